@@ -44,6 +44,7 @@ func main() {
 	action := flag.String("action", "get", `action to perform ("get" or "set")`)
 	size := flag.String("size", "single", `size of the action ("single" or "bulk")`)
 	postID := flag.String("id", "", "blog post id")
+	retrieval := flag.String("retrieval", nlp.EmbeddingsRetrieval, `retrieval method used to answer questions ("embeddings", "keyword", or "hybrid")`)
 
 	flag.Parse()
 
@@ -79,13 +80,18 @@ func main() {
 	dbClient := db.New(
 		newSession,
 		config.AWS.S3.DataBucketName,
-		config.AWS.DynamoDB.QuestionsTableName,
-		config.AWS.DynamoDB.SummariesTableName,
+		db.Tables{
+			Questions: config.AWS.DynamoDB.QuestionsTableName,
+			Summaries: config.AWS.DynamoDB.SummariesTableName,
+		},
 	)
 	nlpClient := nlp.New(
 		newSession,
-		config.OpenAI.APIKey,
+		util.GetProvider(config),
 		config.AWS.S3.DataBucketName,
+		nlp.Retrieval{
+			Method: *retrieval,
+		},
 	)
 
 	if *action == getAction {
@@ -103,14 +109,17 @@ func main() {
 			}
 
 			postURL := fmt.Sprintf("http://www.paulgraham.com/%s.html", *postID)
-			text, err := cntClient.GetText(ctx, postURL)
+			essay, err := cntClient.GetEssay(ctx, cnt.ItemXML{
+				Link:  postURL,
+				Title: title,
+			})
 			if err != nil {
-				log.Fatalf("error getting text: %v", err)
+				log.Fatalf("error getting essay: %v", err)
 			}
 
 			bodyBytes, err := json.Marshal(dct.Document{
 				Metadata: *postID,
-				Text:     title + " " + *text,
+				Text:     essay.Title + " " + essay.Body,
 			})
 			if err != nil {
 				log.Fatalf("error marshalling document: %v", err)
@@ -133,14 +142,14 @@ func main() {
 					continue
 				}
 
-				text, err := cntClient.GetText(ctx, item.Link)
+				essay, err := cntClient.GetEssay(ctx, item)
 				if err != nil {
-					log.Fatalf("error getting text: %v", err)
+					log.Fatalf("error getting essay: %v", err)
 				}
 
 				id := util.GetIDFromURL(item.Link)
 				if err := encoder.Encode(dct.Document{
-					Text:     item.Title + " " + *text,
+					Text:     essay.Title + " " + essay.Body,
 					Metadata: id,
 				}); err != nil {
 					log.Fatalf("error encoding document: %v", err)
@@ -9,12 +9,16 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 
 	"github.com/forstmeier/askpaulgraham/pkg/cnt"
 	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/ess"
+	"github.com/forstmeier/askpaulgraham/pkg/idx"
 	"github.com/forstmeier/askpaulgraham/pkg/nlp"
 	"github.com/forstmeier/askpaulgraham/util"
 )
@@ -25,36 +29,48 @@ const (
 )
 
 const (
-	getAction  = "get"
-	setAction  = "set"
-	singleSize = "single"
-	bulkSize   = "bulk"
+	getAction     = "get"
+	setAction     = "set"
+	relatedAction = "related"
+	singleSize    = "single"
+	bulkSize      = "bulk"
 )
 
+const summariesEndpoint = "summaries"
+
 type summariesJSON struct {
 	Items []summaryJSON `json:"items"`
 }
 
 type summaryJSON struct {
-	ID      string `json:"id"`
-	URL     string `json:"url"`
-	Title   string `json:"title"`
-	Summary string `json:"summary"`
-	Number  int    `json:"number"`
+	ID             string    `json:"id"`
+	URL            string    `json:"url"`
+	Title          string    `json:"title"`
+	Summary        string    `json:"summary"`
+	Number         int       `json:"number"`
+	Published      string    `json:"published,omitempty"`
+	WordCount      int       `json:"word_count,omitempty"`
+	ReadingMinutes int       `json:"reading_minutes,omitempty"`
+	PromptVersion  string    `json:"prompt_version,omitempty"`
+	Usage          *db.Usage `json:"usage,omitempty"`
 }
 
 // The summaries CLI is used to generate and upload essay summaries
 // to be presented via the DynamoDB table.
+//
+// The related essays of every summary are regenerated from the
+// stored documents whenever summaries are set and can be
+// regenerated on their own after the documents change.
 func main() {
 	ctx := context.Background()
 
-	action := flag.String("action", "get", `action to perform ("get" or "set")`)
+	action := flag.String("action", "get", `action to perform ("get", "set", or "related")`)
 	size := flag.String("size", "single", `size of the action ("single" or "bulk")`)
 	postID := flag.String("id", "", "blog post id")
 
 	flag.Parse()
 
-	if *action != getAction && *action != setAction {
+	if *action != getAction && *action != setAction && *action != relatedAction {
 		log.Fatalf("error invalid action: %s", *action)
 	}
 
@@ -85,14 +101,19 @@ func main() {
 	cntClient := cnt.New()
 	nlpClient := nlp.New(
 		newSession,
-		config.OpenAI.APIKey,
+		util.GetProvider(config),
 		config.AWS.S3.DataBucketName,
+		nlp.Retrieval{
+			Method: nlp.EmbeddingsRetrieval,
+		},
 	)
 	dbClient := db.New(
 		newSession,
 		config.AWS.S3.DataBucketName,
-		config.AWS.DynamoDB.QuestionsTableName,
-		config.AWS.DynamoDB.SummariesTableName,
+		db.Tables{
+			Questions: config.AWS.DynamoDB.QuestionsTableName,
+			Summaries: config.AWS.DynamoDB.SummariesTableName,
+		},
 	)
 
 	if *action == getAction {
@@ -108,23 +129,39 @@ func main() {
 			}
 
 			if (*size == bulkSize) || (*size == singleSize && strings.Contains(item.Link, "/"+*postID+".html")) {
-				text, err := cntClient.GetText(ctx, item.Link)
+				essay, err := cntClient.GetEssay(ctx, item)
 				if err != nil {
-					log.Fatalf("error getting text: %v", err)
+					log.Fatalf("error getting essay: %v", err)
 				}
 
-				summary, err := nlpClient.GetSummary(ctx, *text)
+				usageCtx, recorder := nlp.WithUsageRecorder(ctx)
+				summary, err := nlpClient.GetSummary(usageCtx, essay.Body)
 				if err != nil {
 					log.Fatalf("error getting summary: %v", err)
 				}
 
+				published := ""
+				if !essay.Published.IsZero() {
+					published = essay.Published.Format(db.PublishedLayout)
+				}
+
 				id := util.GetIDFromURL(item.Link)
 				summaries = append(summaries, summaryJSON{
-					ID:      id,
-					URL:     item.Link,
-					Title:   item.Title,
-					Summary: *summary,
-					Number:  item.Number,
+					ID:             id,
+					URL:            item.Link,
+					Title:          essay.Title,
+					Summary:        summary.Text,
+					Number:         item.Number,
+					Published:      published,
+					WordCount:      essay.WordCount,
+					ReadingMinutes: essay.ReadingMinutes,
+					PromptVersion:  summary.PromptVersion,
+					Usage: &db.Usage{
+						ID:        id,
+						Endpoint:  summariesEndpoint,
+						Timestamp: time.Now().UTC(),
+						Tokens:    recorder.Usage(),
+					},
 				})
 			}
 		}
@@ -165,15 +202,73 @@ func main() {
 		summariesData := []db.Summary{}
 		for _, item := range summaries.Items {
 			summariesData = append(summariesData, db.Summary{
-				ID:      item.ID,
-				URL:     item.URL,
-				Title:   item.Title,
-				Summary: item.Summary,
-				Number:  item.Number,
+				ID:             item.ID,
+				URL:            item.URL,
+				Title:          item.Title,
+				Summary:        item.Summary,
+				Number:         item.Number,
+				Published:      item.Published,
+				WordCount:      item.WordCount,
+				ReadingMinutes: item.ReadingMinutes,
+				PromptVersion:  item.PromptVersion,
+				Usage:          item.Usage,
 			})
 		}
 		if err := dbClient.StoreSummaries(ctx, summariesData); err != nil {
 			log.Fatalf("error batch writing summaries: %v", err)
 		}
+
+		if err := storeRelated(ctx, dbClient); err != nil {
+			log.Fatalf("error storing related essays: %v", err)
+		}
+
+	} else if *action == relatedAction {
+		if err := storeRelated(ctx, dbClient); err != nil {
+			log.Fatalf("error storing related essays: %v", err)
+		}
+	}
+}
+
+// storeRelated stores the essays most similar to each summarized
+// essay based on the TF-IDF vectors of the stored documents,
+// replacing the related essays stored previously.
+func storeRelated(ctx context.Context, dbClient db.Databaser) error {
+	ids, err := dbClient.GetIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	summarized := map[string]bool{}
+	for _, id := range ids {
+		summarized[id] = true
+	}
+
+	documents, err := dbClient.GetDocuments(ctx)
+	if err != nil {
+		return err
+	}
+
+	summarizedDocuments := []dct.Document{}
+	for _, document := range documents {
+		if summarized[document.Metadata] {
+			summarizedDocuments = append(summarizedDocuments, document)
+		}
 	}
+
+	similar := idx.Similar(summarizedDocuments, ess.MaxRelatedCount)
+	for _, id := range ids {
+		related := []db.Related{}
+		for _, similarity := range similar[id] {
+			related = append(related, db.Related{
+				ID:    similarity.Metadata,
+				Score: similarity.Score,
+			})
+		}
+
+		if err := dbClient.StoreRelated(ctx, id, related); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,137 @@
+//+build !test
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/uuid"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/idx"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+	"github.com/forstmeier/askpaulgraham/pkg/usg"
+	"github.com/forstmeier/askpaulgraham/util"
+)
+
+const topicsEndpoint = "topics"
+
+const topicTitles = 10 // titles of each cluster sent to label it
+
+// The topics CLI is used to cluster the summarized essays by the
+// text of the stored documents, label each cluster with the
+// OpenAI API, and store the labels as the topics of the essays
+// in the DynamoDB table, replacing the topics stored previously.
+func main() {
+	ctx := context.Background()
+
+	count := flag.Int("count", 12, "number of topics to group the essays into")
+
+	flag.Parse()
+
+	if *count < 1 {
+		log.Fatalf("error invalid count: %d", *count)
+	}
+
+	config := util.Config{}
+	configContent, err := os.ReadFile("etc/config/config.json")
+	if err != nil {
+		log.Fatalf("error reading config file: %v", err)
+	}
+	if err := json.Unmarshal(configContent, &config); err != nil {
+		log.Fatalf("error unmarshalling config file: %v", err)
+	}
+
+	newSession, err := session.NewSession(&aws.Config{
+		Region: aws.String("us-east-1"),
+	})
+	if err != nil {
+		log.Fatalf("error creating aws session: %v", err)
+	}
+
+	nlpClient := nlp.New(
+		newSession,
+		util.GetProvider(config),
+		config.AWS.S3.DataBucketName,
+		nlp.Retrieval{
+			Method: nlp.EmbeddingsRetrieval,
+		},
+	)
+	dbClient := db.New(
+		newSession,
+		config.AWS.S3.DataBucketName,
+		db.Tables{
+			Questions: config.AWS.DynamoDB.QuestionsTableName,
+			Summaries: config.AWS.DynamoDB.SummariesTableName,
+		},
+	)
+
+	summaries, err := dbClient.GetSummaries(ctx)
+	if err != nil {
+		log.Fatalf("error getting summaries: %v", err)
+	}
+
+	titles := map[string]string{}
+	for _, summary := range summaries {
+		titles[summary.ID] = summary.Title
+	}
+
+	documents, err := dbClient.GetDocuments(ctx)
+	if err != nil {
+		log.Fatalf("error getting documents: %v", err)
+	}
+
+	summarizedDocuments := []dct.Document{}
+	for _, document := range documents {
+		if _, ok := titles[document.Metadata]; ok {
+			summarizedDocuments = append(summarizedDocuments, document)
+		}
+	}
+
+	usageCtx, recorder := nlp.WithUsageRecorder(ctx)
+
+	topics := map[string][]string{}
+	promptVersion := ""
+	for _, cluster := range idx.Clusters(summarizedDocuments, *count) {
+		clusterTitles := []string{}
+		for _, id := range cluster.Metadata {
+			if len(clusterTitles) < topicTitles {
+				clusterTitles = append(clusterTitles, titles[id])
+			}
+		}
+
+		topic, err := nlpClient.GetTopic(usageCtx, clusterTitles, cluster.Terms)
+		if err != nil {
+			log.Fatalf("error getting topic: %v", err)
+		}
+
+		for _, id := range cluster.Metadata {
+			topics[id] = append(topics[id], topic.Name)
+		}
+		promptVersion = topic.PromptVersion
+
+		log.Printf("topic %q: %d essays", topic.Name, len(cluster.Metadata))
+	}
+
+	if err := usg.StoreUsage(ctx, dbClient, recorder, uuid.NewString(), topicsEndpoint); err != nil {
+		log.Fatalf("error storing usage: %v", err)
+	}
+
+	for _, summary := range summaries {
+		essayTopics := topics[summary.ID]
+		if essayTopics == nil {
+			essayTopics = []string{}
+		}
+
+		if err := dbClient.StoreTopics(ctx, summary.ID, essayTopics, promptVersion); err != nil {
+			log.Fatalf("error storing topics: %v", err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+//+build !test
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/util"
+)
+
+const dayLayout = "2006-01-02"
+
+type usageKey struct {
+	day      string
+	endpoint string
+}
+
+type usageTotal struct {
+	requests         int
+	promptTokens     int
+	completionTokens int
+	cost             float64
+}
+
+// The usage CLI is used to report the OpenAI API token usage and
+// cost per day and per endpoint from the DynamoDB tables.
+func main() {
+	ctx := context.Background()
+
+	since := flag.String("since", "", `first day to report ("YYYY-MM-DD", defaults to all days)`)
+
+	flag.Parse()
+
+	sinceTime := time.Time{}
+	if *since != "" {
+		var err error
+		sinceTime, err = time.Parse(dayLayout, *since)
+		if err != nil {
+			log.Fatalf("error invalid since: %v", err)
+		}
+	}
+
+	config := util.Config{}
+	configContent, err := os.ReadFile("etc/config/config.json")
+	if err != nil {
+		log.Fatalf("error reading config file: %v", err)
+	}
+	if err := json.Unmarshal(configContent, &config); err != nil {
+		log.Fatalf("error unmarshalling config file: %v", err)
+	}
+
+	newSession, err := session.NewSession(&aws.Config{
+		Region: aws.String("us-east-1"),
+	})
+	if err != nil {
+		log.Fatalf("error creating aws session: %v", err)
+	}
+
+	dbClient := db.New(
+		newSession,
+		config.AWS.S3.DataBucketName,
+		db.Tables{
+			Questions: config.AWS.DynamoDB.QuestionsTableName,
+			Summaries: config.AWS.DynamoDB.SummariesTableName,
+		},
+	)
+
+	usage, err := dbClient.GetUsage(ctx)
+	if err != nil {
+		log.Fatalf("error getting usage: %v", err)
+	}
+
+	totals := map[usageKey]*usageTotal{}
+	endpointTotals := map[string]*usageTotal{}
+	unknownModels := map[string]bool{}
+	for _, item := range usage {
+		if item.Timestamp.Before(sinceTime) {
+			continue
+		}
+
+		key := usageKey{
+			day:      item.Timestamp.UTC().Format(dayLayout),
+			endpoint: item.Endpoint,
+		}
+		if totals[key] == nil {
+			totals[key] = &usageTotal{}
+		}
+		if endpointTotals[key.endpoint] == nil {
+			endpointTotals[key.endpoint] = &usageTotal{}
+		}
+
+		for _, total := range []*usageTotal{totals[key], endpointTotals[key.endpoint]} {
+			total.requests++
+			for _, tokens := range item.Tokens {
+				total.promptTokens += tokens.PromptTokens
+				total.completionTokens += tokens.CompletionTokens
+
+				cost, ok := tokens.Cost()
+				if !ok {
+					unknownModels[tokens.Model] = true
+				}
+				total.cost += cost
+			}
+		}
+	}
+
+	keys := make([]usageKey, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].day == keys[j].day {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].day < keys[j].day
+	})
+
+	endpoints := make([]string, 0, len(endpointTotals))
+	for endpoint := range endpointTotals {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "DAY\tENDPOINT\tREQUESTS\tPROMPT TOKENS\tCOMPLETION TOKENS\tCOST (USD)")
+	for _, key := range keys {
+		writeTotal(writer, key.day, key.endpoint, totals[key])
+	}
+	for _, endpoint := range endpoints {
+		writeTotal(writer, "total", endpoint, endpointTotals[endpoint])
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("error writing usage: %v", err)
+	}
+
+	if len(unknownModels) > 0 {
+		models := make([]string, 0, len(unknownModels))
+		for model := range unknownModels {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+
+		fmt.Printf("\nno price for models (not included in cost): %v\n", models)
+	}
+}
+
+func writeTotal(writer *tabwriter.Writer, day, endpoint string, total *usageTotal) {
+	fmt.Fprintf(
+		writer,
+		"%s\t%s\t%d\t%d\t%d\t%.4f\n",
+		day,
+		endpoint,
+		total.requests,
+		total.promptTokens,
+		total.completionTokens,
+		total.cost,
+	)
+}
@@ -2,24 +2,52 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
 
 	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/ess"
 	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+	"github.com/forstmeier/askpaulgraham/pkg/ssn"
+	"github.com/forstmeier/askpaulgraham/pkg/usg"
+	"github.com/forstmeier/askpaulgraham/pkg/vld"
 	"github.com/forstmeier/askpaulgraham/util"
 )
 
+const (
+	searchPath   = "/search"
+	quotesPath   = "/quotes"
+	relatedPath  = "/related"
+	topicsPath   = "/topics"
+	timelinePath = "/timeline"
+)
+
+const questionEndpoint = "question"
+
+// responseReserve is kept back from the Lambda deadline so that
+// an error can be sent before the invocation is stopped.
+const responseReserve = time.Second
+
 type requestPayload struct {
-	Question string `json:"question"`
-	UserID   string `json:"user_id"`
+	Question  string `json:"question"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Mode      string `json:"mode"`
 }
 
-func handler(dbClient db.Databaser, nlpClient nlp.NLPer, jwtSigningKey string) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handler(dbClient db.Databaser, nlpClient nlp.NLPer, jwtSigningKey string, sessionTTL time.Duration) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		util.Log("REQUEST", request)
 
+		ctx, cancel := util.WithDeadlineBudget(ctx, responseReserve)
+		defer cancel()
+
 		// NOTE: this will be added back in once the UI is completed
 		// if err := util.ValidateToken(request.Headers["Token"], jwtSigningKey); err != nil {
 		// 	return util.SendResponse(
@@ -29,80 +57,252 @@ func handler(dbClient db.Databaser, nlpClient nlp.NLPer, jwtSigningKey string) f
 		// 	)
 		// }
 
-		return util.SendResponse(
-			http.StatusOK,
-			nil,
-			"SUCCESSFUL_RESPONSE",
-		)
+		switch request.HTTPMethod {
+		case "GET":
+			if request.Path == searchPath {
+				query := request.QueryStringParameters["query"]
+				if query == "" {
+					return util.SendResponse(
+						http.StatusBadRequest,
+						errors.New("query parameter 'query' is required"),
+						"MISSING_QUERY_ERROR",
+					)
+				}
 
-		/*
-			switch request.HTTPMethod {
-			case "GET":
-				summaries, err := dbClient.GetSummaries(ctx)
+				documents, err := nlpClient.SearchDocuments(ctx, query)
 				if err != nil {
-					return util.SendResponse(
-						http.StatusInternalServerError,
+					return util.SendErrorResponse(
 						err,
-						"GET_SUMMARIES_ERROR",
+						"SEARCH_DOCUMENTS_ERROR",
 					)
 				}
 
 				return util.SendResponse(
 					http.StatusOK,
-					summaries,
+					documents,
 					"SUCCESSFUL_GET_RESPONSE",
 				)
+			}
 
-			case "POST":
-				payload := requestPayload{}
-				if err := json.Unmarshal([]byte(request.Body), &payload); err != nil {
-					return util.SendResponse(
-						http.StatusBadRequest,
+			if request.Path == quotesPath {
+				question := request.QueryStringParameters["question"]
+				if err := vld.ValidateQuestion(question); err != nil {
+					return util.SendErrorResponse(
 						err,
-						"UNMARSHAL_BODY_ERROR",
+						"VALIDATE_QUESTION_ERROR",
 					)
 				}
 
-				id := uuid.NewString()
+				quotes, err := nlpClient.GetQuotes(ctx, question)
+				if err != nil {
+					return util.SendErrorResponse(
+						err,
+						"GET_QUOTES_ERROR",
+					)
+				}
+
+				if err := ess.AddCitationDetails(ctx, dbClient, quotes); err != nil {
+					return util.SendErrorResponse(
+						err,
+						"GET_CITATIONS_ERROR",
+					)
+				}
+
+				return util.SendResponse(
+					http.StatusOK,
+					quotes,
+					"SUCCESSFUL_GET_RESPONSE",
+				)
+			}
 
-				if err := dbClient.StoreQuestion(ctx, id, payload.Question); err != nil {
+			if request.Path == relatedPath {
+				id := request.QueryStringParameters["id"]
+				if id == "" {
 					return util.SendResponse(
-						http.StatusInternalServerError,
+						http.StatusBadRequest,
+						errors.New("query parameter 'id' is required"),
+						"MISSING_ID_ERROR",
+					)
+				}
+
+				count, err := ess.GetRelatedCount(request.QueryStringParameters["count"])
+				if err != nil {
+					return util.SendErrorResponse(
 						err,
-						"STORE_QUESTION_ERROR",
+						"INVALID_COUNT_ERROR",
 					)
 				}
 
-				answer, err := nlpClient.GetAnswer(ctx, payload.Question, payload.UserID)
+				summaries, err := ess.GetRelatedSummaries(ctx, dbClient, id, count)
 				if err != nil {
-					return util.SendResponse(
-						http.StatusInternalServerError,
+					return util.SendErrorResponse(
 						err,
-						"GET_ANSWERS_ERROR",
+						"GET_RELATED_ERROR",
 					)
 				}
 
-				if err := dbClient.StoreAnswer(ctx, id, *answer); err != nil {
-					return util.SendResponse(
-						http.StatusInternalServerError,
+				return util.SendResponse(
+					http.StatusOK,
+					summaries,
+					"SUCCESSFUL_GET_RESPONSE",
+				)
+			}
+
+			if request.Path == timelinePath {
+				page, years, err := ess.GetTimelinePagination(
+					request.QueryStringParameters["page"],
+					request.QueryStringParameters["years"],
+				)
+				if err != nil {
+					return util.SendErrorResponse(
+						err,
+						"INVALID_PAGINATION_ERROR",
+					)
+				}
+
+				summaries, err := dbClient.GetSummaries(ctx)
+				if err != nil {
+					return util.SendErrorResponse(
 						err,
-						"STORE_ANSWER_ERROR",
+						"GET_SUMMARIES_ERROR",
 					)
 				}
 
 				return util.SendResponse(
 					http.StatusOK,
-					*answer,
-					"SUCCESSFUL_POST_RESPONSE",
+					ess.GetTimeline(summaries, page, years),
+					"SUCCESSFUL_GET_RESPONSE",
+				)
+			}
+
+			summaries, err := dbClient.GetSummaries(ctx)
+			if err != nil {
+				return util.SendErrorResponse(
+					err,
+					"GET_SUMMARIES_ERROR",
+				)
+			}
+
+			if request.Path == topicsPath {
+				return util.SendResponse(
+					http.StatusOK,
+					ess.GetTopics(summaries),
+					"SUCCESSFUL_GET_RESPONSE",
+				)
+			}
+
+			if err := ess.SortSummaries(summaries, request.QueryStringParameters["sort"]); err != nil {
+				return util.SendErrorResponse(
+					err,
+					"INVALID_SORT_ERROR",
 				)
+			}
 
-			default:
+			return util.SendResponse(
+				http.StatusOK,
+				summaries,
+				"SUCCESSFUL_GET_RESPONSE",
+			)
+
+		case "POST":
+			payload := requestPayload{}
+			if err := json.Unmarshal([]byte(request.Body), &payload); err != nil {
 				return util.SendResponse(
-					http.StatusMethodNotAllowed,
-					fmt.Errorf("method '%s' not allowed", request.HTTPMethod),
-					"METHOD_NOT_ALLOWED_ERROR",
+					http.StatusBadRequest,
+					err,
+					"UNMARSHAL_BODY_ERROR",
+				)
+			}
+
+			if err := vld.ValidateQuestion(payload.Question); err != nil {
+				return util.SendErrorResponse(
+					err,
+					"VALIDATE_QUESTION_ERROR",
+				)
+			}
+
+			if err := vld.ValidateMode(payload.Mode); err != nil {
+				return util.SendErrorResponse(
+					err,
+					"VALIDATE_MODE_ERROR",
+				)
+			}
+
+			session, err := ssn.GetSession(ctx, dbClient, payload.SessionID)
+			if err != nil {
+				return util.SendErrorResponse(
+					err,
+					"GET_SESSION_ERROR",
+				)
+			}
+
+			if session != nil {
+				ctx = nlp.WithHistory(ctx, session.Turns)
+			}
+
+			ctx = nlp.WithMode(ctx, payload.Mode)
+
+			id := uuid.NewString()
+
+			ctx, recorder := nlp.WithUsageRecorder(ctx)
+
+			if err := dbClient.StoreQuestion(ctx, id, payload.Question); err != nil {
+				return util.SendErrorResponse(
+					err,
+					"STORE_QUESTION_ERROR",
+				)
+			}
+
+			answer, err := nlpClient.GetAnswer(ctx, payload.Question, payload.UserID)
+			if err := usg.StoreUsage(ctx, dbClient, recorder, id, questionEndpoint); err != nil {
+				util.Log("STORE_USAGE_ERROR", err.Error())
+			}
+			if err != nil {
+				return util.SendErrorResponse(
+					err,
+					"GET_ANSWERS_ERROR",
+				)
+			}
+
+			if err := ess.AddCitationDetails(ctx, dbClient, answer.Citations); err != nil {
+				return util.SendErrorResponse(
+					err,
+					"GET_CITATIONS_ERROR",
+				)
+			}
+
+			if err := dbClient.StoreAnswer(ctx, id, *answer); err != nil {
+				return util.SendErrorResponse(
+					err,
+					"STORE_ANSWER_ERROR",
 				)
 			}
-		*/
+
+			if len(answer.Moderations) > 0 {
+				if err := dbClient.StoreModerations(ctx, id, answer.Moderations); err != nil {
+					return util.SendErrorResponse(
+						err,
+						"STORE_MODERATIONS_ERROR",
+					)
+				}
+			}
+
+			if err := ssn.StoreTurn(ctx, dbClient, session, sessionTTL, payload.Question, answer); err != nil {
+				util.Log("STORE_SESSION_ERROR", err.Error())
+			}
+
+			return util.SendResponse(
+				http.StatusOK,
+				*answer,
+				"SUCCESSFUL_POST_RESPONSE",
+			)
+
+		default:
+			return util.SendResponse(
+				http.StatusMethodNotAllowed,
+				fmt.Errorf("method '%s' not allowed", request.HTTPMethod),
+				"METHOD_NOT_ALLOWED_ERROR",
+			)
+		}
 	}
 }
@@ -2,15 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 
+	"github.com/forstmeier/askpaulgraham/pkg/bgt"
 	"github.com/forstmeier/askpaulgraham/pkg/db"
 	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+	"github.com/forstmeier/askpaulgraham/pkg/vld"
 )
 
 func TestMain(m *testing.M) {
@@ -19,10 +28,18 @@ func TestMain(m *testing.M) {
 }
 
 type mockDBClient struct {
-	mockGetSummariesOutput []db.Summary
-	mockGetSummariesError  error
-	mockStoreQuestionError error
-	mockStoreAnwerError    error
+	mockGetSummariesOutput      []db.Summary
+	mockGetSummariesError       error
+	mockGetSummariesByIDsOutput []db.Summary
+	mockGetSummariesByIDsError  error
+	mockStoreQuestionError      error
+	mockStoreAnwerError         error
+	mockStoreModerationsError   error
+	mockStoreUsageError         error
+	storedUsage                 []db.Usage
+	mockGetSessionOutput        *db.Session
+	mockGetSessionError         error
+	storedSessions              []db.Session
 }
 
 func (m *mockDBClient) GetIDs(ctx context.Context) ([]string, error) {
@@ -33,10 +50,22 @@ func (m *mockDBClient) GetSummaries(ctx context.Context) ([]db.Summary, error) {
 	return m.mockGetSummariesOutput, m.mockGetSummariesError
 }
 
+func (m *mockDBClient) GetSummariesByIDs(ctx context.Context, ids []string) ([]db.Summary, error) {
+	return m.mockGetSummariesByIDsOutput, m.mockGetSummariesByIDsError
+}
+
 func (m *mockDBClient) StoreSummaries(ctx context.Context, summaries []db.Summary) error {
 	return nil
 }
 
+func (m *mockDBClient) StoreRelated(ctx context.Context, id string, related []db.Related) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	return nil
+}
+
 func (m *mockDBClient) StoreText(ctx context.Context, id, text string) error {
 	return nil
 }
@@ -53,16 +82,64 @@ func (m *mockDBClient) StoreQuestion(ctx context.Context, id, question string) e
 	return m.mockStoreQuestionError
 }
 
-func (m *mockDBClient) StoreAnswer(ctx context.Context, id, answer string) error {
+func (m *mockDBClient) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
 	return m.mockStoreAnwerError
 }
 
+func (m *mockDBClient) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	return m.mockStoreModerationsError
+}
+
+func (m *mockDBClient) GetCachedAnswer(ctx context.Context, question, mode string) (*db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetCachedAnswers(ctx context.Context) ([]db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreCachedAnswer(ctx context.Context, cachedAnswer db.CachedAnswer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreUsage(ctx context.Context, usage db.Usage) error {
+	m.storedUsage = append(m.storedUsage, usage)
+	return m.mockStoreUsageError
+}
+
+func (m *mockDBClient) GetUsage(ctx context.Context) ([]db.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSpend(ctx context.Context, month string) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) GetSession(ctx context.Context, id string) (*db.Session, error) {
+	return m.mockGetSessionOutput, m.mockGetSessionError
+}
+
+func (m *mockDBClient) StoreSession(ctx context.Context, session db.Session) error {
+	m.storedSessions = append(m.storedSessions, session)
+	return nil
+}
+
 type mockNLPClient struct {
-	mockGetAnswersOutput *string
-	mockGetAnswersError  error
+	mockGetAnswersOutput      *nlp.Answer
+	mockGetAnswersError       error
+	mockSearchDocumentsOutput []dct.Document
+	mockSearchDocumentsError  error
+	mockGetQuotesOutput       []nlp.Citation
+	mockGetQuotesError        error
+	history                   []nlp.Turn
+	mode                      string
 }
 
-func (m *mockNLPClient) GetSummary(ctx context.Context, text string) (*string, error) {
+func (m *mockNLPClient) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
 	return nil, nil
 }
 
@@ -70,127 +147,795 @@ func (m *mockNLPClient) SetDocuments(ctx context.Context, document []dct.Documen
 	return nil
 }
 
-func (m *mockNLPClient) GetAnswer(ctx context.Context, question, userID string) (*string, error) {
+func (m *mockNLPClient) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	m.history = nlp.GetHistory(ctx)
+	m.mode = nlp.GetMode(ctx)
 	return m.mockGetAnswersOutput, m.mockGetAnswersError
 }
 
+func (m *mockNLPClient) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	return m.mockGetAnswersOutput, m.mockGetAnswersError
+}
+
+func (m *mockNLPClient) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	return m.mockSearchDocumentsOutput, m.mockSearchDocumentsError
+}
+
+func (m *mockNLPClient) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return m.mockGetQuotesOutput, m.mockGetQuotesError
+}
+
+func (m *mockNLPClient) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetPromptVersion(ctx context.Context) (string, error) {
+	return "", nil
+}
+
 func Test_handler(t *testing.T) {
-	// mockAnswer := "mock answer"
+	mockAnswer := func() *nlp.Answer {
+		return &nlp.Answer{
+			Text: "mock answer",
+			Citations: []nlp.Citation{
+				{
+					ID:      "mock_id",
+					Excerpt: "mock excerpt",
+				},
+				{
+					ID:      "missing_id",
+					Excerpt: "missing excerpt",
+				},
+			},
+		}
+	}
+
+	mockRefusal := func() *nlp.Answer {
+		return &nlp.Answer{
+			Text: "mock refusal",
+			Refusal: &nlp.Refusal{
+				Target: nlp.QuestionTarget,
+				Categories: []string{
+					"violence",
+				},
+			},
+			Moderations: []nlp.Moderation{
+				{
+					Target:  nlp.QuestionTarget,
+					Flagged: true,
+					Categories: []string{
+						"violence",
+					},
+					Scores: map[string]float64{
+						"violence": 0.9,
+					},
+				},
+			},
+		}
+	}
 
 	tests := []struct {
-		description            string
-		request                events.APIGatewayProxyRequest
-		mockGetSummariesOutput []db.Summary
-		mockGetSummariesError  error
-		mockStoreQuestionError error
-		mockGetAnswersOutput   *string
-		mockGetAnswersError    error
-		mockStoreAnwerError    error
-		statusCode             int
-		body                   string
+		description                 string
+		request                     events.APIGatewayProxyRequest
+		mockGetSummariesOutput      []db.Summary
+		mockGetSummariesByIDsOutput []db.Summary
+		mockGetSummariesByIDsError  error
+		mockGetSummariesError       error
+		mockStoreQuestionError      error
+		mockGetAnswersOutput        *nlp.Answer
+		mockGetAnswersError         error
+		mockStoreAnwerError         error
+		mockStoreModerationsError   error
+		mockGetSessionOutput        *db.Session
+		mockGetSessionError         error
+		mockSearchDocumentsOutput   []dct.Document
+		mockSearchDocumentsError    error
+		mockGetQuotesOutput         []nlp.Citation
+		mockGetQuotesError          error
+		statusCode                  int
+		headers                     map[string]string
+		body                        string
+		history                     []nlp.Turn
+		mode                        string
+		turns                       []nlp.Turn
 	}{
-		/*
-			{
-				description: "unsupported http method",
-				request: events.APIGatewayProxyRequest{
-					HTTPMethod: http.MethodPut,
-				},
-				statusCode: http.StatusMethodNotAllowed,
-				body:       `{"error":"method 'PUT' not allowed"}`,
-			},
-			{
-				description: "error getting data",
-				request: events.APIGatewayProxyRequest{
-					HTTPMethod: http.MethodGet,
-				},
-				mockGetSummariesOutput: nil,
-				mockGetSummariesError:  errors.New("mock get data error"),
-				statusCode:             http.StatusInternalServerError,
-				body:                   `{"error":"mock get data error"}`,
-			},
-			{
-				description: "successful get invocation",
-				request: events.APIGatewayProxyRequest{
-					HTTPMethod: http.MethodGet,
-				},
-				mockGetSummariesOutput: []db.Summary{
+		{
+			description: "unsupported http method",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPut,
+			},
+			statusCode: http.StatusMethodNotAllowed,
+			body:       `{"error":"method 'PUT' not allowed"}`,
+		},
+		{
+			description: "error getting data",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+			},
+			mockGetSummariesOutput: nil,
+			mockGetSummariesError:  errors.New("mock get data error"),
+			statusCode:             http.StatusInternalServerError,
+			body:                   `{"error":"mock get data error"}`,
+		},
+		{
+			description: "successful get invocation",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+			},
+			mockGetSummariesOutput: []db.Summary{
+				{
+					ID:      "mock_id",
+					URL:     "mock_url",
+					Title:   "mock_title",
+					Summary: "mock_summary",
+					Number:  1,
+				},
+			},
+			mockGetSummariesError: nil,
+			statusCode:            http.StatusOK,
+			body:                  `{"message":"success","summaries":[{"id":"mock_id","url":"mock_url","title":"mock_title","summary":"mock_summary","number":1}]}`,
+		},
+		{
+			description: "invalid summaries sort",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				QueryStringParameters: map[string]string{
+					"sort": "title",
+				},
+			},
+			mockGetSummariesOutput: []db.Summary{},
+			statusCode:             http.StatusBadRequest,
+			body:                   `{"error":"sort must be one of number, published"}`,
+		},
+		{
+			description: "successful get invocation sorted by publication month",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				QueryStringParameters: map[string]string{
+					"sort": "published",
+				},
+			},
+			mockGetSummariesOutput: []db.Summary{
+				{
+					ID:     "undated_id",
+					Number: 3,
+				},
+				{
+					ID:        "older_id",
+					Number:    2,
+					Published: "2008-04",
+				},
+				{
+					ID:        "newer_id",
+					Number:    1,
+					Published: "2022-02",
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","summaries":[{"id":"newer_id","url":"","title":"","summary":"","number":1,"published":"2022-02"},{"id":"older_id","url":"","title":"","summary":"","number":2,"published":"2008-04"},{"id":"undated_id","url":"","title":"","summary":"","number":3}]}`,
+		},
+		{
+			description: "successful topics invocation",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/topics",
+			},
+			mockGetSummariesOutput: []db.Summary{
+				{
+					ID:     "first_id",
+					Title:  "first_title",
+					Number: 1,
+					Topics: []string{"startups"},
+				},
+				{
+					ID:     "second_id",
+					Title:  "second_title",
+					Number: 2,
+					Topics: []string{"writing", "startups"},
+				},
+				{
+					ID:     "third_id",
+					Title:  "third_title",
+					Number: 3,
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","topics":[{"name":"startups","essays":[{"id":"second_id","url":"","title":"second_title","summary":"","number":2,"topics":["writing","startups"]},{"id":"first_id","url":"","title":"first_title","summary":"","number":1,"topics":["startups"]}]},{"name":"writing","essays":[{"id":"second_id","url":"","title":"second_title","summary":"","number":2,"topics":["writing","startups"]}]}]}`,
+		},
+		{
+			description: "invalid timeline page",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/timeline",
+				QueryStringParameters: map[string]string{
+					"page": "0",
+				},
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"page must be a positive number"}`,
+		},
+		{
+			description: "invalid timeline years",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/timeline",
+				QueryStringParameters: map[string]string{
+					"years": "50",
+				},
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"years must be a number between 1 and 20"}`,
+		},
+		{
+			description: "error getting timeline summaries",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/timeline",
+			},
+			mockGetSummariesError: errors.New("mock get data error"),
+			statusCode:            http.StatusInternalServerError,
+			body:                  `{"error":"mock get data error"}`,
+		},
+		{
+			description: "successful timeline invocation",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/timeline",
+				QueryStringParameters: map[string]string{
+					"page":  "1",
+					"years": "1",
+				},
+			},
+			mockGetSummariesOutput: []db.Summary{
+				{
+					ID:        "older_id",
+					Number:    1,
+					Published: "2021-03",
+					WordCount: 1000,
+				},
+				{
+					ID:        "first_id",
+					Number:    2,
+					Published: "2022-02",
+					WordCount: 2000,
+				},
+				{
+					ID:     "undated_id",
+					Number: 3,
+				},
+				{
+					ID:        "second_id",
+					Number:    4,
+					Published: "2022-02",
+					WordCount: 500,
+				},
+				{
+					ID:        "third_id",
+					Number:    5,
+					Published: "2022-11",
+					WordCount: 1500,
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","years":[{"year":2022,"count":3,"words":4000,"months":[{"month":"2022-11","essays":[{"id":"third_id","url":"","title":"","summary":"","number":5,"published":"2022-11","word_count":1500}]},{"month":"2022-02","essays":[{"id":"second_id","url":"","title":"","summary":"","number":4,"published":"2022-02","word_count":500},{"id":"first_id","url":"","title":"","summary":"","number":2,"published":"2022-02","word_count":2000}]}]}],"undated":1,"page":1,"pages":2}`,
+		},
+		{
+			description: "timeline page past the last page",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/timeline",
+				QueryStringParameters: map[string]string{
+					"page": "3",
+				},
+			},
+			mockGetSummariesOutput: []db.Summary{
+				{
+					ID:        "first_id",
+					Number:    1,
+					Published: "2022-02",
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","years":[],"undated":0,"page":3,"pages":1}`,
+		},
+		{
+			description: "missing search query",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/search",
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"query parameter 'query' is required"}`,
+		},
+		{
+			description: "error searching documents",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/search",
+				QueryStringParameters: map[string]string{
+					"query": "ramen",
+				},
+			},
+			mockSearchDocumentsOutput: nil,
+			mockSearchDocumentsError:  errors.New("mock search documents error"),
+			statusCode:                http.StatusInternalServerError,
+			body:                      `{"error":"mock search documents error"}`,
+		},
+		{
+			description: "successful search invocation",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/search",
+				QueryStringParameters: map[string]string{
+					"query": "ramen",
+				},
+			},
+			mockSearchDocumentsOutput: []dct.Document{
+				{
+					Text:     "mock_text",
+					Metadata: "mock_id",
+				},
+			},
+			mockSearchDocumentsError: nil,
+			statusCode:               http.StatusOK,
+			body:                     `{"message":"success","results":[{"text":"mock_text","metadata":"mock_id"}]}`,
+		},
+		{
+			description: "missing quotes question",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/quotes",
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"question is required","code":"QUESTION_EMPTY"}`,
+		},
+		{
+			description: "error getting quotes",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/quotes",
+				QueryStringParameters: map[string]string{
+					"question": "what is ramen profitable?",
+				},
+			},
+			mockGetQuotesError: errors.New("mock get quotes error"),
+			statusCode:         http.StatusInternalServerError,
+			body:               `{"error":"mock get quotes error"}`,
+		},
+		{
+			description: "successful quotes invocation",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/quotes",
+				QueryStringParameters: map[string]string{
+					"question": "what is ramen profitable?",
+				},
+			},
+			mockGetQuotesOutput: []nlp.Citation{
+				{
+					ID:      "mock_id",
+					Excerpt: "Ramen profitable means a startup makes just enough to pay the founders' living expenses.",
+				},
+			},
+			mockGetSummariesByIDsOutput: []db.Summary{
+				{
+					ID:    "mock_id",
+					URL:   "mock_url",
+					Title: "mock_title",
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","quotes":[{"id":"mock_id","title":"mock_title","url":"mock_url","excerpt":"Ramen profitable means a startup makes just enough to pay the founders' living expenses."}]}`,
+		},
+		{
+			description: "missing related id",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/related",
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"query parameter 'id' is required"}`,
+		},
+		{
+			description: "invalid related count",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/related",
+				QueryStringParameters: map[string]string{
+					"id":    "mock_id",
+					"count": "100",
+				},
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"count must be a number between 1 and 10"}`,
+		},
+		{
+			description: "error getting related summaries",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/related",
+				QueryStringParameters: map[string]string{
+					"id": "mock_id",
+				},
+			},
+			mockGetSummariesByIDsError: errors.New("mock get summaries by ids error"),
+			statusCode:                 http.StatusInternalServerError,
+			body:                       `{"error":"mock get summaries by ids error"}`,
+		},
+		{
+			description: "related essay not found",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/related",
+				QueryStringParameters: map[string]string{
+					"id": "missing_id",
+				},
+			},
+			mockGetSummariesByIDsOutput: []db.Summary{},
+			statusCode:                  http.StatusNotFound,
+			body:                        `{"error":"essay not found"}`,
+		},
+		{
+			description: "successful related invocation",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/related",
+				QueryStringParameters: map[string]string{
+					"id":    "mock_id",
+					"count": "2",
+				},
+			},
+			mockGetSummariesByIDsOutput: []db.Summary{
+				{
+					ID:    "related_id",
+					URL:   "related_url",
+					Title: "related_title",
+				},
+				{
+					ID:    "mock_id",
+					URL:   "mock_url",
+					Title: "mock_title",
+					Related: []db.Related{
+						{
+							ID:    "missing_id",
+							Score: 0.8,
+						},
+						{
+							ID:    "related_id",
+							Score: 0.5,
+						},
+						{
+							ID:    "other_id",
+							Score: 0.2,
+						},
+					},
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","summaries":[{"id":"related_id","url":"related_url","title":"related_title","summary":"","number":0}]}`,
+		},
+		{
+			description: "empty question",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"  "}`,
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"question is required","code":"QUESTION_EMPTY"}`,
+		},
+		{
+			description: "question too long",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"` + strings.Repeat("é", vld.MaxQuestionLength+1) + `"}`,
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"question must be less than or equal to 100 characters","code":"QUESTION_TOO_LONG"}`,
+		},
+		{
+			description: "unsupported mode",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question","mode":"haiku"}`,
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"mode must be one of concise, detailed, bullets, quotes","code":"MODE_UNSUPPORTED"}`,
+		},
+		{
+			description: "invalid session id",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question","session_id":"mock_session"}`,
+			},
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"session_id must be a uuid"}`,
+		},
+		{
+			description: "error getting session",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question","session_id":"5f8e7a3c-1b2d-4e6f-9a0b-c1d2e3f4a5b6"}`,
+			},
+			mockGetSessionError: errors.New("mock get session error"),
+			statusCode:          http.StatusInternalServerError,
+			body:                `{"error":"mock get session error"}`,
+		},
+		{
+			description: "error storing question",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockStoreQuestionError: errors.New("mock store question error"),
+			statusCode:             http.StatusInternalServerError,
+			body:                   `{"error":"mock store question error"}`,
+		},
+		{
+			description: "error getting answers",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockStoreQuestionError: nil,
+			mockGetAnswersOutput:   nil,
+			mockGetAnswersError:    errors.New("mock get answers error"),
+			statusCode:             http.StatusInternalServerError,
+			body:                   `{"error":"mock get answers error"}`,
+		},
+		{
+			description: "error getting answers from rate limited provider",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockGetAnswersError: &nlp.APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "rate_limit_exceeded",
+				Message:    "mock rate limit",
+				RetryAfter: 1500 * time.Millisecond,
+			},
+			statusCode: http.StatusTooManyRequests,
+			headers: map[string]string{
+				"Retry-After": "2",
+			},
+			body: `{"error":"nlp: 429 rate_limit_exceeded: mock rate limit"}`,
+		},
+		{
+			description: "error getting answers from unavailable provider",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockGetAnswersError: &nlp.APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "insufficient_quota",
+				Message:    "mock quota",
+			},
+			statusCode: http.StatusServiceUnavailable,
+			headers:    nil,
+			body:       `{"error":"nlp: 429 insufficient_quota: mock quota"}`,
+		},
+		{
+			description: "error getting answers past monthly budget",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockGetAnswersError: bgt.ErrThrottled,
+			statusCode:          http.StatusServiceUnavailable,
+			headers:             nil,
+			body:                `{"error":"service throttled","code":"SERVICE_THROTTLED"}`,
+		},
+		{
+			description: "error getting answers before deadline",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockGetAnswersError: fmt.Errorf("mock get answers: %w", context.DeadlineExceeded),
+			statusCode:          http.StatusGatewayTimeout,
+			headers:             nil,
+			body:                `{"error":"request timed out"}`,
+		},
+		{
+			description: "error storing answer",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockStoreQuestionError: nil,
+			mockGetAnswersOutput:   mockAnswer(),
+			mockGetAnswersError:    nil,
+			mockStoreAnwerError:    errors.New("mock store answer error"),
+			statusCode:             http.StatusInternalServerError,
+			body:                   `{"error":"mock store answer error"}`,
+		},
+		{
+			description: "error storing moderations",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockGetAnswersOutput:      mockRefusal(),
+			mockStoreModerationsError: errors.New("mock store moderations error"),
+			statusCode:                http.StatusInternalServerError,
+			body:                      `{"error":"mock store moderations error"}`,
+		},
+		{
+			description: "error getting citations",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockStoreQuestionError:     nil,
+			mockGetAnswersOutput:       mockAnswer(),
+			mockGetAnswersError:        nil,
+			mockGetSummariesByIDsError: errors.New("mock get summaries by ids error"),
+			statusCode:                 http.StatusInternalServerError,
+			body:                       `{"error":"mock get summaries by ids error"}`,
+		},
+		{
+			description: "successful post invocation",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockStoreQuestionError: nil,
+			mockGetAnswersOutput:   mockAnswer(),
+			mockGetAnswersError:    nil,
+			mockGetSummariesByIDsOutput: []db.Summary{
+				{
+					ID:    "mock_id",
+					URL:   "mock_url",
+					Title: "mock_title",
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","answer":"mock answer","citations":[{"id":"mock_id","title":"mock_title","url":"mock_url","excerpt":"mock excerpt"},{"id":"missing_id","title":"","url":"http://www.paulgraham.com/missing_id.html","excerpt":"missing excerpt"}],"cached":false}`,
+		},
+		{
+			description: "successful post invocation without answer",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockStoreQuestionError: nil,
+			mockGetAnswersOutput:   &nlp.Answer{},
+			mockGetAnswersError:    nil,
+			statusCode:             http.StatusOK,
+			body:                   `{"message":"success","answer":"","citations":[],"cached":false}`,
+		},
+		{
+			description: "successful post invocation with refused question",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockGetAnswersOutput: mockRefusal(),
+			statusCode:           http.StatusOK,
+			body:                 `{"message":"success","answer":"mock refusal","citations":[],"cached":false,"refusal":{"target":"question","categories":["violence"]}}`,
+		},
+		{
+			description: "successful post invocation with session",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question","session_id":"5f8e7a3c-1b2d-4e6f-9a0b-c1d2e3f4a5b6"}`,
+			},
+			mockGetSessionOutput: &db.Session{
+				ID: "5f8e7a3c-1b2d-4e6f-9a0b-c1d2e3f4a5b6",
+				Turns: []nlp.Turn{
 					{
-						ID:      "mock_id",
-						URL:     "mock_url",
-						Title:   "mock_title",
-						Summary: "mock_summary",
-						Number:  1,
+						Question: "earlier question",
+						Answer:   "Earlier answer.",
 					},
 				},
-				mockGetSummariesError: nil,
-				statusCode:            http.StatusOK,
-				body:                  `{"message":"success","summaries":[{"id":"mock_id","url":"mock_url","title":"mock_title","summary":"mock_summary","number":1}]}`,
-			},
-			{
-				description: "error storing question",
-				request: events.APIGatewayProxyRequest{
-					HTTPMethod: http.MethodPost,
-					Body:       `{"question":"mock_question"}`,
-				},
-				mockStoreQuestionError: errors.New("mock store question error"),
-				statusCode:             http.StatusInternalServerError,
-				body:                   `{"error":"mock store question error"}`,
-			},
-			{
-				description: "error getting answers",
-				request: events.APIGatewayProxyRequest{
-					HTTPMethod: http.MethodPost,
-					Body:       `{"question":"mock_question"}`,
-				},
-				mockStoreQuestionError: nil,
-				mockGetAnswersOutput:   nil,
-				mockGetAnswersError:    errors.New("mock get answers error"),
-				statusCode:             http.StatusInternalServerError,
-				body:                   `{"error":"mock get answers error"}`,
-			},
-			{
-				description: "error storing answer",
-				request: events.APIGatewayProxyRequest{
-					HTTPMethod: http.MethodPost,
-					Body:       `{"question":"mock_question"}`,
-				},
-				mockStoreQuestionError: nil,
-				mockGetAnswersOutput:   &mockAnswer,
-				mockGetAnswersError:    nil,
-				mockStoreAnwerError:    errors.New("mock store answer error"),
-				statusCode:             http.StatusInternalServerError,
-				body:                   `{"error":"mock store answer error"}`,
-			},
-			{
-				description: "successful post invocation",
-				request: events.APIGatewayProxyRequest{
-					HTTPMethod: http.MethodPost,
-					Body:       `{"question":"mock_question"}`,
-				},
-				mockStoreQuestionError: nil,
-				mockGetAnswersOutput:   &mockAnswer,
-				mockGetAnswersError:    nil,
-				statusCode:             http.StatusOK,
-				body:                   `{"message":"success","answer":"mock answer"}`,
-			},
-		*/
+			},
+			mockGetAnswersOutput: &nlp.Answer{
+				Text: "mock answer",
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","answer":"mock answer","citations":[],"cached":false}`,
+			history: []nlp.Turn{
+				{
+					Question: "earlier question",
+					Answer:   "Earlier answer.",
+				},
+			},
+			turns: []nlp.Turn{
+				{
+					Question: "earlier question",
+					Answer:   "Earlier answer.",
+				},
+				{
+					Question: "mock_question",
+					Answer:   "mock answer",
+				},
+			},
+		},
+		{
+			description: "successful post invocation with mode",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question","mode":"bullets"}`,
+			},
+			mockGetAnswersOutput: &nlp.Answer{
+				Text: "- mock answer",
+				Mode: nlp.BulletsMode,
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","answer":"- mock answer","citations":[],"cached":false}`,
+			mode:       nlp.BulletsMode,
+		},
+		{
+			description: "successful post invocation with ungrounded answer",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockGetAnswersOutput: &nlp.Answer{
+				Text: "mock answer",
+				Grounding: &nlp.Grounding{
+					Score:   0,
+					Flagged: true,
+					Claims: []nlp.Claim{
+						{
+							Text: "Mock answer.",
+						},
+					},
+				},
+			},
+			statusCode: http.StatusOK,
+			body:       `{"message":"success","answer":"mock answer","citations":[],"cached":false,"grounding":{"score":0,"flagged":true,"claims":[{"text":"Mock answer.","score":0,"supported":false}]}}`,
+		},
+		{
+			description: "successful post invocation with cached answer",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"question":"mock_question"}`,
+			},
+			mockStoreQuestionError: nil,
+			mockGetAnswersOutput: &nlp.Answer{
+				Text:   "mock answer",
+				Cached: true,
+			},
+			mockGetAnswersError: nil,
+			statusCode:          http.StatusOK,
+			body:                `{"message":"success","answer":"mock answer","citations":[],"cached":true}`,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.description, func(t *testing.T) {
 			d := &mockDBClient{
-				mockGetSummariesOutput: test.mockGetSummariesOutput,
-				mockGetSummariesError:  test.mockGetSummariesError,
-				mockStoreQuestionError: test.mockStoreQuestionError,
-				mockStoreAnwerError:    test.mockStoreAnwerError,
+				mockGetSummariesOutput:      test.mockGetSummariesOutput,
+				mockGetSummariesError:       test.mockGetSummariesError,
+				mockGetSummariesByIDsOutput: test.mockGetSummariesByIDsOutput,
+				mockGetSummariesByIDsError:  test.mockGetSummariesByIDsError,
+				mockStoreQuestionError:      test.mockStoreQuestionError,
+				mockStoreAnwerError:         test.mockStoreAnwerError,
+				mockStoreModerationsError:   test.mockStoreModerationsError,
+				mockGetSessionOutput:        test.mockGetSessionOutput,
+				mockGetSessionError:         test.mockGetSessionError,
 			}
 
 			n := &mockNLPClient{
-				mockGetAnswersOutput: test.mockGetAnswersOutput,
-				mockGetAnswersError:  test.mockGetAnswersError,
+				mockGetAnswersOutput:      test.mockGetAnswersOutput,
+				mockGetAnswersError:       test.mockGetAnswersError,
+				mockSearchDocumentsOutput: test.mockSearchDocumentsOutput,
+				mockSearchDocumentsError:  test.mockSearchDocumentsError,
+				mockGetQuotesOutput:       test.mockGetQuotesOutput,
+				mockGetQuotesError:        test.mockGetQuotesError,
 			}
 
-			handlerFunc := handler(d, n, "jwt_signing_key")
+			handlerFunc := handler(d, n, "jwt_signing_key", time.Hour)
 
 			response, _ := handlerFunc(context.Background(), test.request)
 
@@ -198,9 +943,29 @@ func Test_handler(t *testing.T) {
 				t.Errorf("incorrect status code, received: %d, expected: %d", response.StatusCode, test.statusCode)
 			}
 
+			if !reflect.DeepEqual(response.Headers, test.headers) {
+				t.Errorf("incorrect headers, received: %v, expected: %v", response.Headers, test.headers)
+			}
+
 			if response.Body != test.body {
 				t.Errorf("incorrect body, received: %q, expected: %q", response.Body, test.body)
 			}
+
+			if !reflect.DeepEqual(n.history, test.history) {
+				t.Errorf("incorrect history, received: %+v, expected: %+v", n.history, test.history)
+			}
+
+			if test.mode != "" && n.mode != test.mode {
+				t.Errorf("incorrect mode, received: %s, expected: %s", n.mode, test.mode)
+			}
+
+			turns := []nlp.Turn(nil)
+			if len(d.storedSessions) > 0 {
+				turns = d.storedSessions[0].Turns
+			}
+			if !reflect.DeepEqual(turns, test.turns) {
+				t.Errorf("incorrect session turns, received: %+v, expected: %+v", turns, test.turns)
+			}
 		})
 	}
 }
@@ -9,8 +9,12 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws/session"
 
+	"github.com/forstmeier/askpaulgraham/pkg/bgt"
+	"github.com/forstmeier/askpaulgraham/pkg/cch"
 	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/mdr"
 	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+	"github.com/forstmeier/askpaulgraham/util"
 )
 
 func main() {
@@ -22,15 +26,57 @@ func main() {
 	dbClient := db.New(
 		newSession,
 		os.Getenv("DATA_BUCKET_NAME"),
-		os.Getenv("QUESTIONS_TABLE_NAME"),
-		os.Getenv("SUMMARIES_TABLE_NAME"),
+		db.Tables{
+			Questions: os.Getenv("QUESTIONS_TABLE_NAME"),
+			Summaries: os.Getenv("SUMMARIES_TABLE_NAME"),
+			Cache:     os.Getenv("CACHE_TABLE_NAME"),
+			Spend:     os.Getenv("SPEND_TABLE_NAME"),
+			Sessions:  os.Getenv("SESSIONS_TABLE_NAME"),
+		},
 	)
 
-	nlpClient := nlp.New(
-		newSession,
-		os.Getenv("OPENAI_API_KEY"),
-		os.Getenv("DATA_BUCKET_NAME"),
+	provider, err := util.GetEnvProvider()
+	if err != nil {
+		panic(fmt.Sprintf("error getting provider: %v", err))
+	}
+
+	retrieval, err := util.GetEnvRetrieval()
+	if err != nil {
+		panic(fmt.Sprintf("error getting retrieval: %v", err))
+	}
+
+	cacheThreshold, err := util.GetEnvCacheThreshold()
+	if err != nil {
+		panic(fmt.Sprintf("error getting cache threshold: %v", err))
+	}
+
+	budget, err := util.GetEnvBudget()
+	if err != nil {
+		panic(fmt.Sprintf("error getting budget: %v", err))
+	}
+
+	sessionTTL, err := util.GetEnvSessionTTL()
+	if err != nil {
+		panic(fmt.Sprintf("error getting session ttl: %v", err))
+	}
+
+	nlpClient := mdr.New(
+		cch.New(
+			dbClient,
+			bgt.New(
+				dbClient,
+				nlp.New(
+					newSession,
+					*provider,
+					os.Getenv("DATA_BUCKET_NAME"),
+					*retrieval,
+				),
+				*budget,
+				util.Log,
+			),
+			cacheThreshold,
+		),
 	)
 
-	lambda.Start(handler(dbClient, nlpClient, os.Getenv("JWT_SIGNING_KEY")))
+	lambda.Start(handler(dbClient, nlpClient, os.Getenv("JWT_SIGNING_KEY"), sessionTTL))
 }
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/ess"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+	"github.com/forstmeier/askpaulgraham/pkg/ssn"
+	"github.com/forstmeier/askpaulgraham/pkg/usg"
+	"github.com/forstmeier/askpaulgraham/pkg/vld"
+	"github.com/forstmeier/askpaulgraham/util"
+)
+
+const (
+	tokenEvent  = "token"
+	answerEvent = "answer"
+	errorEvent  = "error"
+)
+
+// responseReserve is kept back from the Lambda deadline so that
+// an error can be sent before the invocation is stopped.
+const responseReserve = time.Second
+
+const questionEndpoint = "question/stream"
+
+type requestPayload struct {
+	Question  string `json:"question"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Mode      string `json:"mode"`
+}
+
+type tokenPayload struct {
+	Text string `json:"text"`
+}
+
+type answerPayload struct {
+	QuestionID string         `json:"question_id"`
+	Answer     string         `json:"answer"`
+	Citations  []nlp.Citation `json:"citations"`
+	Cached     bool           `json:"cached"`
+	Refusal    *nlp.Refusal   `json:"refusal,omitempty"`
+	Grounding  *nlp.Grounding `json:"grounding,omitempty"`
+}
+
+type errorPayload struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// handler streams answers to questions as server-sent events.
+//
+// Each token from the LLM is sent as a "token" event and the
+// formatted answer, citations, and stored question ID are sent
+// as a final "answer" event which replaces the streamed tokens.
+// Errors after the first event are sent as an "error" event
+// since the status code has already been written.
+//
+// Questions sent with a session ID are answered as follow-ups to
+// the earlier questions of the session which is kept for the
+// session TTL after the latest question.
+func handler(dbClient db.Databaser, nlpClient nlp.NLPer, sessionTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		util.Log("REQUEST", r.Method+" "+r.URL.Path)
+
+		if r.Method != http.MethodPost {
+			sendError(
+				w,
+				http.StatusMethodNotAllowed,
+				fmt.Errorf("method '%s' not allowed", r.Method),
+				"METHOD_NOT_ALLOWED_ERROR",
+			)
+			return
+		}
+
+		payload := requestPayload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			sendError(
+				w,
+				http.StatusBadRequest,
+				err,
+				"UNMARSHAL_BODY_ERROR",
+			)
+			return
+		}
+
+		if err := vld.ValidateQuestion(payload.Question); err != nil {
+			sendError(
+				w,
+				http.StatusBadRequest,
+				err,
+				"VALIDATE_QUESTION_ERROR",
+			)
+			return
+		}
+
+		if err := vld.ValidateMode(payload.Mode); err != nil {
+			sendError(
+				w,
+				http.StatusBadRequest,
+				err,
+				"VALIDATE_MODE_ERROR",
+			)
+			return
+		}
+
+		ctx, cancel := util.WithDeadlineBudget(r.Context(), responseReserve)
+		defer cancel()
+
+		stream := &eventStream{
+			writer: w,
+		}
+
+		session, err := ssn.GetSession(ctx, dbClient, payload.SessionID)
+		if err != nil {
+			stream.fail(err, "GET_SESSION_ERROR")
+			return
+		}
+
+		if session != nil {
+			ctx = nlp.WithHistory(ctx, session.Turns)
+		}
+
+		ctx = nlp.WithMode(ctx, payload.Mode)
+
+		id := uuid.NewString()
+
+		ctx, recorder := nlp.WithUsageRecorder(ctx)
+
+		if err := dbClient.StoreQuestion(ctx, id, payload.Question); err != nil {
+			stream.fail(err, "STORE_QUESTION_ERROR")
+			return
+		}
+
+		answer, err := nlpClient.StreamAnswer(ctx, payload.Question, payload.UserID, func(token string) error {
+			return stream.send(tokenEvent, tokenPayload{
+				Text: token,
+			})
+		})
+		if err := usg.StoreUsage(ctx, dbClient, recorder, id, questionEndpoint); err != nil {
+			util.Log("STORE_USAGE_ERROR", err.Error())
+		}
+		if err != nil {
+			stream.fail(err, "GET_ANSWERS_ERROR")
+			return
+		}
+
+		if err := ess.AddCitationDetails(ctx, dbClient, answer.Citations); err != nil {
+			stream.fail(err, "GET_CITATIONS_ERROR")
+			return
+		}
+
+		if err := dbClient.StoreAnswer(ctx, id, *answer); err != nil {
+			stream.fail(err, "STORE_ANSWER_ERROR")
+			return
+		}
+
+		if len(answer.Moderations) > 0 {
+			if err := dbClient.StoreModerations(ctx, id, answer.Moderations); err != nil {
+				stream.fail(err, "STORE_MODERATIONS_ERROR")
+				return
+			}
+		}
+
+		if err := ssn.StoreTurn(ctx, dbClient, session, sessionTTL, payload.Question, answer); err != nil {
+			util.Log("STORE_SESSION_ERROR", err.Error())
+		}
+
+		citations := answer.Citations
+		if citations == nil {
+			citations = []nlp.Citation{}
+		}
+
+		if err := stream.send(answerEvent, answerPayload{
+			QuestionID: id,
+			Answer:     answer.Text,
+			Citations:  citations,
+			Cached:     answer.Cached,
+			Refusal:    answer.Refusal,
+			Grounding:  answer.Grounding,
+		}); err != nil {
+			util.Log("SEND_EVENT_ERROR", err.Error())
+		}
+	}
+}
+
+// eventStream writes server-sent events and only commits to the
+// event stream status and headers with the first event so that
+// earlier errors can still be sent with a status code.
+type eventStream struct {
+	writer  http.ResponseWriter
+	started bool
+}
+
+func (e *eventStream) send(event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if !e.started {
+		e.started = true
+		e.writer.Header().Set("Content-Type", "text/event-stream")
+		e.writer.Header().Set("Cache-Control", "no-cache")
+		e.writer.WriteHeader(http.StatusOK)
+	}
+
+	if _, err := fmt.Fprintf(e.writer, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+
+	if flusher, ok := e.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+func (e *eventStream) fail(err error, message string) {
+	statusCode, retryAfter := util.GetErrorStatus(err)
+	if statusCode == http.StatusGatewayTimeout {
+		util.Log(message, err.Error())
+		err = util.ErrTimeout
+	}
+
+	if !e.started {
+		if retryAfter > 0 {
+			e.writer.Header().Set("Retry-After", util.FormatRetryAfter(retryAfter))
+		}
+
+		sendError(e.writer, statusCode, err, message)
+		return
+	}
+
+	util.Log(message, err.Error())
+	if err := e.send(errorEvent, errorPayload{
+		Error: err.Error(),
+		Code:  util.GetErrorCode(err),
+	}); err != nil {
+		util.Log("SEND_EVENT_ERROR", err.Error())
+	}
+}
+
+func sendError(w http.ResponseWriter, statusCode int, err error, message string) {
+	util.Log(message, err.Error())
+
+	data, err := json.Marshal(errorPayload{
+		Error: err.Error(),
+		Code:  util.GetErrorCode(err),
+	})
+	if err != nil {
+		statusCode = http.StatusInternalServerError
+		data = []byte(fmt.Sprintf(`{"error": %q}`, err))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
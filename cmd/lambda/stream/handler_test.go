@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/forstmeier/askpaulgraham/pkg/bgt"
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+type mockDBClient struct {
+	mockGetSummariesByIDsOutput []db.Summary
+	mockGetSummariesByIDsError  error
+	mockStoreQuestionError      error
+	mockStoreAnwerError         error
+	mockStoreModerationsError   error
+	mockStoreUsageError         error
+	storedUsage                 []db.Usage
+	mockGetSessionOutput        *db.Session
+	mockGetSessionError         error
+	storedSessions              []db.Session
+}
+
+func (m *mockDBClient) GetIDs(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummaries(ctx context.Context) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummariesByIDs(ctx context.Context, ids []string) ([]db.Summary, error) {
+	return m.mockGetSummariesByIDsOutput, m.mockGetSummariesByIDsError
+}
+
+func (m *mockDBClient) StoreSummaries(ctx context.Context, summaries []db.Summary) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreRelated(ctx context.Context, id string, related []db.Related) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreText(ctx context.Context, id, text string) error {
+	return nil
+}
+
+func (m *mockDBClient) GetDocuments(ctx context.Context) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreQuestion(ctx context.Context, id, question string) error {
+	return m.mockStoreQuestionError
+}
+
+func (m *mockDBClient) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
+	return m.mockStoreAnwerError
+}
+
+func (m *mockDBClient) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	return m.mockStoreModerationsError
+}
+
+func (m *mockDBClient) GetCachedAnswer(ctx context.Context, question, mode string) (*db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetCachedAnswers(ctx context.Context) ([]db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreCachedAnswer(ctx context.Context, cachedAnswer db.CachedAnswer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreUsage(ctx context.Context, usage db.Usage) error {
+	m.storedUsage = append(m.storedUsage, usage)
+	return m.mockStoreUsageError
+}
+
+func (m *mockDBClient) GetUsage(ctx context.Context) ([]db.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSpend(ctx context.Context, month string) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) GetSession(ctx context.Context, id string) (*db.Session, error) {
+	return m.mockGetSessionOutput, m.mockGetSessionError
+}
+
+func (m *mockDBClient) StoreSession(ctx context.Context, session db.Session) error {
+	m.storedSessions = append(m.storedSessions, session)
+	return nil
+}
+
+type mockNLPClient struct {
+	mockTokens             []string
+	mockStreamAnswerOutput *nlp.Answer
+	mockStreamAnswerError  error
+	history                []nlp.Turn
+	mode                   string
+}
+
+func (m *mockNLPClient) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) SetDocuments(ctx context.Context, document []dct.Document) error {
+	return nil
+}
+
+func (m *mockNLPClient) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	m.history = nlp.GetHistory(ctx)
+	return nil, nil
+}
+
+func (m *mockNLPClient) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	m.history = nlp.GetHistory(ctx)
+	m.mode = nlp.GetMode(ctx)
+
+	for _, token := range m.mockTokens {
+		if err := send(token); err != nil {
+			return nil, err
+		}
+	}
+
+	return m.mockStreamAnswerOutput, m.mockStreamAnswerError
+}
+
+func (m *mockNLPClient) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetPromptVersion(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+var questionIDRegexp = regexp.MustCompile(`"question_id":"[0-9a-f-]{36}"`)
+
+func Test_handler(t *testing.T) {
+	mockAnswer := func() *nlp.Answer {
+		return &nlp.Answer{
+			Text: "Mock answer.",
+			Citations: []nlp.Citation{
+				{
+					ID:      "mock_id",
+					Excerpt: "mock excerpt",
+				},
+			},
+		}
+	}
+
+	mockRefusal := func() *nlp.Answer {
+		return &nlp.Answer{
+			Text: "Mock refusal.",
+			Refusal: &nlp.Refusal{
+				Target: nlp.AnswerTarget,
+				Categories: []string{
+					"violence",
+				},
+			},
+			Moderations: []nlp.Moderation{
+				{
+					Target:     nlp.QuestionTarget,
+					Categories: []string{},
+				},
+				{
+					Target:  nlp.AnswerTarget,
+					Flagged: true,
+					Categories: []string{
+						"violence",
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		description                 string
+		method                      string
+		body                        string
+		mockGetSummariesByIDsOutput []db.Summary
+		mockGetSummariesByIDsError  error
+		mockStoreQuestionError      error
+		mockStoreAnwerError         error
+		mockStoreModerationsError   error
+		mockGetSessionOutput        *db.Session
+		mockGetSessionError         error
+		mockTokens                  []string
+		mockStreamAnswerOutput      *nlp.Answer
+		mockStreamAnswerError       error
+		statusCode                  int
+		contentType                 string
+		retryAfter                  string
+		responseBody                string
+		history                     []nlp.Turn
+		mode                        string
+		turns                       []nlp.Turn
+	}{
+		{
+			description:  "unsupported http method",
+			method:       http.MethodGet,
+			body:         "",
+			statusCode:   http.StatusMethodNotAllowed,
+			contentType:  "application/json",
+			responseBody: `{"error":"method 'GET' not allowed"}`,
+		},
+		{
+			description:  "error unmarshalling body",
+			method:       http.MethodPost,
+			body:         "{",
+			statusCode:   http.StatusBadRequest,
+			contentType:  "application/json",
+			responseBody: `{"error":"unexpected EOF"}`,
+		},
+		{
+			description:  "question in unsupported script",
+			method:       http.MethodPost,
+			body:         `{"question":"что такое стартап?"}`,
+			statusCode:   http.StatusBadRequest,
+			contentType:  "application/json",
+			responseBody: `{"error":"question must be written in the latin script","code":"QUESTION_UNSUPPORTED_SCRIPT"}`,
+		},
+		{
+			description:  "unsupported mode",
+			method:       http.MethodPost,
+			body:         `{"question":"mock_question","mode":"haiku"}`,
+			statusCode:   http.StatusBadRequest,
+			contentType:  "application/json",
+			responseBody: `{"error":"mode must be one of concise, detailed, bullets, quotes","code":"MODE_UNSUPPORTED"}`,
+		},
+		{
+			description:  "invalid session id",
+			method:       http.MethodPost,
+			body:         `{"question":"mock_question","session_id":"mock_session"}`,
+			statusCode:   http.StatusBadRequest,
+			contentType:  "application/json",
+			responseBody: `{"error":"session_id must be a uuid"}`,
+		},
+		{
+			description:         "error getting session",
+			method:              http.MethodPost,
+			body:                `{"question":"mock_question","session_id":"5f8e7a3c-1b2d-4e6f-9a0b-c1d2e3f4a5b6"}`,
+			mockGetSessionError: errors.New("mock get session error"),
+			statusCode:          http.StatusInternalServerError,
+			contentType:         "application/json",
+			responseBody:        `{"error":"mock get session error"}`,
+		},
+		{
+			description:            "error storing question",
+			method:                 http.MethodPost,
+			body:                   `{"question":"mock_question"}`,
+			mockStoreQuestionError: errors.New("mock store question error"),
+			statusCode:             http.StatusInternalServerError,
+			contentType:            "application/json",
+			responseBody:           `{"error":"mock store question error"}`,
+		},
+		{
+			description: "error from rate limited provider before first token",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockStreamAnswerError: &nlp.APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "rate_limit_exceeded",
+				Message:    "mock rate limit",
+				RetryAfter: 3 * time.Second,
+			},
+			statusCode:   http.StatusTooManyRequests,
+			contentType:  "application/json",
+			retryAfter:   "3",
+			responseBody: `{"error":"nlp: 429 rate_limit_exceeded: mock rate limit"}`,
+		},
+		{
+			description: "error from unavailable provider before first token",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockStreamAnswerError: &nlp.APIError{
+				StatusCode: http.StatusBadGateway,
+				Message:    "mock bad gateway",
+			},
+			statusCode:   http.StatusServiceUnavailable,
+			contentType:  "application/json",
+			responseBody: `{"error":"nlp: 502: mock bad gateway"}`,
+		},
+		{
+			description:           "error from monthly budget before first token",
+			method:                http.MethodPost,
+			body:                  `{"question":"mock_question"}`,
+			mockStreamAnswerError: bgt.ErrThrottled,
+			statusCode:            http.StatusServiceUnavailable,
+			contentType:           "application/json",
+			responseBody:          `{"error":"service throttled","code":"SERVICE_THROTTLED"}`,
+		},
+		{
+			description:           "error from deadline before first token",
+			method:                http.MethodPost,
+			body:                  `{"question":"mock_question"}`,
+			mockStreamAnswerError: fmt.Errorf("mock stream answer: %w", context.DeadlineExceeded),
+			statusCode:            http.StatusGatewayTimeout,
+			contentType:           "application/json",
+			responseBody:          `{"error":"request timed out"}`,
+		},
+		{
+			description: "error streaming answer",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockTokens: []string{
+				" mock",
+			},
+			mockStreamAnswerError: errors.New("mock stream answer error"),
+			statusCode:            http.StatusOK,
+			contentType:           "text/event-stream",
+			responseBody: "event: token\ndata: {\"text\":\" mock\"}\n\n" +
+				"event: error\ndata: {\"error\":\"mock stream answer error\"}\n\n",
+		},
+		{
+			description: "error getting citations",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockTokens: []string{
+				" mock",
+			},
+			mockStreamAnswerOutput:     mockAnswer(),
+			mockGetSummariesByIDsError: errors.New("mock get summaries by ids error"),
+			statusCode:                 http.StatusOK,
+			contentType:                "text/event-stream",
+			responseBody: "event: token\ndata: {\"text\":\" mock\"}\n\n" +
+				"event: error\ndata: {\"error\":\"mock get summaries by ids error\"}\n\n",
+		},
+		{
+			description: "error storing answer",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockTokens: []string{
+				" mock",
+			},
+			mockStreamAnswerOutput: mockAnswer(),
+			mockStoreAnwerError:    errors.New("mock store answer error"),
+			statusCode:             http.StatusOK,
+			contentType:            "text/event-stream",
+			responseBody: "event: token\ndata: {\"text\":\" mock\"}\n\n" +
+				"event: error\ndata: {\"error\":\"mock store answer error\"}\n\n",
+		},
+		{
+			description: "successful invocation",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockTokens: []string{
+				" mock",
+				" answer",
+			},
+			mockStreamAnswerOutput: mockAnswer(),
+			mockGetSummariesByIDsOutput: []db.Summary{
+				{
+					ID:    "mock_id",
+					URL:   "mock_url",
+					Title: "mock_title",
+				},
+			},
+			statusCode:  http.StatusOK,
+			contentType: "text/event-stream",
+			responseBody: "event: token\ndata: {\"text\":\" mock\"}\n\n" +
+				"event: token\ndata: {\"text\":\" answer\"}\n\n" +
+				"event: answer\ndata: {\"question_id\":\"\",\"answer\":\"Mock answer.\",\"citations\":[{\"id\":\"mock_id\",\"title\":\"mock_title\",\"url\":\"mock_url\",\"excerpt\":\"mock excerpt\"}],\"cached\":false}\n\n",
+		},
+		{
+			description: "successful invocation with session",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question","session_id":"5f8e7a3c-1b2d-4e6f-9a0b-c1d2e3f4a5b6"}`,
+			mockGetSessionOutput: &db.Session{
+				ID: "5f8e7a3c-1b2d-4e6f-9a0b-c1d2e3f4a5b6",
+				Turns: []nlp.Turn{
+					{
+						Question: "earlier question",
+						Answer:   "Earlier answer.",
+					},
+				},
+			},
+			mockStreamAnswerOutput: &nlp.Answer{
+				Text: "Mock answer.",
+			},
+			statusCode:   http.StatusOK,
+			contentType:  "text/event-stream",
+			responseBody: "event: answer\ndata: {\"question_id\":\"\",\"answer\":\"Mock answer.\",\"citations\":[],\"cached\":false}\n\n",
+			history: []nlp.Turn{
+				{
+					Question: "earlier question",
+					Answer:   "Earlier answer.",
+				},
+			},
+			turns: []nlp.Turn{
+				{
+					Question: "earlier question",
+					Answer:   "Earlier answer.",
+				},
+				{
+					Question: "mock_question",
+					Answer:   "Mock answer.",
+				},
+			},
+		},
+		{
+			description: "successful invocation with mode",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question","mode":"quotes"}`,
+			mockStreamAnswerOutput: &nlp.Answer{
+				Text: "\"Mock answer.\"",
+				Mode: nlp.QuotesMode,
+			},
+			statusCode:   http.StatusOK,
+			contentType:  "text/event-stream",
+			responseBody: "event: answer\ndata: {\"question_id\":\"\",\"answer\":\"\\\"Mock answer.\\\"\",\"citations\":[],\"cached\":false}\n\n",
+			mode:         nlp.QuotesMode,
+		},
+		{
+			description: "successful invocation with ungrounded answer",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockStreamAnswerOutput: &nlp.Answer{
+				Text: "Mock answer.",
+				Grounding: &nlp.Grounding{
+					Score:   0,
+					Flagged: true,
+					Claims:  []nlp.Claim{},
+				},
+			},
+			statusCode:   http.StatusOK,
+			contentType:  "text/event-stream",
+			responseBody: "event: answer\ndata: {\"question_id\":\"\",\"answer\":\"Mock answer.\",\"citations\":[],\"cached\":false,\"grounding\":{\"score\":0,\"flagged\":true,\"claims\":[]}}\n\n",
+		},
+		{
+			description:               "error storing moderations",
+			method:                    http.MethodPost,
+			body:                      `{"question":"mock_question"}`,
+			mockStreamAnswerOutput:    mockRefusal(),
+			mockStoreModerationsError: errors.New("mock store moderations error"),
+			statusCode:                http.StatusInternalServerError,
+			contentType:               "application/json",
+			responseBody:              `{"error":"mock store moderations error"}`,
+		},
+		{
+			description: "successful invocation with refused answer",
+			method:      http.MethodPost,
+			body:        `{"question":"mock_question"}`,
+			mockTokens: []string{
+				" mock",
+			},
+			mockStreamAnswerOutput: mockRefusal(),
+			statusCode:             http.StatusOK,
+			contentType:            "text/event-stream",
+			responseBody: "event: token\ndata: {\"text\":\" mock\"}\n\n" +
+				"event: answer\ndata: {\"question_id\":\"\",\"answer\":\"Mock refusal.\",\"citations\":[],\"cached\":false,\"refusal\":{\"target\":\"answer\",\"categories\":[\"violence\"]}}\n\n",
+		},
+		{
+			description:            "successful invocation without answer",
+			method:                 http.MethodPost,
+			body:                   `{"question":"mock_question"}`,
+			mockStreamAnswerOutput: &nlp.Answer{},
+			statusCode:             http.StatusOK,
+			contentType:            "text/event-stream",
+			responseBody:           "event: answer\ndata: {\"question_id\":\"\",\"answer\":\"\",\"citations\":[],\"cached\":false}\n\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			d := &mockDBClient{
+				mockGetSummariesByIDsOutput: test.mockGetSummariesByIDsOutput,
+				mockGetSummariesByIDsError:  test.mockGetSummariesByIDsError,
+				mockStoreQuestionError:      test.mockStoreQuestionError,
+				mockStoreAnwerError:         test.mockStoreAnwerError,
+				mockStoreModerationsError:   test.mockStoreModerationsError,
+				mockGetSessionOutput:        test.mockGetSessionOutput,
+				mockGetSessionError:         test.mockGetSessionError,
+			}
+
+			n := &mockNLPClient{
+				mockTokens:             test.mockTokens,
+				mockStreamAnswerOutput: test.mockStreamAnswerOutput,
+				mockStreamAnswerError:  test.mockStreamAnswerError,
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(test.method, "/question", strings.NewReader(test.body))
+
+			handler(d, n, time.Hour).ServeHTTP(recorder, request)
+
+			if recorder.Code != test.statusCode {
+				t.Errorf("incorrect status code, received: %d, expected: %d", recorder.Code, test.statusCode)
+			}
+
+			if contentType := recorder.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Errorf("incorrect content type, received: %s, expected: %s", contentType, test.contentType)
+			}
+
+			if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != test.retryAfter {
+				t.Errorf("incorrect retry after, received: %s, expected: %s", retryAfter, test.retryAfter)
+			}
+
+			body := questionIDRegexp.ReplaceAllString(recorder.Body.String(), `"question_id":""`)
+			if body != test.responseBody {
+				t.Errorf("incorrect body, received: %q, expected: %q", body, test.responseBody)
+			}
+
+			if !reflect.DeepEqual(n.history, test.history) {
+				t.Errorf("incorrect history, received: %+v, expected: %+v", n.history, test.history)
+			}
+
+			if test.mode != "" && n.mode != test.mode {
+				t.Errorf("incorrect mode, received: %s, expected: %s", n.mode, test.mode)
+			}
+
+			turns := []nlp.Turn(nil)
+			if len(d.storedSessions) > 0 {
+				turns = d.storedSessions[0].Turns
+			}
+			if !reflect.DeepEqual(turns, test.turns) {
+				t.Errorf("incorrect session turns, received: %+v, expected: %+v", turns, test.turns)
+			}
+		})
+	}
+}
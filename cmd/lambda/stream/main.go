@@ -0,0 +1,99 @@
+//+build !test
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambdaurl"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/forstmeier/askpaulgraham/pkg/bgt"
+	"github.com/forstmeier/askpaulgraham/pkg/cch"
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/mdr"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+	"github.com/forstmeier/askpaulgraham/util"
+)
+
+// The stream function serves answers as server-sent events from
+// a Lambda function URL with response streaming or, when run
+// outside of Lambda, from a plain net/http server on PORT.
+func main() {
+	newSession, err := session.NewSession()
+	if err != nil {
+		panic(fmt.Sprintf("error creating session: %v", err))
+	}
+
+	dbClient := db.New(
+		newSession,
+		os.Getenv("DATA_BUCKET_NAME"),
+		db.Tables{
+			Questions: os.Getenv("QUESTIONS_TABLE_NAME"),
+			Summaries: os.Getenv("SUMMARIES_TABLE_NAME"),
+			Cache:     os.Getenv("CACHE_TABLE_NAME"),
+			Spend:     os.Getenv("SPEND_TABLE_NAME"),
+			Sessions:  os.Getenv("SESSIONS_TABLE_NAME"),
+		},
+	)
+
+	provider, err := util.GetEnvProvider()
+	if err != nil {
+		panic(fmt.Sprintf("error getting provider: %v", err))
+	}
+
+	retrieval, err := util.GetEnvRetrieval()
+	if err != nil {
+		panic(fmt.Sprintf("error getting retrieval: %v", err))
+	}
+
+	cacheThreshold, err := util.GetEnvCacheThreshold()
+	if err != nil {
+		panic(fmt.Sprintf("error getting cache threshold: %v", err))
+	}
+
+	budget, err := util.GetEnvBudget()
+	if err != nil {
+		panic(fmt.Sprintf("error getting budget: %v", err))
+	}
+
+	sessionTTL, err := util.GetEnvSessionTTL()
+	if err != nil {
+		panic(fmt.Sprintf("error getting session ttl: %v", err))
+	}
+
+	nlpClient := mdr.New(
+		cch.New(
+			dbClient,
+			bgt.New(
+				dbClient,
+				nlp.New(
+					newSession,
+					*provider,
+					os.Getenv("DATA_BUCKET_NAME"),
+					*retrieval,
+				),
+				*budget,
+				util.Log,
+			),
+			cacheThreshold,
+		),
+	)
+
+	h := handler(dbClient, nlpClient, sessionTTL)
+
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		lambdaurl.Start(h)
+		return
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	log.Fatal(http.ListenAndServe(":"+port, h))
+}
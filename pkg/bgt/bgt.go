@@ -0,0 +1,36 @@
+// Package bgt keeps the LLM provider spend within a monthly
+// budget by switching answers to a cheaper model past a soft
+// threshold and refusing them once the budget is spent.
+package bgt
+
+import "errors"
+
+// DefaultSoftThreshold is the fraction of the monthly budget
+// after which answers are generated with the fallback model.
+const DefaultSoftThreshold = 0.8
+
+// ThrottledCode is the error code sent with ErrThrottled so that
+// clients can tell a spent budget apart from other errors.
+const ThrottledCode = "SERVICE_THROTTLED"
+
+// ErrThrottled is returned in place of answers once the monthly
+// budget is spent.
+var ErrThrottled = errors.New("service throttled")
+
+// Budget configures the monthly LLM provider spend in US dollars.
+//
+// A zero Limit disables the budget, SoftThreshold defaults to
+// DefaultSoftThreshold when left at zero, and answers past the
+// soft threshold keep the Provider AnswersModel when
+// FallbackModel is empty.
+//
+// UnknownModelPrice is the price in US dollars per 1K tokens of
+// models missing from the nlp price table. When it is left at
+// zero the usage of such models spends the whole budget so that
+// unpriced usage throttles answers instead of going uncounted.
+type Budget struct {
+	Limit             float64
+	SoftThreshold     float64
+	FallbackModel     string
+	UnknownModelPrice float64
+}
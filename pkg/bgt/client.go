@@ -0,0 +1,232 @@
+package bgt
+
+import (
+	"context"
+	"time"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+const monthLayout = "2006-01"
+
+// spendTimeout bounds the spend write which is not bound by the
+// deadline of the request it is recorded for.
+const spendTimeout = 2 * time.Second
+
+var _ nlp.NLPer = &Client{}
+
+// Client implements the nlp.NLPer interface by adding the cost of
+// the wrapped NLPer requests to the monthly spend counter in the
+// "spend" table and checking it before answering questions.
+//
+// Answers and embeddings are refused once the budget is spent so
+// that throttled questions, including the embedding used to find
+// similar cached answers, cost nothing. Answers cached for the
+// same normalized question, searches, and quotes are still
+// available.
+type Client struct {
+	dbClient  db.Databaser
+	nlpClient nlp.NLPer
+	budget    Budget
+	log       func(key string, value interface{})
+	now       func() time.Time
+}
+
+// New generates a pointer instance of Client.
+//
+// The log argument receives the spend errors and the models
+// without a known price, such as util.Log.
+func New(dbClient db.Databaser, nlpClient nlp.NLPer, budget Budget, log func(key string, value interface{})) *Client {
+	if budget.SoftThreshold == 0 {
+		budget.SoftThreshold = DefaultSoftThreshold
+	}
+
+	return &Client{
+		dbClient:  dbClient,
+		nlpClient: nlpClient,
+		budget:    budget,
+		log:       log,
+		now:       time.Now,
+	}
+}
+
+// GetSummary implements the nlp.NLPer.GetSummary method with the
+// wrapped NLPer.
+func (c *Client) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
+	ctx, recorder := nlp.WithUsageRecorder(ctx)
+	defer c.addSpend(recorder)
+
+	return c.nlpClient.GetSummary(ctx, text)
+}
+
+// SetDocuments implements the nlp.NLPer.SetDocuments method with
+// the wrapped NLPer.
+func (c *Client) SetDocuments(ctx context.Context, documents []dct.Document) error {
+	ctx, recorder := nlp.WithUsageRecorder(ctx)
+	defer c.addSpend(recorder)
+
+	return c.nlpClient.SetDocuments(ctx, documents)
+}
+
+// GetAnswer implements the nlp.NLPer.GetAnswer method and returns
+// ErrThrottled without calling the wrapped NLPer once the monthly
+// budget is spent.
+func (c *Client) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	ctx, err := c.checkBudget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, recorder := nlp.WithUsageRecorder(ctx)
+	defer c.addSpend(recorder)
+
+	return c.nlpClient.GetAnswer(ctx, question, userID)
+}
+
+// StreamAnswer implements the nlp.NLPer.StreamAnswer method and
+// returns ErrThrottled without calling the wrapped NLPer once the
+// monthly budget is spent.
+func (c *Client) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	ctx, err := c.checkBudget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, recorder := nlp.WithUsageRecorder(ctx)
+	defer c.addSpend(recorder)
+
+	return c.nlpClient.StreamAnswer(ctx, question, userID, send)
+}
+
+// SearchDocuments implements the nlp.NLPer.SearchDocuments method
+// with the wrapped NLPer.
+func (c *Client) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	ctx, recorder := nlp.WithUsageRecorder(ctx)
+	defer c.addSpend(recorder)
+
+	return c.nlpClient.SearchDocuments(ctx, query)
+}
+
+// GetQuotes implements the nlp.NLPer.GetQuotes method with the
+// wrapped NLPer and is available once the monthly budget is spent.
+func (c *Client) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return c.nlpClient.GetQuotes(ctx, question)
+}
+
+// GetTopic implements the nlp.NLPer.GetTopic method with the
+// wrapped NLPer.
+func (c *Client) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	ctx, recorder := nlp.WithUsageRecorder(ctx)
+	defer c.addSpend(recorder)
+
+	return c.nlpClient.GetTopic(ctx, titles, terms)
+}
+
+// GetEmbedding implements the nlp.NLPer.GetEmbedding method and
+// returns ErrThrottled without calling the wrapped NLPer once the
+// monthly budget is spent.
+func (c *Client) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	if _, err := c.checkBudget(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, recorder := nlp.WithUsageRecorder(ctx)
+	defer c.addSpend(recorder)
+
+	return c.nlpClient.GetEmbedding(ctx, text)
+}
+
+// ModerateQuestion implements the nlp.NLPer.ModerateQuestion
+// method with the wrapped NLPer and is available once the monthly
+// budget is spent.
+func (c *Client) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return c.nlpClient.ModerateQuestion(ctx, question)
+}
+
+// GetPromptVersion implements the nlp.NLPer.GetPromptVersion
+// method with the wrapped NLPer and is available once the monthly
+// budget is spent.
+func (c *Client) GetPromptVersion(ctx context.Context) (string, error) {
+	return c.nlpClient.GetPromptVersion(ctx)
+}
+
+// checkBudget returns ErrThrottled when the spend of the current
+// month has reached the budget and otherwise a context selecting
+// the fallback model when it has reached the soft threshold.
+func (c *Client) checkBudget(ctx context.Context) (context.Context, error) {
+	if c.budget.Limit <= 0 {
+		return ctx, nil
+	}
+
+	spend, err := c.dbClient.GetSpend(ctx, c.month())
+	if err != nil {
+		return nil, err
+	}
+
+	if spend >= c.budget.Limit {
+		return nil, ErrThrottled
+	}
+
+	if spend >= c.budget.Limit*c.budget.SoftThreshold && c.budget.FallbackModel != "" {
+		return nlp.WithAnswersModel(ctx, c.budget.FallbackModel), nil
+	}
+
+	return ctx, nil
+}
+
+// addSpend adds the cost of the recorded usage to the spend of
+// the current month.
+//
+// The spend is written with a context detached from the request
+// so that requests which ran out of their deadline budget are
+// still counted, and errors are only logged since the request
+// has already been paid for.
+func (c *Client) addSpend(recorder *nlp.UsageRecorder) {
+	if c.budget.Limit <= 0 {
+		return
+	}
+
+	amount := 0.0
+	for _, usage := range recorder.Usage() {
+		amount += c.getCost(usage)
+	}
+
+	if amount == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), spendTimeout)
+	defer cancel()
+
+	if _, err := c.dbClient.AddSpend(ctx, c.month(), amount); err != nil {
+		c.log("ADD_SPEND_ERROR", err.Error())
+	}
+}
+
+// getCost returns the cost of the usage with the
+// UnknownModelPrice for models without a known price or the
+// whole budget when there is no UnknownModelPrice.
+func (c *Client) getCost(usage nlp.Usage) float64 {
+	if cost, ok := usage.Cost(); ok {
+		return cost
+	}
+
+	tokens := usage.PromptTokens + usage.CompletionTokens
+	if tokens == 0 {
+		return 0
+	}
+
+	if c.budget.UnknownModelPrice > 0 {
+		return float64(tokens) / 1000 * c.budget.UnknownModelPrice
+	}
+
+	c.log("UNKNOWN_MODEL_PRICE", usage.Model)
+
+	return c.budget.Limit
+}
+
+func (c *Client) month() string {
+	return c.now().UTC().Format(monthLayout)
+}
@@ -0,0 +1,588 @@
+package bgt
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+type mockDBClient struct {
+	mockGetSpendOutput float64
+	mockGetSpendError  error
+	mockAddSpendError  error
+	months             []string
+	amounts            []float64
+}
+
+func (m *mockDBClient) GetIDs(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummaries(ctx context.Context) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummariesByIDs(ctx context.Context, ids []string) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSummaries(ctx context.Context, summaries []db.Summary) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreRelated(ctx context.Context, id string, related []db.Related) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreText(ctx context.Context, id, text string) error {
+	return nil
+}
+
+func (m *mockDBClient) GetDocuments(ctx context.Context) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreQuestion(ctx context.Context, id, question string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	return nil
+}
+
+func (m *mockDBClient) GetCachedAnswer(ctx context.Context, question, mode string) (*db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetCachedAnswers(ctx context.Context) ([]db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreCachedAnswer(ctx context.Context, cachedAnswer db.CachedAnswer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreUsage(ctx context.Context, usage db.Usage) error {
+	return nil
+}
+
+func (m *mockDBClient) GetUsage(ctx context.Context) ([]db.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSpend(ctx context.Context, month string) (float64, error) {
+	m.months = append(m.months, month)
+	return m.mockGetSpendOutput, m.mockGetSpendError
+}
+
+func (m *mockDBClient) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if m.mockAddSpendError != nil {
+		return 0, m.mockAddSpendError
+	}
+
+	m.months = append(m.months, month)
+	m.amounts = append(m.amounts, amount)
+	return m.mockGetSpendOutput + amount, nil
+}
+
+func (m *mockDBClient) GetSession(ctx context.Context, id string) (*db.Session, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSession(ctx context.Context, session db.Session) error {
+	return nil
+}
+
+type mockLogger struct {
+	keys []string
+}
+
+func (m *mockLogger) log(key string, value interface{}) {
+	m.keys = append(m.keys, key)
+}
+
+func mockLog(key string, value interface{}) {}
+
+type mockNLPClient struct {
+	mockGetAnswerOutput *nlp.Answer
+	mockGetAnswerError  error
+	mockGetQuotesOutput []nlp.Citation
+	mockUsage           nlp.Usage
+	answers             int
+	model               string
+}
+
+func (m *mockNLPClient) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
+	nlp.RecordUsage(ctx, m.mockUsage)
+	return &nlp.Summary{
+		Text: text,
+	}, nil
+}
+
+func (m *mockNLPClient) SetDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockNLPClient) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	m.answers++
+	m.model, _ = nlp.GetAnswersModel(ctx)
+	nlp.RecordUsage(ctx, m.mockUsage)
+	return m.mockGetAnswerOutput, m.mockGetAnswerError
+}
+
+func (m *mockNLPClient) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	m.answers++
+	m.model, _ = nlp.GetAnswersModel(ctx)
+	nlp.RecordUsage(ctx, m.mockUsage)
+	return m.mockGetAnswerOutput, m.mockGetAnswerError
+}
+
+func (m *mockNLPClient) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return m.mockGetQuotesOutput, nil
+}
+
+func (m *mockNLPClient) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	nlp.RecordUsage(ctx, m.mockUsage)
+	return nil, nil
+}
+
+func (m *mockNLPClient) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetPromptVersion(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func TestNew(t *testing.T) {
+	client := New(&mockDBClient{}, &mockNLPClient{}, Budget{}, mockLog)
+	if client.budget.SoftThreshold != DefaultSoftThreshold {
+		t.Errorf("incorrect soft threshold, received: %f, expected: %f", client.budget.SoftThreshold, DefaultSoftThreshold)
+	}
+}
+
+func TestGetSummary(t *testing.T) {
+	dbClient := &mockDBClient{}
+	c := New(dbClient, &mockNLPClient{
+		mockUsage: nlp.Usage{
+			Model:        "text-embedding-3-small",
+			PromptTokens: 1000000,
+		},
+	}, Budget{
+		Limit: 10,
+	}, mockLog)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.GetSummary(ctx, "text"); err != nil {
+		t.Errorf("incorrect error, received: %v, expected: %v", err, nil)
+	}
+
+	expected := []float64{0.02}
+	if !reflect.DeepEqual(dbClient.amounts, expected) {
+		t.Errorf("incorrect amounts, received: %v, expected: %v", dbClient.amounts, expected)
+	}
+}
+
+func TestGetAnswer(t *testing.T) {
+	getSpendErr := errors.New("mock get spend error")
+	getAnswerErr := errors.New("mock get answer error")
+
+	mockAnswer := &nlp.Answer{
+		Text: "Answer.",
+	}
+
+	mockUsage := nlp.Usage{
+		Model:            "gpt-4o-mini",
+		PromptTokens:     1000000,
+		CompletionTokens: 0,
+	}
+
+	tests := []struct {
+		description         string
+		budget              Budget
+		mockGetSpendOutput  float64
+		mockGetSpendError   error
+		mockGetAnswerOutput *nlp.Answer
+		mockGetAnswerError  error
+		answers             int
+		model               string
+		amounts             []float64
+		answer              *nlp.Answer
+		error               error
+	}{
+		{
+			description: "budget disabled",
+			budget: Budget{
+				Limit:         0,
+				FallbackModel: "gpt-4o-mini",
+			},
+			mockGetSpendOutput:  1000,
+			mockGetAnswerOutput: mockAnswer,
+			answers:             1,
+			model:               "",
+			amounts:             nil,
+			answer:              mockAnswer,
+			error:               nil,
+		},
+		{
+			description: "error getting spend",
+			budget: Budget{
+				Limit: 10,
+			},
+			mockGetSpendError: getSpendErr,
+			answers:           0,
+			amounts:           nil,
+			answer:            nil,
+			error:             getSpendErr,
+		},
+		{
+			description: "budget spent",
+			budget: Budget{
+				Limit: 10,
+			},
+			mockGetSpendOutput: 10,
+			answers:            0,
+			amounts:            nil,
+			answer:             nil,
+			error:              ErrThrottled,
+		},
+		{
+			description: "soft threshold reached with fallback model",
+			budget: Budget{
+				Limit:         10,
+				FallbackModel: "gpt-4o-mini",
+			},
+			mockGetSpendOutput:  8,
+			mockGetAnswerOutput: mockAnswer,
+			answers:             1,
+			model:               "gpt-4o-mini",
+			amounts:             []float64{0.15},
+			answer:              mockAnswer,
+			error:               nil,
+		},
+		{
+			description: "soft threshold reached without fallback model",
+			budget: Budget{
+				Limit: 10,
+			},
+			mockGetSpendOutput:  8,
+			mockGetAnswerOutput: mockAnswer,
+			answers:             1,
+			model:               "",
+			amounts:             []float64{0.15},
+			answer:              mockAnswer,
+			error:               nil,
+		},
+		{
+			description: "error getting answer spend added",
+			budget: Budget{
+				Limit:         10,
+				FallbackModel: "gpt-4o-mini",
+			},
+			mockGetSpendOutput: 1,
+			mockGetAnswerError: getAnswerErr,
+			answers:            1,
+			model:              "",
+			amounts:            []float64{0.15},
+			answer:             nil,
+			error:              getAnswerErr,
+		},
+		{
+			description: "successful invocation",
+			budget: Budget{
+				Limit:         10,
+				FallbackModel: "gpt-4o-mini",
+			},
+			mockGetSpendOutput:  1,
+			mockGetAnswerOutput: mockAnswer,
+			answers:             1,
+			model:               "",
+			amounts:             []float64{0.15},
+			answer:              mockAnswer,
+			error:               nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockGetSpendOutput: test.mockGetSpendOutput,
+				mockGetSpendError:  test.mockGetSpendError,
+			}
+			nlpClient := &mockNLPClient{
+				mockGetAnswerOutput: test.mockGetAnswerOutput,
+				mockGetAnswerError:  test.mockGetAnswerError,
+				mockUsage:           mockUsage,
+			}
+
+			c := New(dbClient, nlpClient, test.budget, mockLog)
+			c.now = func() time.Time {
+				return time.Date(2023, 4, 30, 23, 0, 0, 0, time.UTC)
+			}
+
+			answer, err := c.GetAnswer(context.Background(), "question", "userID")
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if answer != test.answer {
+				t.Errorf("incorrect answer, received: %+v, expected: %+v", answer, test.answer)
+			}
+
+			if nlpClient.answers != test.answers {
+				t.Errorf("incorrect answers, received: %d, expected: %d", nlpClient.answers, test.answers)
+			}
+
+			if nlpClient.model != test.model {
+				t.Errorf("incorrect model, received: %s, expected: %s", nlpClient.model, test.model)
+			}
+
+			if len(dbClient.amounts) != len(test.amounts) {
+				t.Fatalf("incorrect amounts, received: %v, expected: %v", dbClient.amounts, test.amounts)
+			}
+			for i := range test.amounts {
+				if math.Abs(dbClient.amounts[i]-test.amounts[i]) > 1e-9 {
+					t.Errorf("incorrect amount, received: %f, expected: %f", dbClient.amounts[i], test.amounts[i])
+				}
+			}
+
+			for _, month := range dbClient.months {
+				if month != "2023-04" {
+					t.Errorf("incorrect month, received: %s, expected: %s", month, "2023-04")
+				}
+			}
+		})
+	}
+}
+
+func TestStreamAnswer(t *testing.T) {
+	nlpClient := &mockNLPClient{}
+	c := New(&mockDBClient{
+		mockGetSpendOutput: 10,
+	}, nlpClient, Budget{
+		Limit: 10,
+	}, mockLog)
+
+	answer, err := c.StreamAnswer(context.Background(), "question", "userID", func(token string) error {
+		return nil
+	})
+
+	if err != ErrThrottled {
+		t.Errorf("incorrect error, received: %v, expected: %v", err, ErrThrottled)
+	}
+
+	if answer != nil || nlpClient.answers != 0 {
+		t.Errorf("incorrect answer, received: %+v, answers: %d", answer, nlpClient.answers)
+	}
+}
+
+func TestGetQuotes(t *testing.T) {
+	quotes := []nlp.Citation{
+		{
+			ID:      "mock_id",
+			Excerpt: "Mock quote.",
+		},
+	}
+
+	c := New(&mockDBClient{
+		mockGetSpendOutput: 10,
+	}, &mockNLPClient{
+		mockGetQuotesOutput: quotes,
+	}, Budget{
+		Limit: 10,
+	}, mockLog)
+
+	received, err := c.GetQuotes(context.Background(), "question")
+	if err != nil {
+		t.Errorf("incorrect error, received: %v, expected: %v", err, nil)
+	}
+
+	if !reflect.DeepEqual(received, quotes) {
+		t.Errorf("incorrect quotes, received: %+v, expected: %+v", received, quotes)
+	}
+}
+
+func TestGetEmbedding(t *testing.T) {
+	tests := []struct {
+		description        string
+		mockGetSpendOutput float64
+		amounts            []float64
+		error              error
+	}{
+		{
+			description:        "budget spent",
+			mockGetSpendOutput: 10,
+			amounts:            nil,
+			error:              ErrThrottled,
+		},
+		{
+			description:        "successful invocation",
+			mockGetSpendOutput: 5,
+			amounts:            []float64{0.02},
+			error:              nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockGetSpendOutput: test.mockGetSpendOutput,
+			}
+			c := New(dbClient, &mockNLPClient{
+				mockUsage: nlp.Usage{
+					Model:        "text-embedding-3-small",
+					PromptTokens: 1000000,
+				},
+			}, Budget{
+				Limit: 10,
+			}, mockLog)
+
+			if _, err := c.GetEmbedding(context.Background(), "text"); err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(dbClient.amounts, test.amounts) {
+				t.Errorf("incorrect amounts, received: %v, expected: %v", dbClient.amounts, test.amounts)
+			}
+		})
+	}
+}
+
+func Test_addSpend(t *testing.T) {
+	addSpendErr := errors.New("mock add spend error")
+
+	tests := []struct {
+		description       string
+		budget            Budget
+		usage             nlp.Usage
+		mockAddSpendError error
+		amounts           []float64
+		keys              []string
+	}{
+		{
+			description: "known model price",
+			budget: Budget{
+				Limit: 10,
+			},
+			usage: nlp.Usage{
+				Model:        "gpt-4o-mini",
+				PromptTokens: 1000000,
+			},
+			amounts: []float64{0.15},
+			keys:    nil,
+		},
+		{
+			description: "unknown model with unknown model price",
+			budget: Budget{
+				Limit:             10,
+				UnknownModelPrice: 0.03,
+			},
+			usage: nlp.Usage{
+				Model:            "custom-model",
+				PromptTokens:     1500,
+				CompletionTokens: 500,
+			},
+			amounts: []float64{0.06},
+			keys:    nil,
+		},
+		{
+			description: "unknown model without unknown model price",
+			budget: Budget{
+				Limit: 10,
+			},
+			usage: nlp.Usage{
+				Model:        "custom-model",
+				PromptTokens: 1,
+			},
+			amounts: []float64{10},
+			keys:    []string{"UNKNOWN_MODEL_PRICE"},
+		},
+		{
+			description: "unknown model without tokens",
+			budget: Budget{
+				Limit: 10,
+			},
+			usage: nlp.Usage{
+				Model: "custom-model",
+			},
+			amounts: nil,
+			keys:    nil,
+		},
+		{
+			description: "error adding spend",
+			budget: Budget{
+				Limit: 10,
+			},
+			usage: nlp.Usage{
+				Model:        "gpt-4o-mini",
+				PromptTokens: 1000000,
+			},
+			mockAddSpendError: addSpendErr,
+			amounts:           nil,
+			keys:              []string{"ADD_SPEND_ERROR"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockAddSpendError: test.mockAddSpendError,
+			}
+			logger := &mockLogger{}
+
+			c := New(dbClient, &mockNLPClient{}, test.budget, logger.log)
+
+			ctx, recorder := nlp.WithUsageRecorder(context.Background())
+			nlp.RecordUsage(ctx, test.usage)
+
+			c.addSpend(recorder)
+
+			if len(dbClient.amounts) != len(test.amounts) {
+				t.Fatalf("incorrect amounts, received: %v, expected: %v", dbClient.amounts, test.amounts)
+			}
+			for i := range test.amounts {
+				if math.Abs(dbClient.amounts[i]-test.amounts[i]) > 1e-9 {
+					t.Errorf("incorrect amount, received: %f, expected: %f", dbClient.amounts[i], test.amounts[i])
+				}
+			}
+
+			if !reflect.DeepEqual(logger.keys, test.keys) {
+				t.Errorf("incorrect log keys, received: %v, expected: %v", logger.keys, test.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+// Package cch answers repeated questions from earlier answers so
+// that the LLM is only called for new questions.
+package cch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize returns the cache key of the provided question with
+// case, punctuation, and extra whitespace removed so that
+// "Why does YC hate solo founders?" and " why does yc hate solo
+// founders" share an answer.
+func Normalize(question string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	}), " ")
+}
@@ -0,0 +1,40 @@
+package cch
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		description string
+		question    string
+		key         string
+	}{
+		{
+			description: "empty question",
+			question:    "",
+			key:         "",
+		},
+		{
+			description: "punctuation only",
+			question:    " ?! ",
+			key:         "",
+		},
+		{
+			description: "case, punctuation, and whitespace",
+			question:    "  Why does YC   hate solo founders?",
+			key:         "why does yc hate solo founders",
+		},
+		{
+			description: "contractions kept",
+			question:    "What's the best startup idea?",
+			key:         "what's the best startup idea",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if key := Normalize(test.question); key != test.key {
+				t.Errorf("incorrect key, received: %q, expected: %q", key, test.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package cch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+// DefaultThreshold is the cosine similarity of the question
+// embeddings above which a cached answer is reused.
+const DefaultThreshold = 0.95
+
+// refreshInterval is how long the cached answers loaded for
+// similarity matching are kept before being loaded again to pick
+// up answers cached by other instances.
+const refreshInterval = 10 * time.Minute
+
+var _ nlp.NLPer = &Client{}
+
+// Client implements the nlp.NLPer interface by answering
+// questions from the answers cached in the "cache" table,
+// first by normalized question and then by embedding similarity,
+// and otherwise with the wrapped NLPer.
+type Client struct {
+	dbClient  db.Databaser
+	nlpClient nlp.NLPer
+	threshold float64
+
+	mutex         sync.Mutex
+	cachedAnswers []db.CachedAnswer
+	loaded        time.Time
+}
+
+// New generates a pointer instance of Client.
+//
+// The threshold argument defaults to DefaultThreshold when left
+// at zero and a threshold over 1 only reuses answers to the same
+// normalized question.
+func New(dbClient db.Databaser, nlpClient nlp.NLPer, threshold float64) *Client {
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+
+	return &Client{
+		dbClient:  dbClient,
+		nlpClient: nlpClient,
+		threshold: threshold,
+	}
+}
+
+// GetSummary implements the nlp.NLPer.GetSummary method with the
+// wrapped NLPer.
+func (c *Client) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
+	return c.nlpClient.GetSummary(ctx, text)
+}
+
+// SetDocuments implements the nlp.NLPer.SetDocuments method with
+// the wrapped NLPer.
+func (c *Client) SetDocuments(ctx context.Context, documents []dct.Document) error {
+	return c.nlpClient.SetDocuments(ctx, documents)
+}
+
+// GetAnswer implements the nlp.NLPer.GetAnswer method and
+// returns a cached answer to the question when there is one
+// without calling the wrapped NLPer.
+//
+// Answers are cached separately for each answer mode and only
+// answers generated with the current prompt version are reused
+// so that changed prompts are not masked by the cache. Follow-up
+// questions with a conversation history set on the context depend
+// on that history and are neither answered from nor added to the
+// cache.
+func (c *Client) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	if len(nlp.GetHistory(ctx)) > 0 {
+		return c.nlpClient.GetAnswer(ctx, question, userID)
+	}
+
+	key := Normalize(question)
+	answer, embedding, err := c.getCachedAnswer(ctx, key, nlp.GetMode(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if answer != nil {
+		return answer, nil
+	}
+
+	answer, err = c.nlpClient.GetAnswer(ctx, question, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.storeAnswer(ctx, key, embedding, answer)
+
+	return answer, nil
+}
+
+// StreamAnswer implements the nlp.NLPer.StreamAnswer method and
+// sends a cached answer to the question as a single token when
+// there is one without calling the wrapped NLPer. Follow-up
+// questions are handled as in GetAnswer.
+func (c *Client) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	if len(nlp.GetHistory(ctx)) > 0 {
+		return c.nlpClient.StreamAnswer(ctx, question, userID, send)
+	}
+
+	key := Normalize(question)
+	answer, embedding, err := c.getCachedAnswer(ctx, key, nlp.GetMode(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if answer != nil {
+		if err := send(answer.Text); err != nil {
+			return nil, err
+		}
+
+		return answer, nil
+	}
+
+	answer, err = c.nlpClient.StreamAnswer(ctx, question, userID, send)
+	if err != nil {
+		return nil, err
+	}
+
+	c.storeAnswer(ctx, key, embedding, answer)
+
+	return answer, nil
+}
+
+// SearchDocuments implements the nlp.NLPer.SearchDocuments
+// method with the wrapped NLPer.
+func (c *Client) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	return c.nlpClient.SearchDocuments(ctx, query)
+}
+
+// GetQuotes implements the nlp.NLPer.GetQuotes method with the
+// wrapped NLPer.
+func (c *Client) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return c.nlpClient.GetQuotes(ctx, question)
+}
+
+// GetTopic implements the nlp.NLPer.GetTopic method with the
+// wrapped NLPer.
+func (c *Client) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	return c.nlpClient.GetTopic(ctx, titles, terms)
+}
+
+// GetEmbedding implements the nlp.NLPer.GetEmbedding method with
+// the wrapped NLPer.
+func (c *Client) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return c.nlpClient.GetEmbedding(ctx, text)
+}
+
+// ModerateQuestion implements the nlp.NLPer.ModerateQuestion
+// method with the wrapped NLPer.
+func (c *Client) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return c.nlpClient.ModerateQuestion(ctx, question)
+}
+
+// GetPromptVersion implements the nlp.NLPer.GetPromptVersion
+// method with the wrapped NLPer.
+func (c *Client) GetPromptVersion(ctx context.Context) (string, error) {
+	return c.nlpClient.GetPromptVersion(ctx)
+}
+
+// getCachedAnswer returns the cached answer matching the
+// normalized question in the answer mode and the current prompt
+// version or nil along with the question embedding to store with
+// the new answer.
+func (c *Client) getCachedAnswer(ctx context.Context, key, mode string) (*nlp.Answer, []float64, error) {
+	if key == "" {
+		return nil, nil, nil
+	}
+
+	version, err := c.nlpClient.GetPromptVersion(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cachedAnswer, err := c.dbClient.GetCachedAnswer(ctx, key, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cachedAnswer != nil && cachedAnswer.Answer.PromptVersion == version {
+		return getAnswer(*cachedAnswer), nil, nil
+	}
+
+	if c.threshold > 1 {
+		return nil, nil, nil
+	}
+
+	embedding, err := c.nlpClient.GetEmbedding(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cachedAnswers, err := c.load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	best, bestSimilarity := -1, c.threshold
+	for i, cachedAnswer := range cachedAnswers {
+		if cachedAnswer.Answer.Mode != mode || cachedAnswer.Answer.PromptVersion != version {
+			continue
+		}
+
+		if similarity := nlp.CosineSimilarity(embedding, cachedAnswer.Embedding); similarity >= bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+
+	if best < 0 {
+		return nil, embedding, nil
+	}
+
+	return getAnswer(cachedAnswers[best]), embedding, nil
+}
+
+// storeAnswer caches the provided answer. Errors are ignored
+// since the answer has already been paid for and a missing cache
+// entry only costs a later LLM call. Refusals are not cached so
+// that every question is moderated and answers flagged as not
+// grounded in the essays are not cached so that they are not
+// repeated.
+func (c *Client) storeAnswer(ctx context.Context, key string, embedding []float64, answer *nlp.Answer) {
+	if key == "" || answer.Text == "" || answer.Refusal != nil {
+		return
+	}
+
+	if answer.Grounding != nil && answer.Grounding.Flagged {
+		return
+	}
+
+	cachedAnswer := db.CachedAnswer{
+		Question:  key,
+		Embedding: embedding,
+		Answer:    *answer,
+	}
+
+	if err := c.dbClient.StoreCachedAnswer(ctx, cachedAnswer); err != nil {
+		return
+	}
+
+	if embedding == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	if c.cachedAnswers != nil {
+		c.cachedAnswers = append(c.cachedAnswers, cachedAnswer)
+	}
+	c.mutex.Unlock()
+}
+
+func (c *Client) load(ctx context.Context) ([]db.CachedAnswer, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.cachedAnswers != nil && time.Since(c.loaded) < refreshInterval {
+		return c.cachedAnswers, nil
+	}
+
+	cachedAnswers, err := c.dbClient.GetCachedAnswers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cachedAnswers = cachedAnswers
+	c.loaded = time.Now()
+
+	return cachedAnswers, nil
+}
+
+// getAnswer copies the cached answer so that callers adding the
+// citation details do not modify the loaded cached answers.
+func getAnswer(cachedAnswer db.CachedAnswer) *nlp.Answer {
+	citations := make([]nlp.Citation, len(cachedAnswer.Answer.Citations))
+	copy(citations, cachedAnswer.Answer.Citations)
+
+	return &nlp.Answer{
+		Text:          cachedAnswer.Answer.Text,
+		Citations:     citations,
+		Cached:        true,
+		PromptVersion: cachedAnswer.Answer.PromptVersion,
+		Mode:          cachedAnswer.Answer.Mode,
+		Grounding:     cachedAnswer.Answer.Grounding,
+	}
+}
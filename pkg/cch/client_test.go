@@ -0,0 +1,581 @@
+package cch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+type mockDBClient struct {
+	mockGetCachedAnswerOutput  *db.CachedAnswer
+	mockGetCachedAnswerError   error
+	mockGetCachedAnswersOutput []db.CachedAnswer
+	mockGetCachedAnswersError  error
+	storedCachedAnswers        []db.CachedAnswer
+}
+
+func (m *mockDBClient) GetIDs(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummaries(ctx context.Context) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummariesByIDs(ctx context.Context, ids []string) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSummaries(ctx context.Context, summaries []db.Summary) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreRelated(ctx context.Context, id string, related []db.Related) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreText(ctx context.Context, id, text string) error {
+	return nil
+}
+
+func (m *mockDBClient) GetDocuments(ctx context.Context) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreQuestion(ctx context.Context, id, question string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	return nil
+}
+
+func (m *mockDBClient) GetCachedAnswer(ctx context.Context, question, mode string) (*db.CachedAnswer, error) {
+	return m.mockGetCachedAnswerOutput, m.mockGetCachedAnswerError
+}
+
+func (m *mockDBClient) GetCachedAnswers(ctx context.Context) ([]db.CachedAnswer, error) {
+	return m.mockGetCachedAnswersOutput, m.mockGetCachedAnswersError
+}
+
+func (m *mockDBClient) StoreCachedAnswer(ctx context.Context, cachedAnswer db.CachedAnswer) error {
+	m.storedCachedAnswers = append(m.storedCachedAnswers, cachedAnswer)
+	return nil
+}
+
+func (m *mockDBClient) StoreUsage(ctx context.Context, usage db.Usage) error {
+	return nil
+}
+
+func (m *mockDBClient) GetUsage(ctx context.Context) ([]db.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSpend(ctx context.Context, month string) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) GetSession(ctx context.Context, id string) (*db.Session, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSession(ctx context.Context, session db.Session) error {
+	return nil
+}
+
+type mockNLPClient struct {
+	mockGetAnswerOutput        *nlp.Answer
+	mockGetAnswerError         error
+	mockGetEmbeddingOutput     []float64
+	mockGetEmbeddingError      error
+	mockGetPromptVersionOutput string
+	mockGetPromptVersionError  error
+	answers                    int
+	embeddings                 int
+}
+
+func (m *mockNLPClient) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) SetDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockNLPClient) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	m.answers++
+	return m.mockGetAnswerOutput, m.mockGetAnswerError
+}
+
+func (m *mockNLPClient) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	m.answers++
+	if m.mockGetAnswerOutput != nil {
+		if err := send(m.mockGetAnswerOutput.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	return m.mockGetAnswerOutput, m.mockGetAnswerError
+}
+
+func (m *mockNLPClient) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	m.embeddings++
+	return m.mockGetEmbeddingOutput, m.mockGetEmbeddingError
+}
+
+func (m *mockNLPClient) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetPromptVersion(ctx context.Context) (string, error) {
+	return m.mockGetPromptVersionOutput, m.mockGetPromptVersionError
+}
+
+func mockCachedAnswer(question string, embedding []float64) db.CachedAnswer {
+	return db.CachedAnswer{
+		Question:  question,
+		Embedding: embedding,
+		Answer: nlp.Answer{
+			Text: "Cached answer.",
+			Citations: []nlp.Citation{
+				{
+					ID:      "mock_id",
+					Excerpt: "mock excerpt",
+				},
+			},
+			PromptVersion: "v1",
+			Mode:          nlp.DefaultMode,
+		},
+	}
+}
+
+func mockCachedAnswerVersion(question string, embedding []float64, version string) db.CachedAnswer {
+	cachedAnswer := mockCachedAnswer(question, embedding)
+	cachedAnswer.Answer.PromptVersion = version
+	return cachedAnswer
+}
+
+func TestGetAnswer(t *testing.T) {
+	mockGetPromptVersionErr := errors.New("mock get prompt version error")
+	mockGetCachedAnswerErr := errors.New("mock get cached answer error")
+	mockGetAnswerErr := errors.New("mock get answer error")
+
+	cachedAnswer := &nlp.Answer{
+		Text: "Cached answer.",
+		Citations: []nlp.Citation{
+			{
+				ID:      "mock_id",
+				Excerpt: "mock excerpt",
+			},
+		},
+		Cached:        true,
+		PromptVersion: "v1",
+		Mode:          nlp.DefaultMode,
+	}
+
+	generatedAnswer := &nlp.Answer{
+		Text: "Generated answer.",
+	}
+
+	tests := []struct {
+		description                string
+		question                   string
+		history                    []nlp.Turn
+		mode                       string
+		threshold                  float64
+		mockGetPromptVersionError  error
+		mockGetCachedAnswerOutput  *db.CachedAnswer
+		mockGetCachedAnswerError   error
+		mockGetCachedAnswersOutput []db.CachedAnswer
+		mockGetAnswerOutput        *nlp.Answer
+		mockGetAnswerError         error
+		answer                     *nlp.Answer
+		answers                    int
+		embeddings                 int
+		stored                     []db.CachedAnswer
+		error                      error
+	}{
+		{
+			description:               "error getting prompt version",
+			question:                  "Mock question?",
+			mockGetPromptVersionError: mockGetPromptVersionErr,
+			answer:                    nil,
+			answers:                   0,
+			embeddings:                0,
+			stored:                    nil,
+			error:                     mockGetPromptVersionErr,
+		},
+		{
+			description:              "error getting cached answer",
+			question:                 "Mock question?",
+			mockGetCachedAnswerError: mockGetCachedAnswerErr,
+			answer:                   nil,
+			answers:                  0,
+			embeddings:               0,
+			stored:                   nil,
+			error:                    mockGetCachedAnswerErr,
+		},
+		{
+			description: "exact cached answer",
+			question:    "  MOCK question?",
+			mockGetCachedAnswerOutput: func() *db.CachedAnswer {
+				cachedAnswer := mockCachedAnswer("mock question", []float64{1, 0})
+				return &cachedAnswer
+			}(),
+			answer:     cachedAnswer,
+			answers:    0,
+			embeddings: 0,
+			stored:     nil,
+			error:      nil,
+		},
+		{
+			description: "exact cached answer from other prompt version",
+			question:    "Mock question?",
+			mockGetCachedAnswerOutput: func() *db.CachedAnswer {
+				cachedAnswer := mockCachedAnswerVersion("mock question", []float64{1, 0}, "v0")
+				return &cachedAnswer
+			}(),
+			mockGetAnswerOutput: generatedAnswer,
+			answer:              generatedAnswer,
+			answers:             1,
+			embeddings:          1,
+			stored: []db.CachedAnswer{
+				{
+					Question:  "mock question",
+					Embedding: []float64{1, 0},
+					Answer:    *generatedAnswer,
+				},
+			},
+			error: nil,
+		},
+		{
+			description: "similar cached answer",
+			question:    "Mock question?",
+			mockGetCachedAnswersOutput: []db.CachedAnswer{
+				mockCachedAnswer("unrelated question", []float64{0, 1}),
+				mockCachedAnswer("mock questions", []float64{0.99, 0.01}),
+			},
+			answer:     cachedAnswer,
+			answers:    0,
+			embeddings: 1,
+			stored:     nil,
+			error:      nil,
+		},
+		{
+			description: "similar cached answer in other mode",
+			question:    "Mock question?",
+			mode:        nlp.DetailedMode,
+			mockGetCachedAnswersOutput: []db.CachedAnswer{
+				mockCachedAnswer("mock questions", []float64{0.99, 0.01}),
+			},
+			mockGetAnswerOutput: generatedAnswer,
+			answer:              generatedAnswer,
+			answers:             1,
+			embeddings:          1,
+			stored: []db.CachedAnswer{
+				{
+					Question:  "mock question",
+					Embedding: []float64{1, 0},
+					Answer:    *generatedAnswer,
+				},
+			},
+			error: nil,
+		},
+		{
+			description: "similar cached answer from other prompt version",
+			question:    "Mock question?",
+			mockGetCachedAnswersOutput: []db.CachedAnswer{
+				mockCachedAnswerVersion("mock questions", []float64{0.99, 0.01}, "v0"),
+			},
+			mockGetAnswerOutput: generatedAnswer,
+			answer:              generatedAnswer,
+			answers:             1,
+			embeddings:          1,
+			stored: []db.CachedAnswer{
+				{
+					Question:  "mock question",
+					Embedding: []float64{1, 0},
+					Answer:    *generatedAnswer,
+				},
+			},
+			error: nil,
+		},
+		{
+			description: "no similar cached answer",
+			question:    "Mock question?",
+			mockGetCachedAnswersOutput: []db.CachedAnswer{
+				mockCachedAnswer("unrelated question", []float64{0, 1}),
+			},
+			mockGetAnswerOutput: generatedAnswer,
+			answer:              generatedAnswer,
+			answers:             1,
+			embeddings:          1,
+			stored: []db.CachedAnswer{
+				{
+					Question:  "mock question",
+					Embedding: []float64{1, 0},
+					Answer:    *generatedAnswer,
+				},
+			},
+			error: nil,
+		},
+		{
+			description:         "similarity matching disabled",
+			question:            "Mock question?",
+			threshold:           2,
+			mockGetAnswerOutput: generatedAnswer,
+			answer:              generatedAnswer,
+			answers:             1,
+			embeddings:          0,
+			stored: []db.CachedAnswer{
+				{
+					Question:  "mock question",
+					Embedding: nil,
+					Answer:    *generatedAnswer,
+				},
+			},
+			error: nil,
+		},
+		{
+			description:         "empty answer not cached",
+			question:            "Mock question?",
+			mockGetAnswerOutput: &nlp.Answer{},
+			answer:              &nlp.Answer{},
+			answers:             1,
+			embeddings:          1,
+			stored:              nil,
+			error:               nil,
+		},
+		{
+			description: "refused answer not cached",
+			question:    "Mock question?",
+			mockGetAnswerOutput: &nlp.Answer{
+				Text: "Refused.",
+				Refusal: &nlp.Refusal{
+					Target: nlp.QuestionTarget,
+				},
+			},
+			answer: &nlp.Answer{
+				Text: "Refused.",
+				Refusal: &nlp.Refusal{
+					Target: nlp.QuestionTarget,
+				},
+			},
+			answers:    1,
+			embeddings: 1,
+			stored:     nil,
+			error:      nil,
+		},
+		{
+			description: "ungrounded answer not cached",
+			question:    "Mock question?",
+			mockGetAnswerOutput: &nlp.Answer{
+				Text: "Ungrounded.",
+				Grounding: &nlp.Grounding{
+					Flagged: true,
+				},
+			},
+			answer: &nlp.Answer{
+				Text: "Ungrounded.",
+				Grounding: &nlp.Grounding{
+					Flagged: true,
+				},
+			},
+			answers:    1,
+			embeddings: 1,
+			stored:     nil,
+			error:      nil,
+		},
+		{
+			description:         "question without words not cached",
+			question:            "?",
+			mockGetAnswerOutput: generatedAnswer,
+			answer:              generatedAnswer,
+			answers:             1,
+			embeddings:          0,
+			stored:              nil,
+			error:               nil,
+		},
+		{
+			description: "follow-up question not cached",
+			question:    "Mock question?",
+			history: []nlp.Turn{
+				{
+					Question: "earlier question",
+					Answer:   "Earlier answer.",
+				},
+			},
+			mockGetCachedAnswerOutput: func() *db.CachedAnswer {
+				cachedAnswer := mockCachedAnswer("mock question", []float64{1, 0})
+				return &cachedAnswer
+			}(),
+			mockGetAnswerOutput: generatedAnswer,
+			answer:              generatedAnswer,
+			answers:             1,
+			embeddings:          0,
+			stored:              nil,
+			error:               nil,
+		},
+		{
+			description:        "error getting answer",
+			question:           "Mock question?",
+			mockGetAnswerError: mockGetAnswerErr,
+			answer:             nil,
+			answers:            1,
+			embeddings:         1,
+			stored:             nil,
+			error:              mockGetAnswerErr,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			d := &mockDBClient{
+				mockGetCachedAnswerOutput:  test.mockGetCachedAnswerOutput,
+				mockGetCachedAnswerError:   test.mockGetCachedAnswerError,
+				mockGetCachedAnswersOutput: test.mockGetCachedAnswersOutput,
+			}
+
+			n := &mockNLPClient{
+				mockGetAnswerOutput:        test.mockGetAnswerOutput,
+				mockGetAnswerError:         test.mockGetAnswerError,
+				mockGetEmbeddingOutput:     []float64{1, 0},
+				mockGetPromptVersionOutput: "v1",
+				mockGetPromptVersionError:  test.mockGetPromptVersionError,
+			}
+
+			c := New(d, n, test.threshold)
+
+			ctx := context.Background()
+			if test.history != nil {
+				ctx = nlp.WithHistory(ctx, test.history)
+			}
+			if test.mode != "" {
+				ctx = nlp.WithMode(ctx, test.mode)
+			}
+
+			answer, err := c.GetAnswer(ctx, test.question, "user_id")
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(answer, test.answer) {
+				t.Errorf("incorrect answer, received: %+v, expected: %+v", answer, test.answer)
+			}
+
+			if n.answers != test.answers {
+				t.Errorf("incorrect answers, received: %d, expected: %d", n.answers, test.answers)
+			}
+
+			if n.embeddings != test.embeddings {
+				t.Errorf("incorrect embeddings, received: %d, expected: %d", n.embeddings, test.embeddings)
+			}
+
+			if !reflect.DeepEqual(d.storedCachedAnswers, test.stored) {
+				t.Errorf("incorrect stored answers, received: %+v, expected: %+v", d.storedCachedAnswers, test.stored)
+			}
+		})
+	}
+}
+
+func TestStreamAnswer(t *testing.T) {
+	tests := []struct {
+		description               string
+		mockGetCachedAnswerOutput *db.CachedAnswer
+		mockGetAnswerOutput       *nlp.Answer
+		tokens                    []string
+		cached                    bool
+		answers                   int
+	}{
+		{
+			description: "cached answer sent as one token",
+			mockGetCachedAnswerOutput: func() *db.CachedAnswer {
+				cachedAnswer := mockCachedAnswer("mock question", []float64{1, 0})
+				return &cachedAnswer
+			}(),
+			tokens:  []string{"Cached answer."},
+			cached:  true,
+			answers: 0,
+		},
+		{
+			description: "generated answer streamed",
+			mockGetAnswerOutput: &nlp.Answer{
+				Text: "Generated answer.",
+			},
+			tokens:  []string{"Generated answer."},
+			cached:  false,
+			answers: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			d := &mockDBClient{
+				mockGetCachedAnswerOutput:  test.mockGetCachedAnswerOutput,
+				mockGetCachedAnswersOutput: []db.CachedAnswer{},
+			}
+
+			n := &mockNLPClient{
+				mockGetAnswerOutput:        test.mockGetAnswerOutput,
+				mockGetEmbeddingOutput:     []float64{1, 0},
+				mockGetPromptVersionOutput: "v1",
+			}
+
+			c := New(d, n, 0)
+
+			tokens := []string{}
+			answer, err := c.StreamAnswer(context.Background(), "Mock question?", "user_id", func(token string) error {
+				tokens = append(tokens, token)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("incorrect error, received: %v", err)
+			}
+
+			if !reflect.DeepEqual(tokens, test.tokens) {
+				t.Errorf("incorrect tokens, received: %v, expected: %v", tokens, test.tokens)
+			}
+
+			if answer.Cached != test.cached {
+				t.Errorf("incorrect cached, received: %t, expected: %t", answer.Cached, test.cached)
+			}
+
+			if n.answers != test.answers {
+				t.Errorf("incorrect answers, received: %d, expected: %d", n.answers, test.answers)
+			}
+		})
+	}
+}
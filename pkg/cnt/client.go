@@ -22,7 +22,7 @@ func New() *Client {
 // returning a slice of structs representing the items in
 // the RSS feed.
 func (c *Client) GetItems(ctx context.Context, address string) ([]ItemXML, error) {
-	response, err := http.Get(address)
+	response, err := get(ctx, address)
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +47,7 @@ func (c *Client) GetItems(ctx context.Context, address string) ([]ItemXML, error
 // GetText implements the cnt.Contenter.GetText method
 // returning the text of a target RSS item address.
 func (c *Client) GetText(ctx context.Context, address string) (*string, error) {
-	response, err := http.Get(address)
+	response, err := get(ctx, address)
 	if err != nil {
 		return nil, err
 	}
@@ -61,3 +61,24 @@ func (c *Client) GetText(ctx context.Context, address string) (*string, error) {
 	text := document.Find("tbody").First().Text()
 	return &text, nil
 }
+
+// GetEssay implements the cnt.Contenter.GetEssay method
+// returning the structured content of the essay of the RSS item.
+func (c *Client) GetEssay(ctx context.Context, item ItemXML) (*Essay, error) {
+	text, err := c.GetText(ctx, item.Link)
+	if err != nil {
+		return nil, err
+	}
+
+	essay := ParseEssay(item.Title, *text)
+	return &essay, nil
+}
+
+func get(ctx context.Context, address string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.DefaultClient.Do(request)
+}
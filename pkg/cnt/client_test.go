@@ -2,12 +2,14 @@ package cnt
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 )
@@ -16,9 +18,17 @@ func TestGetItems(t *testing.T) {
 	tests := []struct {
 		description string
 		body        string
+		canceled    bool
 		response    []ItemXML
 		error       error
 	}{
+		{
+			description: "context canceled",
+			body:        "",
+			canceled:    true,
+			response:    nil,
+			error:       context.Canceled,
+		},
 		{
 			description: "error getting data from server",
 			body:        "---",
@@ -62,8 +72,14 @@ func TestGetItems(t *testing.T) {
 
 			server := httptest.NewServer(mux)
 
-			response, err := client.GetItems(context.Background(), server.URL+urlPath)
-			if err != test.error {
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.canceled {
+				cancel()
+			}
+			defer cancel()
+
+			response, err := client.GetItems(ctx, server.URL+urlPath)
+			if !errors.Is(err, test.error) {
 				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
 			}
 
@@ -114,3 +130,37 @@ func TestGetText(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEssay(t *testing.T) {
+	client := &Client{}
+
+	urlPath := "/words.html"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html PUBLIC "-//W3C//DTD HTML 4.01 Transitional//EN">
+<html><body><table><tbody><tr><td><table><tbody><tr><td><font>February 2022<br><br>Writing about something shows you that you didn't know it.</font></td></tr></tbody></table></td></tr></tbody></table></body></html>`)
+	})
+
+	server := httptest.NewServer(mux)
+
+	essay, err := client.GetEssay(context.Background(), ItemXML{
+		Link:  server.URL + urlPath,
+		Title: "Putting Ideas into Words",
+	})
+	if err != nil {
+		t.Fatalf("incorrect error, received: %v, expected: nil", err)
+	}
+
+	expected := &Essay{
+		Title:          "Putting Ideas into Words",
+		Published:      time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+		Body:           "Writing about something shows you that you didn't know it.",
+		WordCount:      10,
+		ReadingMinutes: 1,
+	}
+
+	if !reflect.DeepEqual(essay, expected) {
+		t.Errorf("incorrect essay, received: %+v, expected: %+v", essay, expected)
+	}
+}
@@ -1,12 +1,16 @@
 package cnt
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Contenter defines methods for interacting with the
 // root blog content.
 type Contenter interface {
 	GetItems(ctx context.Context, address string) ([]ItemXML, error)
 	GetText(ctx context.Context, address string) (*string, error)
+	GetEssay(ctx context.Context, item ItemXML) (*Essay, error)
 }
 
 // RSSXML represents the target RSS feed.
@@ -25,3 +29,16 @@ type ItemXML struct {
 	Title  string `xml:"title"`
 	Number int
 }
+
+// Essay represents the structured content of an essay.
+//
+// Published is the first day of the month the essay is dated
+// with and is zero when the essay text has no date. Body is the
+// essay text without the title and date.
+type Essay struct {
+	Title          string    `json:"title"`
+	Published      time.Time `json:"published"`
+	Body           string    `json:"body"`
+	WordCount      int       `json:"word_count"`
+	ReadingMinutes int       `json:"reading_minutes"`
+}
@@ -0,0 +1,55 @@
+package cnt
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const wordsPerMinute = 230
+
+const dateLayout = "January 2006"
+
+// maxTitleLength bounds the text before the date which is taken
+// as the title so that dates in the essay body are not mistaken
+// for the publication date.
+const maxTitleLength = 200
+
+var (
+	dateRegexp     = regexp.MustCompile(`(January|February|March|April|May|June|July|August|September|October|November|December)\s+(\d{4})`)
+	preambleRegexp = regexp.MustCompile(`^Want to start a startup\?\s*Get funded by\s*Y Combinator\.`)
+)
+
+// ParseEssay returns the structured content of the essay text
+// which begins with its title and the month and year it was
+// published. The provided title is used when the text does not
+// begin with a title and the text is kept as the body when it
+// has no date.
+func ParseEssay(title, text string) Essay {
+	text = strings.TrimSpace(preambleRegexp.ReplaceAllString(strings.TrimSpace(text), ""))
+
+	essay := Essay{
+		Title: strings.TrimSpace(title),
+		Body:  text,
+	}
+
+	if location := dateRegexp.FindStringIndex(text); location != nil && location[0] <= maxTitleLength {
+		prefix := strings.TrimSpace(text[:location[0]])
+		if prefix == "" || essay.Title == "" || prefix == essay.Title {
+			published, err := time.Parse(dateLayout, strings.Join(strings.Fields(text[location[0]:location[1]]), " "))
+			if err == nil {
+				if prefix != "" {
+					essay.Title = prefix
+				}
+				essay.Published = published
+				essay.Body = strings.TrimSpace(text[location[1]:])
+			}
+		}
+	}
+
+	essay.WordCount = len(strings.Fields(essay.Body))
+	essay.ReadingMinutes = int(math.Ceil(float64(essay.WordCount) / wordsPerMinute))
+
+	return essay
+}
@@ -0,0 +1,83 @@
+package cnt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEssay(t *testing.T) {
+	tests := []struct {
+		description string
+		title       string
+		text        string
+		essay       Essay
+	}{
+		{
+			description: "text without date",
+			title:       "Persistence",
+			text:        " Be relentlessly resourceful. ",
+			essay: Essay{
+				Title:          "Persistence",
+				Body:           "Be relentlessly resourceful.",
+				WordCount:      3,
+				ReadingMinutes: 1,
+			},
+		},
+		{
+			description: "date at start of text",
+			title:       "Putting Ideas into Words",
+			text:        "February 2022Writing about something shows you that you didn't know it.",
+			essay: Essay{
+				Title:          "Putting Ideas into Words",
+				Published:      time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+				Body:           "Writing about something shows you that you didn't know it.",
+				WordCount:      10,
+				ReadingMinutes: 1,
+			},
+		},
+		{
+			description: "title and date at start of text",
+			title:       "",
+			text:        "Putting Ideas into Words February 2022Writing about something shows you that you didn't know it.",
+			essay: Essay{
+				Title:          "Putting Ideas into Words",
+				Published:      time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+				Body:           "Writing about something shows you that you didn't know it.",
+				WordCount:      10,
+				ReadingMinutes: 1,
+			},
+		},
+		{
+			description: "funding preamble before date",
+			title:       "Be Good",
+			text:        "Want to start a startup?  Get funded by\nY Combinator.\n\nApril 2008\n\nThis essay is derived from a talk.",
+			essay: Essay{
+				Title:          "Be Good",
+				Published:      time.Date(2008, time.April, 1, 0, 0, 0, 0, time.UTC),
+				Body:           "This essay is derived from a talk.",
+				WordCount:      7,
+				ReadingMinutes: 1,
+			},
+		},
+		{
+			description: "date in body not taken as publication date",
+			title:       "Schlep Blindness",
+			text:        "I started Viaweb in January 1995 with Robert.",
+			essay: Essay{
+				Title:          "Schlep Blindness",
+				Body:           "I started Viaweb in January 1995 with Robert.",
+				WordCount:      8,
+				ReadingMinutes: 1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if essay := ParseEssay(test.title, test.text); !reflect.DeepEqual(essay, test.essay) {
+				t.Errorf("incorrect essay, received: %+v, expected: %+v", essay, test.essay)
+			}
+		})
+	}
+}
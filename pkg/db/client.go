@@ -3,62 +3,93 @@ package db
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"io"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
 )
 
 const documentsFilename = "documents.jsonl"
 
+const (
+	batchRetries = 3                     // retries of unprocessed keys
+	batchBackoff = 50 * time.Millisecond // doubled on each retry
+)
+
+var errUnprocessedKeys = errors.New("db: unprocessed keys remaining after retries")
+
 var _ Databaser = &Client{}
 
+// Tables holds the names of the DynamoDB tables used by Client.
+//
+// Tables which are left empty are not used by the caller.
+type Tables struct {
+	Questions string
+	Summaries string
+	Cache     string
+	Spend     string
+	Sessions  string
+}
+
 // Client implements the db.Databaser interface using
 // AWS S3 and AWS DynamoDB.
 type Client struct {
 	bucketName         string
 	questionsTableName string
 	summariesTableName string
+	cacheTableName     string
+	spendTableName     string
+	sessionsTableName  string
 	dynamoDBClient     dynamoDBClient
 	s3Client           s3Client
 }
 
 // New generates a Client pointer instance.
-func New(newSession *session.Session, bucketName, questionsTableName, summariesTableName string) *Client {
+func New(newSession *session.Session, bucketName string, tables Tables) *Client {
 	return &Client{
 		bucketName:         bucketName,
-		questionsTableName: questionsTableName,
-		summariesTableName: summariesTableName,
+		questionsTableName: tables.Questions,
+		summariesTableName: tables.Summaries,
+		cacheTableName:     tables.Cache,
+		spendTableName:     tables.Spend,
+		sessionsTableName:  tables.Sessions,
 		dynamoDBClient:     dynamodb.New(newSession),
 		s3Client:           s3.New(newSession),
 	}
 }
 
 type s3Client interface {
-	GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
-	PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error)
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+	PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error)
 }
 
 type dynamoDBClient interface {
-	Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
-	BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
-	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
-	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error)
+	GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error)
+	BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error)
+	PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error)
+	UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error)
 }
 
 // GetIDs implements the db.Databaser.GetIDs method
 // using AWS DynamoDB and returns a slice of the IDs
 // of the items stored in the "summaries" table.
 func (c *Client) GetIDs(ctx context.Context) ([]string, error) {
-	scanOutput, err := c.dynamoDBClient.Scan(&dynamodb.ScanInput{
+	scanOutput, err := c.dynamoDBClient.ScanWithContext(ctx, &dynamodb.ScanInput{
 		TableName: &c.summariesTableName,
 	})
 	if err != nil {
@@ -77,7 +108,7 @@ func (c *Client) GetIDs(ctx context.Context) ([]string, error) {
 // method using AWS DynamoDB and returns a slice of structs
 // representing the rows stored in the "summaries" table.
 func (c *Client) GetSummaries(ctx context.Context) ([]Summary, error) {
-	scanOutput, err := c.dynamoDBClient.Scan(&dynamodb.ScanInput{
+	scanOutput, err := c.dynamoDBClient.ScanWithContext(ctx, &dynamodb.ScanInput{
 		TableName: &c.summariesTableName,
 	})
 	if err != nil {
@@ -86,65 +117,139 @@ func (c *Client) GetSummaries(ctx context.Context) ([]Summary, error) {
 
 	datas := make([]Summary, len(scanOutput.Items))
 	for i, item := range scanOutput.Items {
-		number, err := strconv.Atoi(*item["number"].N)
+		summary, err := getSummaryFromItem(item)
 		if err != nil {
 			return nil, err
 		}
 
-		datas[i] = Summary{
-			ID:      *item["id"].S,
-			URL:     *item["url"].S,
-			Title:   *item["title"].S,
-			Summary: *item["summary"].S,
-			Number:  number,
-		}
+		datas[i] = *summary
 	}
 
 	return datas, nil
 }
 
-// StoreSummaries implements the db.Databaser.StoreSummaries
-// method using AWS DynamoDB and stores the provided slice of
-// structs in the "summaries" table.
-func (c *Client) StoreSummaries(ctx context.Context, summaries []Summary) error {
-	chunk := 25
-	for i := 0; i < len(summaries); i += chunk {
+// GetSummariesByIDs implements the db.Databaser.GetSummariesByIDs
+// method using AWS DynamoDB and returns a slice of structs
+// representing the rows in the "summaries" table matching the
+// provided IDs.
+//
+// Keys left unprocessed by DynamoDB are requested again with
+// an exponential backoff.
+func (c *Client) GetSummariesByIDs(ctx context.Context, ids []string) ([]Summary, error) {
+	summaries := []Summary{}
+
+	chunk := 100
+	for i := 0; i < len(ids); i += chunk {
 		end := i + chunk
-		if end > len(summaries) {
-			end = len(summaries)
+		if end > len(ids) {
+			end = len(ids)
 		}
 
-		putRequests := []*dynamodb.WriteRequest{}
-		for _, summary := range summaries[i:end] {
-			putRequests = append(putRequests, &dynamodb.WriteRequest{
-				PutRequest: &dynamodb.PutRequest{
-					Item: map[string]*dynamodb.AttributeValue{
-						"id": {
-							S: aws.String(summary.ID),
-						},
-						"url": {
-							S: aws.String(summary.URL),
-						},
-						"title": {
-							S: aws.String(summary.Title),
-						},
-						"summary": {
-							S: aws.String(summary.Summary),
-						},
-						"number": {
-							N: aws.String(strconv.Itoa(summary.Number)),
-						},
-					},
+		keys := []map[string]*dynamodb.AttributeValue{}
+		for _, id := range ids[i:end] {
+			keys = append(keys, map[string]*dynamodb.AttributeValue{
+				"id": {
+					S: aws.String(id),
 				},
 			})
 		}
 
-		_, err := c.dynamoDBClient.BatchWriteItem(&dynamodb.BatchWriteItemInput{
-			RequestItems: map[string][]*dynamodb.WriteRequest{
-				c.summariesTableName: putRequests,
+		requestItems := map[string]*dynamodb.KeysAndAttributes{
+			c.summariesTableName: {
+				Keys: keys,
 			},
-		})
+		}
+
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt > 0 {
+				if attempt > batchRetries {
+					return nil, errUnprocessedKeys
+				}
+
+				if err := aws.SleepWithContext(ctx, batchBackoff<<(attempt-1)); err != nil {
+					return nil, err
+				}
+			}
+
+			batchGetItemOutput, err := c.dynamoDBClient.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, item := range batchGetItemOutput.Responses[c.summariesTableName] {
+				summary, err := getSummaryFromItem(item)
+				if err != nil {
+					return nil, err
+				}
+
+				summaries = append(summaries, *summary)
+			}
+
+			requestItems = batchGetItemOutput.UnprocessedKeys
+		}
+	}
+
+	return summaries, nil
+}
+
+func getSummaryFromItem(item map[string]*dynamodb.AttributeValue) (*Summary, error) {
+	number, err := strconv.Atoi(*item["number"].N)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{
+		ID:      *item["id"].S,
+		URL:     *item["url"].S,
+		Title:   *item["title"].S,
+		Summary: *item["summary"].S,
+		Number:  number,
+	}
+
+	if published, ok := item["published"]; ok && published.S != nil {
+		summary.Published = *published.S
+	}
+
+	if wordCount, ok := item["word_count"]; ok && wordCount.N != nil {
+		summary.WordCount, err = strconv.Atoi(*wordCount.N)
 		if err != nil {
+			return nil, err
+		}
+	}
+
+	if readingMinutes, ok := item["reading_minutes"]; ok && readingMinutes.N != nil {
+		summary.ReadingMinutes, err = strconv.Atoi(*readingMinutes.N)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if related, ok := item["related"]; ok && related.S != nil {
+		if err := json.Unmarshal([]byte(*related.S), &summary.Related); err != nil {
+			return nil, err
+		}
+	}
+
+	if topics, ok := item["topics"]; ok && topics.S != nil {
+		if err := json.Unmarshal([]byte(*topics.S), &summary.Topics); err != nil {
+			return nil, err
+		}
+	}
+
+	return summary, nil
+}
+
+// StoreSummaries implements the db.Databaser.StoreSummaries
+// method using AWS DynamoDB and stores the provided slice of
+// structs in the "summaries" table.
+//
+// Each summary is written with an update so that the related
+// essays and topics stored separately in its row are kept.
+func (c *Client) StoreSummaries(ctx context.Context, summaries []Summary) error {
+	for _, summary := range summaries {
+		if err := c.storeSummary(ctx, summary); err != nil {
 			return err
 		}
 	}
@@ -152,13 +257,155 @@ func (c *Client) StoreSummaries(ctx context.Context, summaries []Summary) error
 	return nil
 }
 
+func (c *Client) storeSummary(ctx context.Context, summary Summary) error {
+	attributes := map[string]*dynamodb.AttributeValue{
+		"url": {
+			S: aws.String(summary.URL),
+		},
+		"title": {
+			S: aws.String(summary.Title),
+		},
+		"summary": {
+			S: aws.String(summary.Summary),
+		},
+		"number": {
+			N: aws.String(strconv.Itoa(summary.Number)),
+		},
+	}
+
+	if summary.Published != "" {
+		attributes["published"] = &dynamodb.AttributeValue{
+			S: aws.String(summary.Published),
+		}
+	}
+
+	if summary.WordCount > 0 {
+		attributes["word_count"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.Itoa(summary.WordCount)),
+		}
+		attributes["reading_minutes"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.Itoa(summary.ReadingMinutes)),
+		}
+	}
+
+	if summary.PromptVersion != "" {
+		attributes["prompt_version"] = &dynamodb.AttributeValue{
+			S: aws.String(summary.PromptVersion),
+		}
+	}
+
+	if summary.Usage != nil && len(summary.Usage.Tokens) > 0 {
+		for key, value := range getUsageAttributes(*summary.Usage) {
+			attributes[key] = value
+		}
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expressionAttributeNames := map[string]*string{}
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{}
+	updates := make([]string, len(names))
+	for i, name := range names {
+		expressionAttributeNames["#"+name] = aws.String(name)
+		expressionAttributeValues[":"+name] = attributes[name]
+		updates[i] = "#" + name + " = :" + name
+	}
+
+	_, err := c.dynamoDBClient.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(summary.ID),
+			},
+		},
+		UpdateExpression: aws.String("set " + strings.Join(updates, ", ")),
+		TableName:        &c.summariesTableName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// StoreRelated implements the db.Databaser.StoreRelated method
+// using AWS DynamoDB and stores the essays related to the essay
+// of the summary in its row in the "summaries" table.
+func (c *Client) StoreRelated(ctx context.Context, id string, related []Related) error {
+	relatedJSON, err := json.Marshal(related)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dynamoDBClient.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":related": {
+				S: aws.String(string(relatedJSON)),
+			},
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: &id,
+			},
+		},
+		UpdateExpression: aws.String("set related = :related"),
+		TableName:        &c.summariesTableName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// StoreTopics implements the db.Databaser.StoreTopics method
+// using AWS DynamoDB and stores the topics of the essay of the
+// summary and the version of the prompts used to label them in
+// its row in the "summaries" table.
+func (c *Client) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	topicsJSON, err := json.Marshal(topics)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dynamoDBClient.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":topics": {
+				S: aws.String(string(topicsJSON)),
+			},
+			":topics_prompt_version": {
+				S: &promptVersion,
+			},
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: &id,
+			},
+		},
+		UpdateExpression: aws.String("set topics = :topics, topics_prompt_version = :topics_prompt_version"),
+		TableName:        &c.summariesTableName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // StoreText implements the db.Databaser.StoreText
 // method using AWS S3 and stores the provided text as
 // a Markdown file.
 //
 // This Markdown file is not used by the application,
 func (c *Client) StoreText(ctx context.Context, id, text string) error {
-	_, err := c.s3Client.PutObject(&s3.PutObjectInput{
+	_, err := c.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
 		Body:   aws.ReadSeekCloser(strings.NewReader(text)),
 		Bucket: &c.bucketName,
 		Key:    aws.String(id + ".md"),
@@ -171,7 +418,7 @@ func (c *Client) StoreText(ctx context.Context, id, text string) error {
 // method using AWS S3 and returns a slice of structs
 // representing the rows in the documents.jsonl file.
 func (c *Client) GetDocuments(ctx context.Context) ([]dct.Document, error) {
-	response, err := c.s3Client.GetObject(&s3.GetObjectInput{
+	response, err := c.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
 		Bucket: &c.bucketName,
 		Key:    aws.String(documentsFilename),
 	})
@@ -208,7 +455,7 @@ func (c *Client) StoreDocuments(ctx context.Context, documents []dct.Document) e
 		}
 	}
 
-	_, err := c.s3Client.PutObject(&s3.PutObjectInput{
+	_, err := c.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
 		Bucket: &c.bucketName,
 		Key:    aws.String(documentsFilename),
 		Body:   bytes.NewReader(documentsBody.Bytes()),
@@ -225,7 +472,7 @@ func (c *Client) StoreDocuments(ctx context.Context, documents []dct.Document) e
 // question in the "questions" table.
 func (c *Client) StoreQuestion(ctx context.Context, id, question string) error {
 	now := time.Now().String()
-	_, err := c.dynamoDBClient.PutItem(&dynamodb.PutItemInput{
+	_, err := c.dynamoDBClient.PutItemWithContext(ctx, &dynamodb.PutItemInput{
 		Item: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: &id,
@@ -237,7 +484,7 @@ func (c *Client) StoreQuestion(ctx context.Context, id, question string) error {
 				S: &now,
 			},
 		},
-		TableName: &c.questionsTableName,
+		TableName: &c.cacheTableName,
 	})
 	if err != nil {
 		return err
@@ -248,21 +495,47 @@ func (c *Client) StoreQuestion(ctx context.Context, id, question string) error {
 
 // StoreAnswer implements the db.Databaser.StoreAnswer
 // method using AWS DynamoDB and stores the received answer
-// generated by OpenAI in the "questions" table.
-func (c *Client) StoreAnswer(ctx context.Context, id, answer string) error {
-	_, err := c.dynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":answer": {
-				S: aws.String(answer),
-			},
+// generated by OpenAI in the "questions" table along with
+// the version of the prompts and the answer mode used to
+// generate it and its grounding when it was checked.
+func (c *Client) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
+	values := map[string]*dynamodb.AttributeValue{
+		":answer": {
+			S: aws.String(answer.Text),
 		},
+		":prompt_version": {
+			S: aws.String(answer.PromptVersion),
+		},
+		":answer_mode": {
+			S: aws.String(answer.Mode),
+		},
+	}
+	expression := "set answer = :answer, prompt_version = :prompt_version, answer_mode = :answer_mode"
+
+	if answer.Grounding != nil {
+		grounding, err := json.Marshal(answer.Grounding)
+		if err != nil {
+			return err
+		}
+
+		values[":grounding"] = &dynamodb.AttributeValue{
+			S: aws.String(string(grounding)),
+		}
+		values[":grounding_score"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatFloat(answer.Grounding.Score, 'f', -1, 64)),
+		}
+		expression += ", grounding = :grounding, grounding_score = :grounding_score"
+	}
+
+	_, err := c.dynamoDBClient.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: values,
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: &id,
 			},
 		},
 		ReturnValues:     aws.String("UPDATED_NEW"),
-		UpdateExpression: aws.String("set answer = :answer"),
+		UpdateExpression: &expression,
 		TableName:        &c.questionsTableName,
 	})
 	if err != nil {
@@ -271,3 +544,475 @@ func (c *Client) StoreAnswer(ctx context.Context, id, answer string) error {
 
 	return nil
 }
+
+// StoreModerations implements the db.Databaser.StoreModerations
+// method using AWS DynamoDB and stores the moderation results and
+// category scores of the question and answer in the "questions"
+// table.
+func (c *Client) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	moderationsJSON, err := json.Marshal(moderations)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dynamoDBClient.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":moderations": {
+				S: aws.String(string(moderationsJSON)),
+			},
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: &id,
+			},
+		},
+		UpdateExpression: aws.String("set moderations = :moderations"),
+		TableName:        &c.questionsTableName,
+	})
+
+	return err
+}
+
+// GetCachedAnswer implements the db.Databaser.GetCachedAnswer
+// method using AWS DynamoDB and returns the answer cached for the
+// provided normalized question and answer mode or nil if there is
+// none or it is malformed.
+func (c *Client) GetCachedAnswer(ctx context.Context, question, mode string) (*CachedAnswer, error) {
+	getItemOutput, err := c.dynamoDBClient.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(getCachedAnswerID(question, mode)),
+			},
+		},
+		TableName: &c.cacheTableName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cachedAnswer, ok := getCachedAnswerFromItem(getItemOutput.Item)
+	if !ok {
+		return nil, nil
+	}
+
+	return cachedAnswer, nil
+}
+
+// GetCachedAnswers implements the db.Databaser.GetCachedAnswers
+// method using AWS DynamoDB and returns all of the answers cached
+// in the "cache" table. Malformed cached answers are skipped.
+func (c *Client) GetCachedAnswers(ctx context.Context) ([]CachedAnswer, error) {
+	items, err := c.scanItems(ctx, &dynamodb.ScanInput{
+		TableName: &c.cacheTableName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cachedAnswers := make([]CachedAnswer, 0, len(items))
+	for _, item := range items {
+		cachedAnswer, ok := getCachedAnswerFromItem(item)
+		if !ok {
+			continue
+		}
+
+		cachedAnswers = append(cachedAnswers, *cachedAnswer)
+	}
+
+	return cachedAnswers, nil
+}
+
+// scanItems returns the items matching the provided scan across
+// all of the result pages.
+func (c *Client) scanItems(ctx context.Context, input *dynamodb.ScanInput) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+	for {
+		scanOutput, err := c.dynamoDBClient.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, scanOutput.Items...)
+
+		if len(scanOutput.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = scanOutput.LastEvaluatedKey
+	}
+}
+
+// StoreCachedAnswer implements the db.Databaser.StoreCachedAnswer
+// method using AWS DynamoDB and stores the provided answer in the
+// "cache" table under its normalized question and answer mode.
+func (c *Client) StoreCachedAnswer(ctx context.Context, cachedAnswer CachedAnswer) error {
+	citations, err := json.Marshal(cachedAnswer.Answer.Citations)
+	if err != nil {
+		return err
+	}
+
+	grounding, err := json.Marshal(cachedAnswer.Answer.Grounding)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().String()
+	_, err = c.dynamoDBClient.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(getCachedAnswerID(cachedAnswer.Question, cachedAnswer.Answer.Mode)),
+			},
+			"question": {
+				S: aws.String(cachedAnswer.Question),
+			},
+			"embedding": {
+				B: encodeEmbedding(cachedAnswer.Embedding),
+			},
+			"answer": {
+				S: aws.String(cachedAnswer.Answer.Text),
+			},
+			"citations": {
+				S: aws.String(string(citations)),
+			},
+			"prompt_version": {
+				S: aws.String(cachedAnswer.Answer.PromptVersion),
+			},
+			"answer_mode": {
+				S: aws.String(cachedAnswer.Answer.Mode),
+			},
+			"grounding": {
+				S: aws.String(string(grounding)),
+			},
+			"timestamp": {
+				S: &now,
+			},
+		},
+		TableName: &c.questionsTableName,
+	})
+
+	return err
+}
+
+// getCachedAnswerFromItem returns the cached answer held by the
+// item or false when the item is missing any of its attributes or
+// holds attributes which cannot be parsed, such as partially
+// written items.
+func getCachedAnswerFromItem(item map[string]*dynamodb.AttributeValue) (*CachedAnswer, bool) {
+	for _, name := range []string{"question", "answer", "citations"} {
+		if item[name] == nil || item[name].S == nil {
+			return nil, false
+		}
+	}
+
+	if item["embedding"] == nil || item["embedding"].B == nil {
+		return nil, false
+	}
+
+	citations := []nlp.Citation{}
+	if err := json.Unmarshal([]byte(aws.StringValue(item["citations"].S)), &citations); err != nil {
+		return nil, false
+	}
+
+	promptVersion := ""
+	if item["prompt_version"] != nil {
+		promptVersion = aws.StringValue(item["prompt_version"].S)
+	}
+
+	mode := nlp.DefaultMode
+	if item["answer_mode"] != nil {
+		mode = aws.StringValue(item["answer_mode"].S)
+	}
+
+	var grounding *nlp.Grounding
+	if item["grounding"] != nil {
+		if err := json.Unmarshal([]byte(aws.StringValue(item["grounding"].S)), &grounding); err != nil {
+			return nil, false
+		}
+	}
+
+	return &CachedAnswer{
+		Question:  aws.StringValue(item["question"].S),
+		Embedding: decodeEmbedding(item["embedding"].B),
+		Answer: nlp.Answer{
+			Text:          aws.StringValue(item["answer"].S),
+			Citations:     citations,
+			PromptVersion: promptVersion,
+			Mode:          mode,
+			Grounding:     grounding,
+		},
+	}, true
+}
+
+// getCachedAnswerID returns the "cache" table id of the answer
+// cached for the normalized question and answer mode. Answers in the
+// default mode are stored under the plain question.
+func getCachedAnswerID(question, mode string) string {
+	if mode == "" || mode == nlp.DefaultMode {
+		return question
+	}
+	return question + "#" + mode
+}
+
+// encodeEmbedding packs the embedding as little endian float32
+// values which keeps the items well under the DynamoDB item size
+// limit.
+func encodeEmbedding(embedding []float64) []byte {
+	data := make([]byte, 4*len(embedding))
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(value)))
+	}
+
+	return data
+}
+
+func decodeEmbedding(data []byte) []float64 {
+	embedding := make([]float64, len(data)/4)
+	for i := range embedding {
+		embedding[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+	}
+
+	return embedding
+}
+
+// StoreUsage implements the db.Databaser.StoreUsage method
+// using AWS DynamoDB and stores the provided usage with its
+// question in the "questions" table.
+func (c *Client) StoreUsage(ctx context.Context, usage Usage) error {
+	attributes := getUsageAttributes(usage)
+
+	_, err := c.dynamoDBClient.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":endpoint":  attributes["usage_endpoint"],
+			":timestamp": attributes["usage_timestamp"],
+			":tokens":    attributes["usage_tokens"],
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(usage.ID),
+			},
+		},
+		UpdateExpression: aws.String("set usage_endpoint = :endpoint, usage_timestamp = :timestamp, usage_tokens = :tokens"),
+		TableName:        &c.questionsTableName,
+	})
+
+	return err
+}
+
+// GetUsage implements the db.Databaser.GetUsage method using
+// AWS DynamoDB and returns the usage stored with the rows of the
+// "questions" and "summaries" tables.
+func (c *Client) GetUsage(ctx context.Context) ([]Usage, error) {
+	usage := []Usage{}
+	for _, tableName := range []string{c.questionsTableName, c.summariesTableName} {
+		items, err := c.scanItems(ctx, &dynamodb.ScanInput{
+			FilterExpression: aws.String("attribute_exists(usage_tokens)"),
+			TableName:        aws.String(tableName),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			itemUsage, err := getUsageFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+
+			usage = append(usage, *itemUsage)
+		}
+	}
+
+	return usage, nil
+}
+
+func getUsageAttributes(usage Usage) map[string]*dynamodb.AttributeValue {
+	tokens := make([]*dynamodb.AttributeValue, len(usage.Tokens))
+	for i, token := range usage.Tokens {
+		tokens[i] = &dynamodb.AttributeValue{
+			M: map[string]*dynamodb.AttributeValue{
+				"model": {
+					S: aws.String(token.Model),
+				},
+				"prompt_tokens": {
+					N: aws.String(strconv.Itoa(token.PromptTokens)),
+				},
+				"completion_tokens": {
+					N: aws.String(strconv.Itoa(token.CompletionTokens)),
+				},
+			},
+		}
+	}
+
+	return map[string]*dynamodb.AttributeValue{
+		"usage_endpoint": {
+			S: aws.String(usage.Endpoint),
+		},
+		"usage_timestamp": {
+			S: aws.String(usage.Timestamp.UTC().Format(time.RFC3339)),
+		},
+		"usage_tokens": {
+			L: tokens,
+		},
+	}
+}
+
+func getUsageFromItem(item map[string]*dynamodb.AttributeValue) (*Usage, error) {
+	timestamp, err := time.Parse(time.RFC3339, aws.StringValue(item["usage_timestamp"].S))
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]nlp.Usage, len(item["usage_tokens"].L))
+	for i, token := range item["usage_tokens"].L {
+		promptTokens, err := strconv.Atoi(aws.StringValue(token.M["prompt_tokens"].N))
+		if err != nil {
+			return nil, err
+		}
+
+		completionTokens, err := strconv.Atoi(aws.StringValue(token.M["completion_tokens"].N))
+		if err != nil {
+			return nil, err
+		}
+
+		tokens[i] = nlp.Usage{
+			Model:            aws.StringValue(token.M["model"].S),
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+		}
+	}
+
+	return &Usage{
+		ID:        aws.StringValue(item["id"].S),
+		Endpoint:  aws.StringValue(item["usage_endpoint"].S),
+		Timestamp: timestamp,
+		Tokens:    tokens,
+	}, nil
+}
+
+// GetSpend implements the db.Databaser.GetSpend method using
+// AWS DynamoDB and returns the spend counter of the month in the
+// "spend" table.
+func (c *Client) GetSpend(ctx context.Context, month string) (float64, error) {
+	getItemOutput, err := c.dynamoDBClient.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(month),
+			},
+		},
+		TableName: &c.spendTableName,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return getSpendFromItem(getItemOutput.Item)
+}
+
+// AddSpend implements the db.Databaser.AddSpend method using
+// AWS DynamoDB and atomically adds the amount to the spend counter
+// of the month in the "spend" table.
+func (c *Client) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	updateItemOutput, err := c.dynamoDBClient.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":amount": {
+				N: aws.String(strconv.FormatFloat(amount, 'f', -1, 64)),
+			},
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(month),
+			},
+		},
+		ReturnValues:     aws.String(dynamodb.ReturnValueUpdatedNew),
+		UpdateExpression: aws.String("add spend :amount"),
+		TableName:        &c.spendTableName,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return getSpendFromItem(updateItemOutput.Attributes)
+}
+
+func getSpendFromItem(item map[string]*dynamodb.AttributeValue) (float64, error) {
+	if item["spend"] == nil {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(aws.StringValue(item["spend"].N), 64)
+}
+
+// GetSession implements the db.Databaser.GetSession method using
+// AWS DynamoDB and returns the conversation session of the ID in
+// the "sessions" table.
+//
+// Sessions which do not exist or have expired are returned
+// without turns since DynamoDB may not delete expired items
+// until some time after they expire.
+func (c *Client) GetSession(ctx context.Context, id string) (*Session, error) {
+	getItemOutput, err := c.dynamoDBClient.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		TableName: &c.sessionsTableName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:    id,
+		Turns: []nlp.Turn{},
+	}
+
+	item := getItemOutput.Item
+	if item["turns"] == nil || item["expires_at"] == nil {
+		return session, nil
+	}
+
+	expiresAt, err := strconv.ParseInt(aws.StringValue(item["expires_at"].N), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().Unix() >= expiresAt {
+		return session, nil
+	}
+
+	if err := json.Unmarshal([]byte(aws.StringValue(item["turns"].S)), &session.Turns); err != nil {
+		return nil, err
+	}
+	session.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+
+	return session, nil
+}
+
+// StoreSession implements the db.Databaser.StoreSession method
+// using AWS DynamoDB and stores the provided session in the
+// "sessions" table with its expiry as the "expires_at" time to
+// live attribute.
+func (c *Client) StoreSession(ctx context.Context, session Session) error {
+	turns, err := json.Marshal(session.Turns)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dynamoDBClient.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(session.ID),
+			},
+			"turns": {
+				S: aws.String(string(turns)),
+			},
+			"expires_at": {
+				N: aws.String(strconv.FormatInt(session.ExpiresAt.Unix(), 10)),
+			},
+		},
+		TableName: &c.sessionsTableName,
+	})
+
+	return err
+}
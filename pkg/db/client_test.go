@@ -4,39 +4,60 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
 )
 
 type mockDynamoDBClient struct {
 	mockScanOutput          *dynamodb.ScanOutput
 	mockScanError           error
-	mockBatchWriteItemError error
+	mockBatchGetItemOutput  *dynamodb.BatchGetItemOutput
+	mockBatchGetItemOutputs []*dynamodb.BatchGetItemOutput
+	mockBatchGetItemError   error
+	mockGetItemOutput       *dynamodb.GetItemOutput
+	mockGetItemError        error
 	mockPutItemError        error
+	mockUpdateItemOutput    *dynamodb.UpdateItemOutput
 	mockUpdateItemError     error
+	updateItemInputs        []*dynamodb.UpdateItemInput
 }
 
-func (m *mockDynamoDBClient) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+func (m *mockDynamoDBClient) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
 	return m.mockScanOutput, m.mockScanError
 }
 
-func (m *mockDynamoDBClient) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
-	return nil, m.mockBatchWriteItemError
+func (m *mockDynamoDBClient) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	if len(m.mockBatchGetItemOutputs) > 0 {
+		output := m.mockBatchGetItemOutputs[0]
+		m.mockBatchGetItemOutputs = m.mockBatchGetItemOutputs[1:]
+		return output, m.mockBatchGetItemError
+	}
+
+	return m.mockBatchGetItemOutput, m.mockBatchGetItemError
+}
+
+func (m *mockDynamoDBClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return m.mockGetItemOutput, m.mockGetItemError
 }
 
-func (m *mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+func (m *mockDynamoDBClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
 	return nil, m.mockPutItemError
 }
 
-func (m *mockDynamoDBClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
-	return nil, m.mockUpdateItemError
+func (m *mockDynamoDBClient) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	m.updateItemInputs = append(m.updateItemInputs, input)
+	return m.mockUpdateItemOutput, m.mockUpdateItemError
 }
 
 type mockS3Client struct {
@@ -45,16 +66,22 @@ type mockS3Client struct {
 	mockPutObjectError  error
 }
 
-func (m *mockS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+func (m *mockS3Client) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
 	return m.mockGetObjectOutput, m.mockGetObjectError
 }
 
-func (m *mockS3Client) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+func (m *mockS3Client) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
 	return nil, m.mockPutObjectError
 }
 
 func TestNew(t *testing.T) {
-	client := New(session.New(), "bucket_name", "questions_table_name", "summaries_table_name")
+	client := New(session.New(), "bucket_name", Tables{
+		Questions: "questions_table_name",
+		Summaries: "summaries_table_name",
+		Cache:     "cache_table_name",
+		Spend:     "spend_table_name",
+		Sessions:  "sessions_table_name",
+	})
 	if client == nil {
 		t.Errorf("incorrect client, received: %v", client)
 	}
@@ -192,34 +219,268 @@ func TestGetSummaries(t *testing.T) {
 	}
 }
 
-func TestStoreSummaries(t *testing.T) {
-	mockBatchWriteItemErr := errors.New("mock batch write item error")
+func TestGetSummariesByIDs(t *testing.T) {
+	mockBatchGetItemErr := errors.New("mock batch get item error")
+
+	mockItem := func(id string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+			"url": {
+				S: aws.String("mock_url"),
+			},
+			"title": {
+				S: aws.String("mock_title"),
+			},
+			"summary": {
+				S: aws.String("mock_summary"),
+			},
+			"number": {
+				N: aws.String("1"),
+			},
+		}
+	}
+
+	mockUnprocessedKeys := map[string]*dynamodb.KeysAndAttributes{
+		"summaries_table_name": {
+			Keys: []map[string]*dynamodb.AttributeValue{
+				{
+					"id": {
+						S: aws.String("unprocessed_id"),
+					},
+				},
+			},
+		},
+	}
 
 	tests := []struct {
 		description             string
-		mockBatchWriteItemError error
+		mockBatchGetItemOutput  *dynamodb.BatchGetItemOutput
+		mockBatchGetItemOutputs []*dynamodb.BatchGetItemOutput
+		mockBatchGetItemError   error
+		summaries               []Summary
 		error                   error
 	}{
 		{
-			description:             "error putting item",
-			mockBatchWriteItemError: mockBatchWriteItemErr,
-			error:                   mockBatchWriteItemErr,
+			description:            "error batch getting items",
+			mockBatchGetItemOutput: nil,
+			mockBatchGetItemError:  mockBatchGetItemErr,
+			summaries:              nil,
+			error:                  mockBatchGetItemErr,
+		},
+		{
+			description: "error unprocessed keys remaining after retries",
+			mockBatchGetItemOutput: &dynamodb.BatchGetItemOutput{
+				UnprocessedKeys: mockUnprocessedKeys,
+			},
+			mockBatchGetItemError: nil,
+			summaries:             nil,
+			error:                 errUnprocessedKeys,
+		},
+		{
+			description: "successful invocation with unprocessed keys retried",
+			mockBatchGetItemOutputs: []*dynamodb.BatchGetItemOutput{
+				{
+					Responses: map[string][]map[string]*dynamodb.AttributeValue{
+						"summaries_table_name": {
+							mockItem("mock_id"),
+						},
+					},
+					UnprocessedKeys: mockUnprocessedKeys,
+				},
+				{
+					Responses: map[string][]map[string]*dynamodb.AttributeValue{
+						"summaries_table_name": {
+							mockItem("unprocessed_id"),
+						},
+					},
+				},
+			},
+			mockBatchGetItemError: nil,
+			summaries: []Summary{
+				{
+					ID:      "mock_id",
+					URL:     "mock_url",
+					Title:   "mock_title",
+					Summary: "mock_summary",
+					Number:  1,
+				},
+				{
+					ID:      "unprocessed_id",
+					URL:     "mock_url",
+					Title:   "mock_title",
+					Summary: "mock_summary",
+					Number:  1,
+				},
+			},
+			error: nil,
+		},
+		{
+			description: "successful invocation",
+			mockBatchGetItemOutput: &dynamodb.BatchGetItemOutput{
+				Responses: map[string][]map[string]*dynamodb.AttributeValue{
+					"summaries_table_name": {
+						{
+							"id": {
+								S: aws.String("mock_id"),
+							},
+							"url": {
+								S: aws.String("mock_url"),
+							},
+							"title": {
+								S: aws.String("mock_title"),
+							},
+							"summary": {
+								S: aws.String("mock_summary"),
+							},
+							"number": {
+								N: aws.String("1"),
+							},
+						},
+					},
+				},
+			},
+			mockBatchGetItemError: nil,
+			summaries: []Summary{
+				{
+					ID:      "mock_id",
+					URL:     "mock_url",
+					Title:   "mock_title",
+					Summary: "mock_summary",
+					Number:  1,
+				},
+			},
+			error: nil,
 		},
 		{
-			description:             "successful invocation",
-			mockBatchWriteItemError: nil,
-			error:                   nil,
+			description: "successful invocation with essay details, related essays, and topics",
+			mockBatchGetItemOutput: &dynamodb.BatchGetItemOutput{
+				Responses: map[string][]map[string]*dynamodb.AttributeValue{
+					"summaries_table_name": {
+						{
+							"id": {
+								S: aws.String("mock_id"),
+							},
+							"url": {
+								S: aws.String("mock_url"),
+							},
+							"title": {
+								S: aws.String("mock_title"),
+							},
+							"summary": {
+								S: aws.String("mock_summary"),
+							},
+							"number": {
+								N: aws.String("1"),
+							},
+							"published": {
+								S: aws.String("2022-02"),
+							},
+							"word_count": {
+								N: aws.String("2300"),
+							},
+							"reading_minutes": {
+								N: aws.String("10"),
+							},
+							"related": {
+								S: aws.String(`[{"id":"related_id","score":0.5}]`),
+							},
+							"topics": {
+								S: aws.String(`["startups"]`),
+							},
+						},
+					},
+				},
+			},
+			mockBatchGetItemError: nil,
+			summaries: []Summary{
+				{
+					ID:             "mock_id",
+					URL:            "mock_url",
+					Title:          "mock_title",
+					Summary:        "mock_summary",
+					Number:         1,
+					Published:      "2022-02",
+					WordCount:      2300,
+					ReadingMinutes: 10,
+					Related: []Related{
+						{
+							ID:    "related_id",
+							Score: 0.5,
+						},
+					},
+					Topics: []string{
+						"startups",
+					},
+				},
+			},
+			error: nil,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.description, func(t *testing.T) {
 			c := &Client{
+				summariesTableName: "summaries_table_name",
 				dynamoDBClient: &mockDynamoDBClient{
-					mockBatchWriteItemError: test.mockBatchWriteItemError,
+					mockBatchGetItemOutput:  test.mockBatchGetItemOutput,
+					mockBatchGetItemOutputs: test.mockBatchGetItemOutputs,
+					mockBatchGetItemError:   test.mockBatchGetItemError,
 				},
 			}
 
+			summaries, err := c.GetSummariesByIDs(context.Background(), []string{"mock_id"})
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(summaries, test.summaries) {
+				t.Errorf("incorrect summaries, received: %v, expected: %v", summaries, test.summaries)
+			}
+		})
+	}
+}
+
+func TestStoreSummaries(t *testing.T) {
+	mockUpdateItemErr := errors.New("mock update item error")
+
+	tests := []struct {
+		description         string
+		mockUpdateItemError error
+		updates             []string
+		error               error
+	}{
+		{
+			description:         "error updating item",
+			mockUpdateItemError: mockUpdateItemErr,
+			updates: []string{
+				"set #number = :number, #summary = :summary, #title = :title, #url = :url",
+			},
+			error: mockUpdateItemErr,
+		},
+		{
+			description:         "successful invocation",
+			mockUpdateItemError: nil,
+			updates: []string{
+				"set #number = :number, #summary = :summary, #title = :title, #url = :url",
+				"set #number = :number, #published = :published, #reading_minutes = :reading_minutes, #summary = :summary, #title = :title, #url = :url, #word_count = :word_count",
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dynamoDBClient := &mockDynamoDBClient{
+				mockUpdateItemError: test.mockUpdateItemError,
+			}
+
+			c := &Client{
+				dynamoDBClient: dynamoDBClient,
+			}
+
 			err := c.StoreSummaries(context.Background(), []Summary{
 				{
 					ID:      "mock_id",
@@ -227,6 +488,66 @@ func TestStoreSummaries(t *testing.T) {
 					Title:   "title",
 					Summary: "short summary",
 				},
+				{
+					ID:             "dated_id",
+					URL:            "url.com",
+					Title:          "title",
+					Summary:        "short summary",
+					Published:      "2022-02",
+					WordCount:      2300,
+					ReadingMinutes: 10,
+				},
+			})
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			updates := make([]string, len(dynamoDBClient.updateItemInputs))
+			for i, input := range dynamoDBClient.updateItemInputs {
+				updates[i] = aws.StringValue(input.UpdateExpression)
+			}
+
+			if !reflect.DeepEqual(updates, test.updates) {
+				t.Errorf("incorrect updates, received: %v, expected: %v", updates, test.updates)
+			}
+		})
+	}
+}
+
+func TestStoreRelated(t *testing.T) {
+	mockUpdateItemErr := errors.New("mock update item error")
+
+	tests := []struct {
+		description         string
+		mockUpdateItemError error
+		error               error
+	}{
+		{
+			description:         "error updating item",
+			mockUpdateItemError: mockUpdateItemErr,
+			error:               mockUpdateItemErr,
+		},
+		{
+			description:         "successful invocation",
+			mockUpdateItemError: nil,
+			error:               nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockUpdateItemError: test.mockUpdateItemError,
+				},
+			}
+
+			err := c.StoreRelated(context.Background(), "mock_id", []Related{
+				{
+					ID:    "related_id",
+					Score: 0.5,
+				},
 			})
 
 			if err != test.error {
@@ -236,6 +557,45 @@ func TestStoreSummaries(t *testing.T) {
 	}
 }
 
+func TestStoreTopics(t *testing.T) {
+	mockUpdateItemErr := errors.New("mock update item error")
+
+	tests := []struct {
+		description         string
+		mockUpdateItemError error
+		error               error
+	}{
+		{
+			description:         "error updating item",
+			mockUpdateItemError: mockUpdateItemErr,
+			error:               mockUpdateItemErr,
+		},
+		{
+			description:         "successful invocation",
+			mockUpdateItemError: nil,
+			error:               nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockUpdateItemError: test.mockUpdateItemError,
+				},
+			}
+
+			err := c.StoreTopics(context.Background(), "mock_id", []string{
+				"startups",
+			}, "v2")
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+		})
+	}
+}
+
 func TestStoreText(t *testing.T) {
 	mockPutObjectErr := errors.New("mock put object error")
 
@@ -435,7 +795,680 @@ func TestStoreAnswer(t *testing.T) {
 				},
 			}
 
-			err := c.StoreAnswer(context.Background(), "id", "answer")
+			err := c.StoreAnswer(context.Background(), "id", nlp.Answer{
+				Text:          "answer",
+				PromptVersion: "v1",
+				Mode:          nlp.DefaultMode,
+				Grounding: &nlp.Grounding{
+					Score:  1,
+					Claims: []nlp.Claim{},
+				},
+			})
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+		})
+	}
+}
+
+func mockCachedAnswerItem() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {
+			S: aws.String("mock question"),
+		},
+		"question": {
+			S: aws.String("mock question"),
+		},
+		"embedding": {
+			B: encodeEmbedding([]float64{0.5, -0.25}),
+		},
+		"answer": {
+			S: aws.String("Mock answer."),
+		},
+		"citations": {
+			S: aws.String(`[{"id":"mock_id","title":"","url":"","excerpt":"mock excerpt"}]`),
+		},
+		"grounding": {
+			S: aws.String(`{"score":1,"flagged":false,"claims":[{"text":"Mock answer.","score":1,"supported":true}]}`),
+		},
+	}
+}
+
+func mockCachedAnswer() CachedAnswer {
+	return CachedAnswer{
+		Question:  "mock question",
+		Embedding: []float64{0.5, -0.25},
+		Answer: nlp.Answer{
+			Text: "Mock answer.",
+			Citations: []nlp.Citation{
+				{
+					ID:      "mock_id",
+					Excerpt: "mock excerpt",
+				},
+			},
+			Mode: nlp.DefaultMode,
+			Grounding: &nlp.Grounding{
+				Score: 1,
+				Claims: []nlp.Claim{
+					{
+						Text:      "Mock answer.",
+						Score:     1,
+						Supported: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func Test_getCachedAnswerID(t *testing.T) {
+	tests := []struct {
+		description string
+		mode        string
+		id          string
+	}{
+		{
+			description: "no mode",
+			mode:        "",
+			id:          "mock question",
+		},
+		{
+			description: "default mode",
+			mode:        nlp.DefaultMode,
+			id:          "mock question",
+		},
+		{
+			description: "other mode",
+			mode:        nlp.BulletsMode,
+			id:          "mock question#bullets",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if id := getCachedAnswerID("mock question", test.mode); id != test.id {
+				t.Errorf("incorrect id, received: %s, expected: %s", id, test.id)
+			}
+		})
+	}
+}
+
+func TestStoreModerations(t *testing.T) {
+	mockUpdateItemErr := errors.New("mock update item error")
+
+	tests := []struct {
+		description         string
+		mockUpdateItemError error
+		error               error
+	}{
+		{
+			description:         "error updating item",
+			mockUpdateItemError: mockUpdateItemErr,
+			error:               mockUpdateItemErr,
+		},
+		{
+			description:         "successful invocation",
+			mockUpdateItemError: nil,
+			error:               nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockUpdateItemError: test.mockUpdateItemError,
+				},
+			}
+
+			err := c.StoreModerations(context.Background(), "id", []nlp.Moderation{
+				{
+					Target:     nlp.QuestionTarget,
+					Categories: []string{},
+					Scores: map[string]float64{
+						"violence": 0.01,
+					},
+				},
+			})
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+		})
+	}
+}
+
+func TestGetCachedAnswer(t *testing.T) {
+	mockGetItemErr := errors.New("mock get item error")
+
+	tests := []struct {
+		description       string
+		mockGetItemOutput *dynamodb.GetItemOutput
+		mockGetItemError  error
+		cachedAnswer      *CachedAnswer
+		error             error
+	}{
+		{
+			description:       "error getting item",
+			mockGetItemOutput: nil,
+			mockGetItemError:  mockGetItemErr,
+			cachedAnswer:      nil,
+			error:             mockGetItemErr,
+		},
+		{
+			description:       "no cached answer",
+			mockGetItemOutput: &dynamodb.GetItemOutput{},
+			mockGetItemError:  nil,
+			cachedAnswer:      nil,
+			error:             nil,
+		},
+		{
+			description: "malformed cached answer",
+			mockGetItemOutput: &dynamodb.GetItemOutput{
+				Item: func() map[string]*dynamodb.AttributeValue {
+					item := mockCachedAnswerItem()
+					delete(item, "citations")
+					return item
+				}(),
+			},
+			mockGetItemError: nil,
+			cachedAnswer:     nil,
+			error:            nil,
+		},
+		{
+			description: "successful invocation",
+			mockGetItemOutput: &dynamodb.GetItemOutput{
+				Item: mockCachedAnswerItem(),
+			},
+			mockGetItemError: nil,
+			cachedAnswer: func() *CachedAnswer {
+				cachedAnswer := mockCachedAnswer()
+				return &cachedAnswer
+			}(),
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockGetItemOutput: test.mockGetItemOutput,
+					mockGetItemError:  test.mockGetItemError,
+				},
+			}
+
+			cachedAnswer, err := c.GetCachedAnswer(context.Background(), "mock question", nlp.DefaultMode)
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(cachedAnswer, test.cachedAnswer) {
+				t.Errorf("incorrect cached answer, received: %+v, expected: %+v", cachedAnswer, test.cachedAnswer)
+			}
+		})
+	}
+}
+
+func TestGetCachedAnswers(t *testing.T) {
+	mockScanErr := errors.New("mock scan error")
+
+	tests := []struct {
+		description    string
+		mockScanOutput *dynamodb.ScanOutput
+		mockScanError  error
+		cachedAnswers  []CachedAnswer
+		error          error
+	}{
+		{
+			description:    "error scanning table",
+			mockScanOutput: nil,
+			mockScanError:  mockScanErr,
+			cachedAnswers:  nil,
+			error:          mockScanErr,
+		},
+		{
+			description: "successful invocation",
+			mockScanOutput: &dynamodb.ScanOutput{
+				Items: []map[string]*dynamodb.AttributeValue{
+					mockCachedAnswerItem(),
+					func() map[string]*dynamodb.AttributeValue {
+						item := mockCachedAnswerItem()
+						delete(item, "embedding")
+						return item
+					}(),
+					func() map[string]*dynamodb.AttributeValue {
+						item := mockCachedAnswerItem()
+						item["grounding"].S = aws.String("{")
+						return item
+					}(),
+				},
+			},
+			mockScanError: nil,
+			cachedAnswers: []CachedAnswer{
+				mockCachedAnswer(),
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockScanOutput: test.mockScanOutput,
+					mockScanError:  test.mockScanError,
+				},
+			}
+
+			cachedAnswers, err := c.GetCachedAnswers(context.Background())
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(cachedAnswers, test.cachedAnswers) {
+				t.Errorf("incorrect cached answers, received: %+v, expected: %+v", cachedAnswers, test.cachedAnswers)
+			}
+		})
+	}
+}
+
+func TestStoreCachedAnswer(t *testing.T) {
+	mockPutItemErr := errors.New("mock put item error")
+
+	tests := []struct {
+		description      string
+		mockPutItemError error
+		error            error
+	}{
+		{
+			description:      "error putting item",
+			mockPutItemError: mockPutItemErr,
+			error:            mockPutItemErr,
+		},
+		{
+			description:      "successful invocation",
+			mockPutItemError: nil,
+			error:            nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockPutItemError: test.mockPutItemError,
+				},
+			}
+
+			err := c.StoreCachedAnswer(context.Background(), mockCachedAnswer())
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+		})
+	}
+}
+
+func TestStoreUsage(t *testing.T) {
+	mockUpdateItemErr := errors.New("mock update item error")
+
+	tests := []struct {
+		description         string
+		mockUpdateItemError error
+		error               error
+	}{
+		{
+			description:         "error updating item",
+			mockUpdateItemError: mockUpdateItemErr,
+			error:               mockUpdateItemErr,
+		},
+		{
+			description:         "successful invocation",
+			mockUpdateItemError: nil,
+			error:               nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockUpdateItemError: test.mockUpdateItemError,
+				},
+			}
+
+			err := c.StoreUsage(context.Background(), mockUsage())
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+		})
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	mockScanErr := errors.New("mock scan error")
+
+	tests := []struct {
+		description    string
+		mockScanOutput *dynamodb.ScanOutput
+		mockScanError  error
+		usage          []Usage
+		error          error
+	}{
+		{
+			description:    "error scanning table",
+			mockScanOutput: nil,
+			mockScanError:  mockScanErr,
+			usage:          nil,
+			error:          mockScanErr,
+		},
+		{
+			description: "successful invocation",
+			mockScanOutput: &dynamodb.ScanOutput{
+				Items: []map[string]*dynamodb.AttributeValue{
+					getUsageAttributesWithID(mockUsage()),
+				},
+			},
+			mockScanError: nil,
+			usage: []Usage{
+				mockUsage(),
+				mockUsage(),
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockScanOutput: test.mockScanOutput,
+					mockScanError:  test.mockScanError,
+				},
+			}
+
+			usage, err := c.GetUsage(context.Background())
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(usage, test.usage) {
+				t.Errorf("incorrect usage, received: %+v, expected: %+v", usage, test.usage)
+			}
+		})
+	}
+}
+
+func mockUsage() Usage {
+	return Usage{
+		ID:        "id",
+		Endpoint:  "question",
+		Timestamp: time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC),
+		Tokens: []nlp.Usage{
+			{
+				Model:            "gpt-4o-mini",
+				PromptTokens:     1200,
+				CompletionTokens: 150,
+			},
+		},
+	}
+}
+
+func getUsageAttributesWithID(usage Usage) map[string]*dynamodb.AttributeValue {
+	item := getUsageAttributes(usage)
+	item["id"] = &dynamodb.AttributeValue{
+		S: aws.String(usage.ID),
+	}
+	return item
+}
+
+func TestGetSpend(t *testing.T) {
+	mockGetItemErr := errors.New("mock get item error")
+
+	tests := []struct {
+		description       string
+		mockGetItemOutput *dynamodb.GetItemOutput
+		mockGetItemError  error
+		spend             float64
+		error             error
+	}{
+		{
+			description:       "error getting item",
+			mockGetItemOutput: nil,
+			mockGetItemError:  mockGetItemErr,
+			spend:             0,
+			error:             mockGetItemErr,
+		},
+		{
+			description:       "no spend in month",
+			mockGetItemOutput: &dynamodb.GetItemOutput{},
+			mockGetItemError:  nil,
+			spend:             0,
+			error:             nil,
+		},
+		{
+			description: "successful invocation",
+			mockGetItemOutput: &dynamodb.GetItemOutput{
+				Item: map[string]*dynamodb.AttributeValue{
+					"spend": {
+						N: aws.String("12.5"),
+					},
+				},
+			},
+			mockGetItemError: nil,
+			spend:            12.5,
+			error:            nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockGetItemOutput: test.mockGetItemOutput,
+					mockGetItemError:  test.mockGetItemError,
+				},
+			}
+
+			spend, err := c.GetSpend(context.Background(), "2023-04")
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if spend != test.spend {
+				t.Errorf("incorrect spend, received: %f, expected: %f", spend, test.spend)
+			}
+		})
+	}
+}
+
+func TestAddSpend(t *testing.T) {
+	mockUpdateItemErr := errors.New("mock update item error")
+
+	tests := []struct {
+		description          string
+		mockUpdateItemOutput *dynamodb.UpdateItemOutput
+		mockUpdateItemError  error
+		spend                float64
+		error                error
+	}{
+		{
+			description:          "error updating item",
+			mockUpdateItemOutput: nil,
+			mockUpdateItemError:  mockUpdateItemErr,
+			spend:                0,
+			error:                mockUpdateItemErr,
+		},
+		{
+			description: "successful invocation",
+			mockUpdateItemOutput: &dynamodb.UpdateItemOutput{
+				Attributes: map[string]*dynamodb.AttributeValue{
+					"spend": {
+						N: aws.String("12.75"),
+					},
+				},
+			},
+			mockUpdateItemError: nil,
+			spend:               12.75,
+			error:               nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockUpdateItemOutput: test.mockUpdateItemOutput,
+					mockUpdateItemError:  test.mockUpdateItemError,
+				},
+			}
+
+			spend, err := c.AddSpend(context.Background(), "2023-04", 0.25)
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if spend != test.spend {
+				t.Errorf("incorrect spend, received: %f, expected: %f", spend, test.spend)
+			}
+		})
+	}
+}
+
+func TestGetSession(t *testing.T) {
+	mockGetItemErr := errors.New("mock get item error")
+
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		description       string
+		mockGetItemOutput *dynamodb.GetItemOutput
+		mockGetItemError  error
+		turns             []nlp.Turn
+		error             error
+	}{
+		{
+			description:       "error getting item",
+			mockGetItemOutput: nil,
+			mockGetItemError:  mockGetItemErr,
+			turns:             nil,
+			error:             mockGetItemErr,
+		},
+		{
+			description:       "no session stored",
+			mockGetItemOutput: &dynamodb.GetItemOutput{},
+			mockGetItemError:  nil,
+			turns:             []nlp.Turn{},
+			error:             nil,
+		},
+		{
+			description: "expired session",
+			mockGetItemOutput: &dynamodb.GetItemOutput{
+				Item: map[string]*dynamodb.AttributeValue{
+					"turns": {
+						S: aws.String(`[{"question": "question", "answer": "Answer."}]`),
+					},
+					"expires_at": {
+						N: aws.String(past),
+					},
+				},
+			},
+			mockGetItemError: nil,
+			turns:            []nlp.Turn{},
+			error:            nil,
+		},
+		{
+			description: "successful invocation",
+			mockGetItemOutput: &dynamodb.GetItemOutput{
+				Item: map[string]*dynamodb.AttributeValue{
+					"turns": {
+						S: aws.String(`[{"question": "question", "answer": "Answer."}]`),
+					},
+					"expires_at": {
+						N: aws.String(future),
+					},
+				},
+			},
+			mockGetItemError: nil,
+			turns: []nlp.Turn{
+				{
+					Question: "question",
+					Answer:   "Answer.",
+				},
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockGetItemOutput: test.mockGetItemOutput,
+					mockGetItemError:  test.mockGetItemError,
+				},
+			}
+
+			session, err := c.GetSession(context.Background(), "session_id")
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if session != nil && !reflect.DeepEqual(session.Turns, test.turns) {
+				t.Errorf("incorrect turns, received: %+v, expected: %+v", session.Turns, test.turns)
+			}
+		})
+	}
+}
+
+func TestStoreSession(t *testing.T) {
+	mockPutItemErr := errors.New("mock put item error")
+
+	tests := []struct {
+		description      string
+		mockPutItemError error
+		error            error
+	}{
+		{
+			description:      "error putting item",
+			mockPutItemError: mockPutItemErr,
+			error:            mockPutItemErr,
+		},
+		{
+			description:      "successful invocation",
+			mockPutItemError: nil,
+			error:            nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				dynamoDBClient: &mockDynamoDBClient{
+					mockPutItemError: test.mockPutItemError,
+				},
+			}
+
+			err := c.StoreSession(context.Background(), Session{
+				ID: "session_id",
+				Turns: []nlp.Turn{
+					{
+						Question: "question",
+						Answer:   "Answer.",
+					},
+				},
+				ExpiresAt: time.Now().Add(time.Hour),
+			})
 
 			if err != test.error {
 				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
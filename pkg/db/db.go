@@ -2,8 +2,10 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
 )
 
 // Databaser defines methods for interacting with the
@@ -11,19 +13,122 @@ import (
 type Databaser interface {
 	GetIDs(ctx context.Context) ([]string, error)
 	GetSummaries(ctx context.Context) ([]Summary, error)
+	GetSummariesByIDs(ctx context.Context, ids []string) ([]Summary, error)
 	StoreSummaries(ctx context.Context, summaries []Summary) error
+	StoreRelated(ctx context.Context, id string, related []Related) error
+	StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error
 	StoreText(ctx context.Context, id, text string) error
 	GetDocuments(ctx context.Context) ([]dct.Document, error)
 	StoreDocuments(ctx context.Context, answers []dct.Document) error
 	StoreQuestion(ctx context.Context, id, question string) error
-	StoreAnswer(ctx context.Context, id, answer string) error
+	StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error
+	StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error
+	GetCachedAnswer(ctx context.Context, question, mode string) (*CachedAnswer, error)
+	GetCachedAnswers(ctx context.Context) ([]CachedAnswer, error)
+	StoreCachedAnswer(ctx context.Context, cachedAnswer CachedAnswer) error
+	StoreUsage(ctx context.Context, usage Usage) error
+	GetUsage(ctx context.Context) ([]Usage, error)
+	GetSpend(ctx context.Context, month string) (float64, error)
+	AddSpend(ctx context.Context, month string, amount float64) (float64, error)
+	GetSession(ctx context.Context, id string) (*Session, error)
+	StoreSession(ctx context.Context, session Session) error
 }
 
 // Summary represents a row in the summaries table.
+//
+// Published is the month the essay was published formatted with
+// PublishedLayout and is empty for essays without a date. It is
+// set along with WordCount and ReadingMinutes on summaries of
+// essays parsed into structured content. PromptVersion and Usage
+// are only set on summaries generated with versioned prompts and
+// usage accounting and are not included in API responses.
+// Related is set once the essay similarities have been generated
+// by the summaries CLI and Topics once the essays have been
+// clustered by the topics CLI.
 type Summary struct {
-	ID      string `json:"id"`
-	URL     string `json:"url"`
-	Title   string `json:"title"`
-	Summary string `json:"summary"`
-	Number  int    `json:"number"`
+	ID             string    `json:"id"`
+	URL            string    `json:"url"`
+	Title          string    `json:"title"`
+	Summary        string    `json:"summary"`
+	Number         int       `json:"number"`
+	Published      string    `json:"published,omitempty"`
+	WordCount      int       `json:"word_count,omitempty"`
+	ReadingMinutes int       `json:"reading_minutes,omitempty"`
+	Related        []Related `json:"related,omitempty"`
+	Topics         []string  `json:"topics,omitempty"`
+	PromptVersion  string    `json:"-"`
+	Usage          *Usage    `json:"-"`
+}
+
+// PublishedLayout is the layout of the Summary Published month.
+const PublishedLayout = "2006-01"
+
+// Related represents an essay similar to the essay of a summary
+// ordered by the similarity Score of their texts.
+type Related struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// Topic represents a theme shared by a group of essays and the
+// summaries of those essays.
+type Topic struct {
+	Name   string    `json:"name"`
+	Essays []Summary `json:"essays"`
+}
+
+// Timeline represents a page of the years essays were published
+// in, from newest to oldest, along with the number of pages and
+// the number of essays without a publication month.
+type Timeline struct {
+	Years   []Year `json:"years"`
+	Undated int    `json:"undated"`
+	Page    int    `json:"page"`
+	Pages   int    `json:"pages"`
+}
+
+// Year represents the essays published in a year grouped by
+// month along with their number and total words.
+type Year struct {
+	Year   int     `json:"year"`
+	Count  int     `json:"count"`
+	Words  int     `json:"words"`
+	Months []Month `json:"months"`
+}
+
+// Month represents the summaries of the essays published in a
+// month formatted with PublishedLayout.
+type Month struct {
+	Month  string    `json:"month"`
+	Essays []Summary `json:"essays"`
+}
+
+// Usage represents the LLM provider tokens used to generate the
+// answer or summary stored in a row and the endpoint which
+// generated it.
+type Usage struct {
+	ID        string      `json:"id"`
+	Endpoint  string      `json:"endpoint"`
+	Timestamp time.Time   `json:"timestamp"`
+	Tokens    []nlp.Usage `json:"tokens"`
+}
+
+// CachedAnswer represents an earlier answer stored in the
+// "cache" table for reuse with repeated questions.
+//
+// Question is the normalized question text and Embedding is
+// its embedding vector. Answers are cached separately for each
+// answer mode.
+type CachedAnswer struct {
+	Question  string     `json:"question"`
+	Embedding []float64  `json:"embedding"`
+	Answer    nlp.Answer `json:"answer"`
+}
+
+// Session represents the earlier turns of a conversation stored
+// in the "sessions" table until ExpiresAt.
+type Session struct {
+	ID        string     `json:"id"`
+	Turns     []nlp.Turn `json:"turns"`
+	ExpiresAt time.Time  `json:"expires_at"`
 }
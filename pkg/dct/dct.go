@@ -1,7 +1,117 @@
 package dct
 
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
 // Document represents a row in the documents.jsonl file.
 type Document struct {
 	Text     string `json:"text"`
 	Metadata string `json:"metadata"`
 }
+
+var sentenceRegexp = regexp.MustCompile(`\w\.\w`)
+
+// sentenceEndRegexp matches the terminal punctuation and closing
+// quotes or brackets of a sentence followed by whitespace.
+var sentenceEndRegexp = regexp.MustCompile(`[.!?]+["'”’)\]]*\s+`)
+
+const sentenceEnds = `.!?"'”’)]`
+
+// listMarkers are trimmed from the start of lines by SplitLines.
+const listMarkers = "-*• "
+
+// SplitParagraphs splits the text of the provided documents into
+// paragraph documents that keep the metadata of their source.
+func SplitParagraphs(documents []Document) []Document {
+	paragraphs := []Document{}
+	for _, document := range documents {
+		text := sentenceRegexp.ReplaceAllStringFunc(document.Text, replaceFunc)
+		for _, paragraph := range strings.Split(text, ".\n") {
+			if paragraph == "" {
+				continue
+			}
+
+			paragraphs = append(paragraphs, Document{
+				Text:     paragraph,
+				Metadata: document.Metadata,
+			})
+		}
+	}
+
+	return paragraphs
+}
+
+// SplitLines splits the text of the provided documents into
+// documents for each non-empty line with any list marker such as
+// "-" or "*" removed that keep the metadata of their source, so
+// that bullet points are split as their own paragraphs.
+func SplitLines(documents []Document) []Document {
+	lines := []Document{}
+	for _, document := range documents {
+		for _, line := range strings.Split(document.Text, "\n") {
+			line = strings.TrimLeft(strings.TrimSpace(line), listMarkers)
+			if line == "" {
+				continue
+			}
+
+			lines = append(lines, Document{
+				Text:     line,
+				Metadata: document.Metadata,
+			})
+		}
+	}
+
+	return lines
+}
+
+// SplitSentences splits the text of the provided paragraph
+// documents into sentence documents that keep the metadata of
+// their source.
+//
+// The final period removed from paragraphs by SplitParagraphs is
+// restored so that each sentence reads as it does in the essay.
+func SplitSentences(paragraphs []Document) []Document {
+	sentences := []Document{}
+	for _, paragraph := range paragraphs {
+		text := strings.TrimSpace(paragraph.Text)
+		for text != "" {
+			sentence := text
+			if location := sentenceEndRegexp.FindStringIndex(text); location != nil {
+				sentence = text[:location[1]]
+			}
+			text = text[len(sentence):]
+
+			sentence = strings.Join(strings.Fields(sentence), " ")
+			if last, _ := utf8.DecodeLastRuneInString(sentence); !strings.ContainsRune(sentenceEnds, last) {
+				sentence += "."
+			}
+
+			sentences = append(sentences, Document{
+				Text:     sentence,
+				Metadata: paragraph.Metadata,
+			})
+		}
+	}
+
+	return sentences
+}
+
+// SentencesEnd returns the length of the complete sentences at
+// the start of the text, ended as in SplitSentences by terminal
+// punctuation followed by whitespace, or 0 when there are none.
+// Decimals such as "3.5" do not end a sentence.
+func SentencesEnd(text string) int {
+	locations := sentenceEndRegexp.FindAllStringIndex(text, -1)
+	if len(locations) == 0 {
+		return 0
+	}
+
+	return locations[len(locations)-1][1]
+}
+
+func replaceFunc(input string) string {
+	return strings.Replace(input, ".", ".\n", -1)
+}
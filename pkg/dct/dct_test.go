@@ -0,0 +1,193 @@
+package dct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitParagraphs(t *testing.T) {
+	tests := []struct {
+		description string
+		documents   []Document
+		paragraphs  []Document
+	}{
+		{
+			description: "no documents",
+			documents:   []Document{},
+			paragraphs:  []Document{},
+		},
+		{
+			description: "multiple sentences split",
+			documents: []Document{
+				{
+					Text:     "First sentence.Second sentence.\nThird sentence",
+					Metadata: "mock_id",
+				},
+			},
+			paragraphs: []Document{
+				{
+					Text:     "First sentence",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Second sentence",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Third sentence",
+					Metadata: "mock_id",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			paragraphs := SplitParagraphs(test.documents)
+			if !reflect.DeepEqual(paragraphs, test.paragraphs) {
+				t.Errorf("incorrect paragraphs, received: %+v, expected: %+v", paragraphs, test.paragraphs)
+			}
+		})
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		description string
+		documents   []Document
+		lines       []Document
+	}{
+		{
+			description: "no documents",
+			documents:   []Document{},
+			lines:       []Document{},
+		},
+		{
+			description: "bullet points split",
+			documents: []Document{
+				{
+					Text:     "Intro line.\n\n- First point\n * Second point.\n• ",
+					Metadata: "mock_id",
+				},
+			},
+			lines: []Document{
+				{
+					Text:     "Intro line.",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "First point",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Second point.",
+					Metadata: "mock_id",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			lines := SplitLines(test.documents)
+			if !reflect.DeepEqual(lines, test.lines) {
+				t.Errorf("incorrect lines, received: %+v, expected: %+v", lines, test.lines)
+			}
+		})
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		description string
+		paragraphs  []Document
+		sentences   []Document
+	}{
+		{
+			description: "no paragraphs",
+			paragraphs:  []Document{},
+			sentences:   []Document{},
+		},
+		{
+			description: "multiple sentences split",
+			paragraphs: []Document{
+				{
+					Text:     "Startups are hard. Why?  Most \"fail.\" Growth matters",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Make something people want!",
+					Metadata: "other_id",
+				},
+			},
+			sentences: []Document{
+				{
+					Text:     "Startups are hard.",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Why?",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Most \"fail.\"",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Growth matters.",
+					Metadata: "mock_id",
+				},
+				{
+					Text:     "Make something people want!",
+					Metadata: "other_id",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			sentences := SplitSentences(test.paragraphs)
+			if !reflect.DeepEqual(sentences, test.sentences) {
+				t.Errorf("incorrect sentences, received: %+v, expected: %+v", sentences, test.sentences)
+			}
+		})
+	}
+}
+
+func TestSentencesEnd(t *testing.T) {
+	tests := []struct {
+		description string
+		text        string
+		end         int
+	}{
+		{
+			description: "no sentence end",
+			text:        "Startups are hard",
+			end:         0,
+		},
+		{
+			description: "sentence end without whitespace",
+			text:        "Startups are hard.",
+			end:         0,
+		},
+		{
+			description: "decimal number",
+			text:        "It costs 3.5 times more",
+			end:         0,
+		},
+		{
+			description: "multiple sentence ends",
+			text:        "Startups are hard. Why? Most \"fail.\" Growth",
+			end:         len("Startups are hard. Why? Most \"fail.\" "),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if end := SentencesEnd(test.text); end != test.end {
+				t.Errorf("incorrect end, received: %d, expected: %d", end, test.end)
+			}
+		})
+	}
+}
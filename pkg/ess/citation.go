@@ -0,0 +1,46 @@
+package ess
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+// AddCitationDetails populates the title and URL of the answer
+// citations or quotes from the stored summaries, falling back to
+// the essay URL for essays without a summary.
+func AddCitationDetails(ctx context.Context, dbClient db.Databaser, citations []nlp.Citation) error {
+	if len(citations) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(citations))
+	for i, citation := range citations {
+		ids[i] = citation.ID
+	}
+
+	summaries, err := dbClient.GetSummariesByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	summariesByID := map[string]db.Summary{}
+	for _, summary := range summaries {
+		summariesByID[summary.ID] = summary
+	}
+
+	for i, citation := range citations {
+		summary, ok := summariesByID[citation.ID]
+		if !ok {
+			citations[i].URL = fmt.Sprintf("http://www.paulgraham.com/%s.html", citation.ID)
+			continue
+		}
+
+		citations[i].Title = summary.Title
+		citations[i].URL = summary.URL
+	}
+
+	return nil
+}
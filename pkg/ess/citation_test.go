@@ -0,0 +1,96 @@
+package ess
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+func TestAddCitationDetails(t *testing.T) {
+	mockGetSummariesByIDsErr := errors.New("mock get summaries by ids error")
+
+	tests := []struct {
+		description                string
+		citations                  []nlp.Citation
+		mockGetSummariesByIDsError error
+		output                     []nlp.Citation
+		error                      error
+	}{
+		{
+			description:                "no citations",
+			citations:                  []nlp.Citation{},
+			mockGetSummariesByIDsError: mockGetSummariesByIDsErr,
+			output:                     []nlp.Citation{},
+			error:                      nil,
+		},
+		{
+			description: "error getting summaries",
+			citations: []nlp.Citation{
+				{
+					ID: "startupideas",
+				},
+			},
+			mockGetSummariesByIDsError: mockGetSummariesByIDsErr,
+			output: []nlp.Citation{
+				{
+					ID: "startupideas",
+				},
+			},
+			error: mockGetSummariesByIDsErr,
+		},
+		{
+			description: "successful invocation",
+			citations: []nlp.Citation{
+				{
+					ID:      "startupideas",
+					Excerpt: "Live in the future.",
+				},
+				{
+					ID: "missing",
+				},
+			},
+			mockGetSummariesByIDsError: nil,
+			output: []nlp.Citation{
+				{
+					ID:      "startupideas",
+					Title:   "How to Get Startup Ideas",
+					URL:     "http://www.paulgraham.com/startupideas.html",
+					Excerpt: "Live in the future.",
+				},
+				{
+					ID:  "missing",
+					URL: "http://www.paulgraham.com/missing.html",
+				},
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockSummaries: []db.Summary{
+					{
+						ID:    "startupideas",
+						URL:   "http://www.paulgraham.com/startupideas.html",
+						Title: "How to Get Startup Ideas",
+					},
+				},
+				mockGetSummariesByIDsError: test.mockGetSummariesByIDsError,
+			}
+
+			err := AddCitationDetails(context.Background(), dbClient, test.citations)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(test.citations, test.output) {
+				t.Errorf("incorrect citations, received: %+v, expected: %+v", test.citations, test.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package ess
+
+import "strconv"
+
+// getQueryNumber returns the number in the query parameter value
+// and defaultNumber when it is empty or invalidErr when it is not
+// a number between 1 and max.
+func getQueryNumber(value string, defaultNumber, max int, invalidErr error) (int, error) {
+	if value == "" {
+		return defaultNumber, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 || number > max {
+		return 0, invalidErr
+	}
+
+	return number, nil
+}
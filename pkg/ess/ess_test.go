@@ -0,0 +1,168 @@
+package ess
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+type mockDBClient struct {
+	mockSummaries              []db.Summary
+	mockGetSummariesByIDsError error
+}
+
+func (m *mockDBClient) GetIDs(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummaries(ctx context.Context) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummariesByIDs(ctx context.Context, ids []string) ([]db.Summary, error) {
+	if m.mockGetSummariesByIDsError != nil {
+		return nil, m.mockGetSummariesByIDsError
+	}
+
+	summaries := []db.Summary{}
+	for _, summary := range m.mockSummaries {
+		for _, id := range ids {
+			if summary.ID == id {
+				summaries = append(summaries, summary)
+			}
+		}
+	}
+
+	return summaries, nil
+}
+
+func (m *mockDBClient) StoreSummaries(ctx context.Context, summaries []db.Summary) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreRelated(ctx context.Context, id string, related []db.Related) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreText(ctx context.Context, id, text string) error {
+	return nil
+}
+
+func (m *mockDBClient) GetDocuments(ctx context.Context) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreQuestion(ctx context.Context, id, question string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	return nil
+}
+
+func (m *mockDBClient) GetCachedAnswer(ctx context.Context, question, mode string) (*db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetCachedAnswers(ctx context.Context) ([]db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreCachedAnswer(ctx context.Context, cachedAnswer db.CachedAnswer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreUsage(ctx context.Context, usage db.Usage) error {
+	return nil
+}
+
+func (m *mockDBClient) GetUsage(ctx context.Context) ([]db.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSpend(ctx context.Context, month string) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) GetSession(ctx context.Context, id string) (*db.Session, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSession(ctx context.Context, session db.Session) error {
+	return nil
+}
+
+func Test_getQueryNumber(t *testing.T) {
+	invalidErr := errors.New("mock invalid error")
+
+	tests := []struct {
+		description string
+		value       string
+		number      int
+		error       error
+	}{
+		{
+			description: "empty value",
+			value:       "",
+			number:      5,
+			error:       nil,
+		},
+		{
+			description: "not a number",
+			value:       "five",
+			number:      0,
+			error:       invalidErr,
+		},
+		{
+			description: "number under bound",
+			value:       "0",
+			number:      0,
+			error:       invalidErr,
+		},
+		{
+			description: "number over bound",
+			value:       "11",
+			number:      0,
+			error:       invalidErr,
+		},
+		{
+			description: "successful invocation",
+			value:       "10",
+			number:      10,
+			error:       nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			number, err := getQueryNumber(test.value, 5, 10, invalidErr)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if number != test.number {
+				t.Errorf("incorrect number, received: %d, expected: %d", number, test.number)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package ess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+const defaultRelatedCount = 5
+
+// MaxRelatedCount is the largest number of related essays which
+// are stored for and can be requested for an essay.
+const MaxRelatedCount = 10
+
+// ErrInvalidCount is returned for related essay counts which are
+// not numbers within the supported range.
+var ErrInvalidCount = fmt.Errorf("count must be a number between 1 and %d", MaxRelatedCount)
+
+// ErrEssayNotFound is returned for essay IDs without a stored
+// summary.
+var ErrEssayNotFound = errors.New("essay not found")
+
+// GetRelatedCount returns the number of related essays requested
+// and the default number when none was requested.
+func GetRelatedCount(value string) (int, error) {
+	return getQueryNumber(value, defaultRelatedCount, MaxRelatedCount, ErrInvalidCount)
+}
+
+// GetRelatedSummaries returns the summaries of up to count of the
+// essays most similar to the essay of the provided ID ordered by
+// their similarity. Related essays without a stored summary are
+// skipped.
+func GetRelatedSummaries(ctx context.Context, dbClient db.Databaser, id string, count int) ([]db.Summary, error) {
+	summaries, err := dbClient.GetSummariesByIDs(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+
+	var essay *db.Summary
+	for i := range summaries {
+		if summaries[i].ID == id {
+			essay = &summaries[i]
+		}
+	}
+
+	if essay == nil {
+		return nil, ErrEssayNotFound
+	}
+
+	related := essay.Related
+	if len(related) > count {
+		related = related[:count]
+	}
+
+	if len(related) == 0 {
+		return []db.Summary{}, nil
+	}
+
+	ids := make([]string, len(related))
+	for i, relatedEssay := range related {
+		ids[i] = relatedEssay.ID
+	}
+
+	relatedSummaries, err := dbClient.GetSummariesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	summariesByID := map[string]db.Summary{}
+	for _, summary := range relatedSummaries {
+		summariesByID[summary.ID] = summary
+	}
+
+	output := []db.Summary{}
+	for _, relatedID := range ids {
+		if summary, ok := summariesByID[relatedID]; ok {
+			output = append(output, summary)
+		}
+	}
+
+	return output, nil
+}
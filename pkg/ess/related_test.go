@@ -0,0 +1,160 @@
+package ess
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+func TestGetRelatedCount(t *testing.T) {
+	tests := []struct {
+		description string
+		value       string
+		count       int
+		error       error
+	}{
+		{
+			description: "default count",
+			value:       "",
+			count:       defaultRelatedCount,
+			error:       nil,
+		},
+		{
+			description: "count over bound",
+			value:       "11",
+			count:       0,
+			error:       ErrInvalidCount,
+		},
+		{
+			description: "successful invocation",
+			value:       "3",
+			count:       3,
+			error:       nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			count, err := GetRelatedCount(test.value)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if count != test.count {
+				t.Errorf("incorrect count, received: %d, expected: %d", count, test.count)
+			}
+		})
+	}
+}
+
+func TestGetRelatedSummaries(t *testing.T) {
+	mockGetSummariesByIDsErr := errors.New("mock get summaries by ids error")
+
+	startupIdeas := db.Summary{
+		ID: "startupideas",
+		Related: []db.Related{
+			{
+				ID:    "growth",
+				Score: 0.8,
+			},
+			{
+				ID:    "missing",
+				Score: 0.6,
+			},
+			{
+				ID:    "ramenprofitable",
+				Score: 0.4,
+			},
+		},
+	}
+	growth := db.Summary{
+		ID: "growth",
+	}
+	ramen := db.Summary{
+		ID: "ramenprofitable",
+	}
+	unrelated := db.Summary{
+		ID: "unrelated",
+	}
+
+	tests := []struct {
+		description                string
+		id                         string
+		count                      int
+		mockGetSummariesByIDsError error
+		summaries                  []db.Summary
+		error                      error
+	}{
+		{
+			description:                "error getting summaries",
+			id:                         "startupideas",
+			count:                      5,
+			mockGetSummariesByIDsError: mockGetSummariesByIDsErr,
+			summaries:                  nil,
+			error:                      mockGetSummariesByIDsErr,
+		},
+		{
+			description:                "essay not found",
+			id:                         "missing",
+			count:                      5,
+			mockGetSummariesByIDsError: nil,
+			summaries:                  nil,
+			error:                      ErrEssayNotFound,
+		},
+		{
+			description:                "no related essays",
+			id:                         "unrelated",
+			count:                      5,
+			mockGetSummariesByIDsError: nil,
+			summaries:                  []db.Summary{},
+			error:                      nil,
+		},
+		{
+			description:                "related essays limited to count",
+			id:                         "startupideas",
+			count:                      1,
+			mockGetSummariesByIDsError: nil,
+			summaries: []db.Summary{
+				growth,
+			},
+			error: nil,
+		},
+		{
+			description:                "successful invocation",
+			id:                         "startupideas",
+			count:                      5,
+			mockGetSummariesByIDsError: nil,
+			summaries: []db.Summary{
+				growth,
+				ramen,
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockSummaries: []db.Summary{
+					ramen,
+					unrelated,
+					growth,
+					startupIdeas,
+				},
+				mockGetSummariesByIDsError: test.mockGetSummariesByIDsError,
+			}
+
+			summaries, err := GetRelatedSummaries(context.Background(), dbClient, test.id, test.count)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(summaries, test.summaries) {
+				t.Errorf("incorrect summaries, received: %+v, expected: %+v", summaries, test.summaries)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package ess
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+const (
+	// NumberSort orders summaries by their RSS feed number.
+	NumberSort = "number"
+
+	// PublishedSort orders summaries by their publication month.
+	PublishedSort = "published"
+)
+
+// ErrInvalidSort is returned for unsupported summary orders.
+var ErrInvalidSort = fmt.Errorf("sort must be one of %s, %s", NumberSort, PublishedSort)
+
+// SortSummaries orders the summaries from newest to oldest by
+// NumberSort, the default, or PublishedSort, which places essays
+// without a publication month last in NumberSort order.
+func SortSummaries(summaries []db.Summary, by string) error {
+	if by != "" && by != NumberSort && by != PublishedSort {
+		return ErrInvalidSort
+	}
+
+	sortSummaries(summaries, by)
+
+	return nil
+}
+
+func sortSummaries(summaries []db.Summary, by string) {
+	sort.SliceStable(summaries, func(x, y int) bool {
+		if by == PublishedSort && summaries[x].Published != summaries[y].Published {
+			return summaries[x].Published > summaries[y].Published
+		}
+		return summaries[x].Number > summaries[y].Number
+	})
+}
@@ -0,0 +1,93 @@
+package ess
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+func TestSortSummaries(t *testing.T) {
+	tests := []struct {
+		description string
+		by          string
+		ids         []string
+		error       error
+	}{
+		{
+			description: "invalid sort",
+			by:          "title",
+			ids: []string{
+				"older",
+				"undated",
+				"newer",
+			},
+			error: ErrInvalidSort,
+		},
+		{
+			description: "default sort",
+			by:          "",
+			ids: []string{
+				"undated",
+				"older",
+				"newer",
+			},
+			error: nil,
+		},
+		{
+			description: "number sort",
+			by:          NumberSort,
+			ids: []string{
+				"undated",
+				"older",
+				"newer",
+			},
+			error: nil,
+		},
+		{
+			description: "published sort",
+			by:          PublishedSort,
+			ids: []string{
+				"newer",
+				"older",
+				"undated",
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			summaries := []db.Summary{
+				{
+					ID:        "older",
+					Number:    2,
+					Published: "2009-05",
+				},
+				{
+					ID:     "undated",
+					Number: 3,
+				},
+				{
+					ID:        "newer",
+					Number:    1,
+					Published: "2020-01",
+				},
+			}
+
+			err := SortSummaries(summaries, test.by)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			ids := make([]string, len(summaries))
+			for i, summary := range summaries {
+				ids[i] = summary.ID
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("incorrect order, received: %v, expected: %v", ids, test.ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package ess
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+const (
+	defaultTimelineYears = 5
+	maxTimelineYears     = 20
+)
+
+var (
+	// ErrInvalidPage is returned for timeline pages which are not
+	// positive numbers.
+	ErrInvalidPage = errors.New("page must be a positive number")
+
+	// ErrInvalidYears is returned for timeline page sizes which
+	// are not numbers within the supported range.
+	ErrInvalidYears = fmt.Errorf("years must be a number between 1 and %d", maxTimelineYears)
+)
+
+// GetTimelinePagination returns the timeline page requested and
+// the number of years on each page, defaulting to the first page
+// of defaultTimelineYears years.
+func GetTimelinePagination(page, years string) (int, int, error) {
+	pageNumber, err := getQueryNumber(page, 1, math.MaxInt32, ErrInvalidPage)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	yearsNumber, err := getQueryNumber(years, defaultTimelineYears, maxTimelineYears, ErrInvalidYears)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return pageNumber, yearsNumber, nil
+}
+
+// GetTimeline groups the summaries of essays with a publication
+// month by year and month from newest to oldest and returns the
+// requested page of years. Essays within a month are ordered by
+// their RSS feed number and pages past the last one are empty.
+func GetTimeline(summaries []db.Summary, page, years int) db.Timeline {
+	timeline := db.Timeline{
+		Years: []db.Year{},
+		Page:  page,
+	}
+
+	sorted := make([]db.Summary, len(summaries))
+	copy(sorted, summaries)
+	sortSummaries(sorted, PublishedSort)
+
+	allYears := []db.Year{}
+	for _, summary := range sorted {
+		published, err := time.Parse(db.PublishedLayout, summary.Published)
+		if err != nil {
+			timeline.Undated++
+			continue
+		}
+
+		if len(allYears) == 0 || allYears[len(allYears)-1].Year != published.Year() {
+			allYears = append(allYears, db.Year{
+				Year:   published.Year(),
+				Months: []db.Month{},
+			})
+		}
+		year := &allYears[len(allYears)-1]
+		year.Count++
+		year.Words += summary.WordCount
+
+		if len(year.Months) == 0 || year.Months[len(year.Months)-1].Month != summary.Published {
+			year.Months = append(year.Months, db.Month{
+				Month:  summary.Published,
+				Essays: []db.Summary{},
+			})
+		}
+		month := &year.Months[len(year.Months)-1]
+		month.Essays = append(month.Essays, summary)
+	}
+
+	timeline.Pages = (len(allYears) + years - 1) / years
+
+	start := (page - 1) * years
+	if start < len(allYears) {
+		end := start + years
+		if end > len(allYears) {
+			end = len(allYears)
+		}
+		timeline.Years = allYears[start:end]
+	}
+
+	return timeline
+}
@@ -0,0 +1,185 @@
+package ess
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+func TestGetTimelinePagination(t *testing.T) {
+	tests := []struct {
+		description string
+		page        string
+		years       string
+		pageNumber  int
+		yearsNumber int
+		error       error
+	}{
+		{
+			description: "invalid page",
+			page:        "0",
+			years:       "",
+			pageNumber:  0,
+			yearsNumber: 0,
+			error:       ErrInvalidPage,
+		},
+		{
+			description: "invalid years",
+			page:        "",
+			years:       "21",
+			pageNumber:  0,
+			yearsNumber: 0,
+			error:       ErrInvalidYears,
+		},
+		{
+			description: "default pagination",
+			page:        "",
+			years:       "",
+			pageNumber:  1,
+			yearsNumber: defaultTimelineYears,
+			error:       nil,
+		},
+		{
+			description: "successful invocation",
+			page:        "3",
+			years:       "2",
+			pageNumber:  3,
+			yearsNumber: 2,
+			error:       nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			pageNumber, yearsNumber, err := GetTimelinePagination(test.page, test.years)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if pageNumber != test.pageNumber || yearsNumber != test.yearsNumber {
+				t.Errorf("incorrect pagination, received: %d, %d, expected: %d, %d", pageNumber, yearsNumber, test.pageNumber, test.yearsNumber)
+			}
+		})
+	}
+}
+
+func TestGetTimeline(t *testing.T) {
+	startupIdeas := db.Summary{
+		ID:        "startupideas",
+		Number:    4,
+		Published: "2012-11",
+		WordCount: 1000,
+	}
+	growth := db.Summary{
+		ID:        "growth",
+		Number:    3,
+		Published: "2012-09",
+		WordCount: 500,
+	}
+	ramen := db.Summary{
+		ID:        "ramenprofitable",
+		Number:    2,
+		Published: "2009-07",
+		WordCount: 200,
+	}
+	undated := db.Summary{
+		ID:     "undated",
+		Number: 1,
+	}
+
+	summaries := []db.Summary{
+		undated,
+		ramen,
+		growth,
+		startupIdeas,
+	}
+
+	tests := []struct {
+		description string
+		page        int
+		years       int
+		timeline    db.Timeline
+	}{
+		{
+			description: "first page",
+			page:        1,
+			years:       1,
+			timeline: db.Timeline{
+				Years: []db.Year{
+					{
+						Year:  2012,
+						Count: 2,
+						Words: 1500,
+						Months: []db.Month{
+							{
+								Month: "2012-11",
+								Essays: []db.Summary{
+									startupIdeas,
+								},
+							},
+							{
+								Month: "2012-09",
+								Essays: []db.Summary{
+									growth,
+								},
+							},
+						},
+					},
+				},
+				Undated: 1,
+				Page:    1,
+				Pages:   2,
+			},
+		},
+		{
+			description: "last page",
+			page:        2,
+			years:       1,
+			timeline: db.Timeline{
+				Years: []db.Year{
+					{
+						Year:  2009,
+						Count: 1,
+						Words: 200,
+						Months: []db.Month{
+							{
+								Month: "2009-07",
+								Essays: []db.Summary{
+									ramen,
+								},
+							},
+						},
+					},
+				},
+				Undated: 1,
+				Page:    2,
+				Pages:   2,
+			},
+		},
+		{
+			description: "page past the last",
+			page:        3,
+			years:       1,
+			timeline: db.Timeline{
+				Years:   []db.Year{},
+				Undated: 1,
+				Page:    3,
+				Pages:   2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			timeline := GetTimeline(summaries, test.page, test.years)
+			if !reflect.DeepEqual(timeline, test.timeline) {
+				t.Errorf("incorrect timeline, received: %+v, expected: %+v", timeline, test.timeline)
+			}
+
+			if summaries[0].ID != undated.ID {
+				t.Errorf("incorrect summaries order, received: %+v", summaries)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package ess
+
+import (
+	"sort"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+// GetTopics groups the summaries by their topics, ordering the
+// topics by their number of essays and the essays from newest to
+// oldest. Essays with several topics are listed under each one
+// and essays without topics are not listed.
+func GetTopics(summaries []db.Summary) []db.Topic {
+	topicsByName := map[string]*db.Topic{}
+	for _, summary := range summaries {
+		for _, name := range summary.Topics {
+			topic, ok := topicsByName[name]
+			if !ok {
+				topic = &db.Topic{
+					Name:   name,
+					Essays: []db.Summary{},
+				}
+				topicsByName[name] = topic
+			}
+
+			topic.Essays = append(topic.Essays, summary)
+		}
+	}
+
+	topics := []db.Topic{}
+	for _, topic := range topicsByName {
+		sort.SliceStable(topic.Essays, func(x, y int) bool {
+			return topic.Essays[x].Number > topic.Essays[y].Number
+		})
+
+		topics = append(topics, *topic)
+	}
+
+	sort.SliceStable(topics, func(x, y int) bool {
+		if len(topics[x].Essays) == len(topics[y].Essays) {
+			return topics[x].Name < topics[y].Name
+		}
+		return len(topics[x].Essays) > len(topics[y].Essays)
+	})
+
+	return topics
+}
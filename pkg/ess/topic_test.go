@@ -0,0 +1,88 @@
+package ess
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+)
+
+func TestGetTopics(t *testing.T) {
+	startupIdeas := db.Summary{
+		ID:     "startupideas",
+		Number: 3,
+		Topics: []string{
+			"startups",
+			"ideas",
+		},
+	}
+	growth := db.Summary{
+		ID:     "growth",
+		Number: 2,
+		Topics: []string{
+			"startups",
+		},
+	}
+	essays := db.Summary{
+		ID:     "essays",
+		Number: 4,
+		Topics: []string{
+			"writing",
+		},
+	}
+	untitled := db.Summary{
+		ID:     "untitled",
+		Number: 1,
+	}
+
+	tests := []struct {
+		description string
+		summaries   []db.Summary
+		topics      []db.Topic
+	}{
+		{
+			description: "no summaries",
+			summaries:   []db.Summary{},
+			topics:      []db.Topic{},
+		},
+		{
+			description: "successful invocation",
+			summaries: []db.Summary{
+				growth,
+				untitled,
+				essays,
+				startupIdeas,
+			},
+			topics: []db.Topic{
+				{
+					Name: "startups",
+					Essays: []db.Summary{
+						startupIdeas,
+						growth,
+					},
+				},
+				{
+					Name: "ideas",
+					Essays: []db.Summary{
+						startupIdeas,
+					},
+				},
+				{
+					Name: "writing",
+					Essays: []db.Summary{
+						essays,
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			topics := GetTopics(test.summaries)
+			if !reflect.DeepEqual(topics, test.topics) {
+				t.Errorf("incorrect topics, received: %+v, expected: %+v", topics, test.topics)
+			}
+		})
+	}
+}
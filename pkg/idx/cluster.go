@@ -0,0 +1,167 @@
+package idx
+
+import (
+	"sort"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+)
+
+const (
+	clusterIterations = 20
+	clusterTerms      = 10
+)
+
+// Cluster represents a group of similar documents and the terms
+// which best describe them, ordered by their weight.
+type Cluster struct {
+	Metadata []string `json:"metadata"`
+	Terms    []string `json:"terms"`
+}
+
+// Clusters groups the documents into up to count clusters using
+// k-means over the TF-IDF vectors of the documents combined by
+// their metadata value as in Similar.
+//
+// Clusters are seeded with the documents least similar to each
+// other so the output is the same for the same documents and
+// are ordered from largest to smallest.
+func Clusters(documents []dct.Document, count int) []Cluster {
+	names, vectors := getVectors(documents)
+	if count > len(names) {
+		count = len(names)
+	}
+
+	if count <= 0 {
+		return []Cluster{}
+	}
+
+	centroids := getSeeds(vectors, count)
+
+	assignments := make([]int, len(vectors))
+	for iteration := 0; iteration < clusterIterations; iteration++ {
+		changed := false
+		for i, vector := range vectors {
+			best, bestScore := 0, -1.0
+			for j, centroid := range centroids {
+				if score := vector.dot(centroid); score > bestScore {
+					best, bestScore = j, score
+				}
+			}
+
+			if iteration == 0 || assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+
+		if !changed {
+			break
+		}
+
+		for j := range centroids {
+			centroid := vector{}
+			for i, vector := range vectors {
+				if assignments[i] != j {
+					continue
+				}
+
+				for term, weight := range vector {
+					centroid[term] += weight
+				}
+			}
+
+			if len(centroid) == 0 {
+				continue
+			}
+
+			centroid.normalize()
+			centroids[j] = centroid
+		}
+	}
+
+	clusters := make([]Cluster, len(centroids))
+	for i, name := range names {
+		clusters[assignments[i]].Metadata = append(clusters[assignments[i]].Metadata, name)
+	}
+
+	output := []Cluster{}
+	for j, cluster := range clusters {
+		if len(cluster.Metadata) == 0 {
+			continue
+		}
+
+		cluster.Terms = centroids[j].top(clusterTerms)
+		output = append(output, cluster)
+	}
+
+	sort.SliceStable(output, func(x, y int) bool {
+		return len(output[x].Metadata) > len(output[y].Metadata)
+	})
+
+	return output
+}
+
+// getSeeds returns copies of count of the vectors starting with
+// the first and adding the vector least similar to those already
+// chosen until there are count seeds.
+func getSeeds(vectors []vector, count int) []vector {
+	chosen := map[int]bool{
+		0: true,
+	}
+	seeds := []vector{vectors[0].copy()}
+
+	for len(seeds) < count {
+		next, nextScore := -1, 0.0
+		for i, vector := range vectors {
+			if chosen[i] {
+				continue
+			}
+
+			closest := -1.0
+			for _, seed := range seeds {
+				if score := vector.dot(seed); score > closest {
+					closest = score
+				}
+			}
+
+			if next == -1 || closest < nextScore {
+				next, nextScore = i, closest
+			}
+		}
+
+		chosen[next] = true
+		seeds = append(seeds, vectors[next].copy())
+	}
+
+	return seeds
+}
+
+func (v vector) copy() vector {
+	output := make(vector, len(v))
+	for term, weight := range v {
+		output[term] = weight
+	}
+
+	return output
+}
+
+// top returns up to count of the terms with the largest weights.
+func (v vector) top(count int) []string {
+	terms := make([]string, 0, len(v))
+	for term := range v {
+		terms = append(terms, term)
+	}
+
+	sort.Slice(terms, func(x, y int) bool {
+		if v[terms[x]] == v[terms[y]] {
+			return terms[x] < terms[y]
+		}
+		return v[terms[x]] > v[terms[y]]
+	})
+
+	if len(terms) > count {
+		terms = terms[:count]
+	}
+
+	return terms
+}
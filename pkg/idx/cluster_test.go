@@ -0,0 +1,93 @@
+package idx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+)
+
+var testClusterDocuments = []dct.Document{
+	{
+		Text:     "Startup founders raise money from investors.",
+		Metadata: "fundraising",
+	},
+	{
+		Text:     "Good prose writing makes every essay clearer.",
+		Metadata: "writing",
+	},
+	{
+		Text:     "Investors fund startup founders with seed money.",
+		Metadata: "seed",
+	},
+	{
+		Text:     "Writing an essay clarifies ideas in prose.",
+		Metadata: "essays",
+	},
+}
+
+func TestClusters(t *testing.T) {
+	tests := []struct {
+		description string
+		documents   []dct.Document
+		count       int
+		metadata    [][]string
+	}{
+		{
+			description: "no clusters requested",
+			documents:   testClusterDocuments,
+			count:       0,
+			metadata:    [][]string{},
+		},
+		{
+			description: "documents grouped by shared terms",
+			documents:   testClusterDocuments,
+			count:       2,
+			metadata: [][]string{
+				{"fundraising", "seed"},
+				{"writing", "essays"},
+			},
+		},
+		{
+			description: "count larger than the number of documents",
+			documents:   testClusterDocuments[:2],
+			count:       5,
+			metadata: [][]string{
+				{"fundraising"},
+				{"writing"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			clusters := Clusters(test.documents, test.count)
+
+			metadata := [][]string{}
+			for _, cluster := range clusters {
+				if len(cluster.Terms) == 0 {
+					t.Errorf("incorrect terms, received: %v, expected: at least one term", cluster.Terms)
+				}
+				metadata = append(metadata, cluster.Metadata)
+			}
+
+			if !reflect.DeepEqual(metadata, test.metadata) {
+				t.Errorf("incorrect metadata, received: %v, expected: %v", metadata, test.metadata)
+			}
+		})
+	}
+}
+
+func Test_top(t *testing.T) {
+	v := vector{
+		"startup": 0.5,
+		"founder": 0.5,
+		"essay":   0.8,
+		"prose":   0.1,
+	}
+
+	expected := []string{"essay", "founder", "startup"}
+	if terms := v.top(3); !reflect.DeepEqual(terms, expected) {
+		t.Errorf("incorrect terms, received: %v, expected: %v", terms, expected)
+	}
+}
@@ -0,0 +1,160 @@
+package idx
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+)
+
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "do": true, "for": true, "from": true,
+	"how": true, "i": true, "if": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "what": true, "when": true, "which": true,
+	"who": true, "why": true, "with": true, "you": true,
+}
+
+// Index is a BM25 inverted index over a set of documents.
+//
+// The exported fields allow the index to be serialized to
+// JSON and stored alongside the documents.jsonl file.
+type Index struct {
+	Documents     []dct.Document       `json:"documents"`
+	Lengths       []int                `json:"lengths"`
+	AverageLength float64              `json:"average_length"`
+	Postings      map[string][]Posting `json:"postings"`
+}
+
+// Posting represents the frequency of a term in a document.
+type Posting struct {
+	Document  int `json:"d"`
+	Frequency int `json:"f"`
+}
+
+// Result represents a document matching a search query.
+type Result struct {
+	Document dct.Document `json:"document"`
+	Score    float64      `json:"score"`
+}
+
+// New generates a pointer instance of Index with each of the
+// provided documents indexed as a single unit.
+func New(documents []dct.Document) *Index {
+	index := &Index{
+		Documents: documents,
+		Lengths:   make([]int, len(documents)),
+		Postings:  map[string][]Posting{},
+	}
+
+	total := 0
+	for i, document := range documents {
+		terms := Tokenize(document.Text)
+		index.Lengths[i] = len(terms)
+		total += len(terms)
+
+		frequencies := map[string]int{}
+		for _, term := range terms {
+			frequencies[term]++
+		}
+
+		for term, frequency := range frequencies {
+			index.Postings[term] = append(index.Postings[term], Posting{
+				Document:  i,
+				Frequency: frequency,
+			})
+		}
+	}
+
+	if len(documents) > 0 {
+		index.AverageLength = float64(total) / float64(len(documents))
+	}
+
+	return index
+}
+
+// Search returns up to count documents ranked by their BM25
+// score against the provided query.
+func (i *Index) Search(query string, count int) []Result {
+	scores := map[int]float64{}
+	for _, term := range unique(Tokenize(query)) {
+		postings := i.Postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		idf := math.Log(1 + (float64(len(i.Documents))-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for _, posting := range postings {
+			frequency := float64(posting.Frequency)
+			length := float64(i.Lengths[posting.Document])
+			scores[posting.Document] += idf * (frequency * (k1 + 1)) / (frequency + k1*(1-b+b*length/i.AverageLength))
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for document, score := range scores {
+		results = append(results, Result{
+			Document: i.Documents[document],
+			Score:    score,
+		})
+	}
+
+	sort.SliceStable(results, func(x, y int) bool {
+		if results[x].Score == results[y].Score {
+			if results[x].Document.Text == results[y].Document.Text {
+				return results[x].Document.Metadata < results[y].Document.Metadata
+			}
+			return results[x].Document.Text < results[y].Document.Text
+		}
+		return results[x].Score > results[y].Score
+	})
+
+	if len(results) > count {
+		results = results[:count]
+	}
+
+	return results
+}
+
+// Tokenize splits the provided text into lowercase terms with
+// punctuation and common stopwords removed.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+
+	terms := []string{}
+	for _, field := range fields {
+		field = strings.Trim(field, "'")
+		field = strings.TrimSuffix(field, "'s")
+		if field == "" || stopwords[field] {
+			continue
+		}
+
+		terms = append(terms, field)
+	}
+
+	return terms
+}
+
+func unique(terms []string) []string {
+	seen := map[string]bool{}
+	output := []string{}
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		output = append(output, term)
+	}
+
+	return output
+}
@@ -0,0 +1,128 @@
+package idx
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+)
+
+var testDocuments = []dct.Document{
+	{
+		Text:     "Being ramen profitable means a startup makes just enough to pay the founders' living expenses.",
+		Metadata: "ramenprofitable",
+	},
+	{
+		Text:     "Schlep blindness is an unwillingness to take on schleps.",
+		Metadata: "schlep",
+	},
+	{
+		Text:     "The way to get startup ideas is not to try to think of startup ideas.",
+		Metadata: "startupideas",
+	},
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		description string
+		query       string
+		count       int
+		metadata    []string
+	}{
+		{
+			description: "no matching terms",
+			query:       "the and of",
+			count:       3,
+			metadata:    []string{},
+		},
+		{
+			description: "single distinctive term",
+			query:       "What is schlep blindness?",
+			count:       3,
+			metadata:    []string{"schlep"},
+		},
+		{
+			description: "shared term ranked by frequency",
+			query:       "startup",
+			count:       3,
+			metadata:    []string{"startupideas", "ramenprofitable"},
+		},
+		{
+			description: "results limited by count",
+			query:       "startup",
+			count:       1,
+			metadata:    []string{"startupideas"},
+		},
+	}
+
+	index := New(testDocuments)
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			results := index.Search(test.query, test.count)
+
+			metadata := []string{}
+			for _, result := range results {
+				metadata = append(metadata, result.Document.Metadata)
+			}
+
+			if !reflect.DeepEqual(metadata, test.metadata) {
+				t.Errorf("incorrect results, received: %v, expected: %v", metadata, test.metadata)
+			}
+		})
+	}
+}
+
+func TestIndexJSON(t *testing.T) {
+	index := New(testDocuments)
+
+	indexBytes, err := json.Marshal(index)
+	if err != nil {
+		t.Fatalf("error marshalling index: %v", err)
+	}
+
+	decodedIndex := &Index{}
+	if err := json.Unmarshal(indexBytes, decodedIndex); err != nil {
+		t.Fatalf("error unmarshalling index: %v", err)
+	}
+
+	received := decodedIndex.Search("ramen profitable", 1)
+	expected := index.Search("ramen profitable", 1)
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("incorrect results, received: %v, expected: %v", received, expected)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		description string
+		text        string
+		terms       []string
+	}{
+		{
+			description: "empty text",
+			text:        "",
+			terms:       []string{},
+		},
+		{
+			description: "punctuation and stopwords removed",
+			text:        "What is the founders' secret? Ramen-profitable!",
+			terms:       []string{"founders", "secret", "ramen", "profitable"},
+		},
+		{
+			description: "possessive suffix removed",
+			text:        "Graham's essays",
+			terms:       []string{"graham", "essays"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			terms := Tokenize(test.text)
+			if !reflect.DeepEqual(terms, test.terms) {
+				t.Errorf("incorrect terms, received: %v, expected: %v", terms, test.terms)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package idx
+
+import (
+	"math"
+	"sort"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+)
+
+// Similarity represents a document related to another document
+// and the cosine similarity of their TF-IDF vectors.
+type Similarity struct {
+	Metadata string  `json:"metadata"`
+	Score    float64 `json:"score"`
+}
+
+// Similar returns up to count of the most similar documents for
+// each document keyed by its metadata value.
+//
+// Documents sharing a metadata value (e.g. the paragraphs of an
+// essay) are combined into a single document and documents
+// without any shared terms are not included.
+func Similar(documents []dct.Document, count int) map[string][]Similarity {
+	names, vectors := getVectors(documents)
+
+	output := make(map[string][]Similarity, len(names))
+	for i, name := range names {
+		similarities := []Similarity{}
+		for j, other := range names {
+			if i == j {
+				continue
+			}
+
+			score := vectors[i].dot(vectors[j])
+			if score <= 0 {
+				continue
+			}
+
+			similarities = append(similarities, Similarity{
+				Metadata: other,
+				Score:    score,
+			})
+		}
+
+		sort.SliceStable(similarities, func(x, y int) bool {
+			if similarities[x].Score == similarities[y].Score {
+				return similarities[x].Metadata < similarities[y].Metadata
+			}
+			return similarities[x].Score > similarities[y].Score
+		})
+
+		if len(similarities) > count {
+			similarities = similarities[:count]
+		}
+
+		output[name] = similarities
+	}
+
+	return output
+}
+
+// vector is a sparse vector of term weights.
+type vector map[string]float64
+
+func (v vector) dot(other vector) float64 {
+	output := 0.0
+	for term, weight := range v {
+		output += weight * other[term]
+	}
+
+	return output
+}
+
+// getVectors returns the metadata values of the documents in the
+// order they first appear and the normalized TF-IDF vector of the
+// combined text of the documents sharing each value.
+func getVectors(documents []dct.Document) ([]string, []vector) {
+	names := []string{}
+	frequencies := map[string]map[string]int{}
+	for _, document := range documents {
+		if _, ok := frequencies[document.Metadata]; !ok {
+			names = append(names, document.Metadata)
+			frequencies[document.Metadata] = map[string]int{}
+		}
+
+		for _, term := range Tokenize(document.Text) {
+			frequencies[document.Metadata][term]++
+		}
+	}
+
+	counts := map[string]int{}
+	for _, terms := range frequencies {
+		for term := range terms {
+			counts[term]++
+		}
+	}
+
+	vectors := make([]vector, len(names))
+	for i, name := range names {
+		vectors[i] = vector{}
+		for term, frequency := range frequencies[name] {
+			weight := (1 + math.Log(float64(frequency))) * math.Log(float64(len(names))/float64(counts[term]))
+			if weight == 0 {
+				continue
+			}
+
+			vectors[i][term] = weight
+		}
+
+		vectors[i].normalize()
+	}
+
+	return names, vectors
+}
+
+func (v vector) normalize() {
+	norm := math.Sqrt(v.dot(v))
+	if norm == 0 {
+		return
+	}
+
+	for term := range v {
+		v[term] /= norm
+	}
+}
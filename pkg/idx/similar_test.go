@@ -0,0 +1,75 @@
+package idx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+)
+
+func TestSimilar(t *testing.T) {
+	tests := []struct {
+		description string
+		documents   []dct.Document
+		count       int
+		metadata    map[string][]string
+	}{
+		{
+			description: "no documents",
+			documents:   []dct.Document{},
+			count:       3,
+			metadata:    map[string][]string{},
+		},
+		{
+			description: "documents with and without shared terms",
+			documents:   testDocuments,
+			count:       3,
+			metadata: map[string][]string{
+				"ramenprofitable": {"startupideas"},
+				"schlep":          {},
+				"startupideas":    {"ramenprofitable"},
+			},
+		},
+		{
+			description: "paragraphs combined and results limited by count",
+			documents: append([]dct.Document{
+				{
+					Text:     "Startup founders should avoid schleps.",
+					Metadata: "schlep",
+				},
+				{
+					Text:     "Founders living expenses are low when ramen profitable.",
+					Metadata: "founders",
+				},
+			}, testDocuments...),
+			count: 1,
+			metadata: map[string][]string{
+				"founders":        {"ramenprofitable"},
+				"ramenprofitable": {"founders"},
+				"schlep":          {"ramenprofitable"},
+				"startupideas":    {"ramenprofitable"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			similar := Similar(test.documents, test.count)
+
+			metadata := map[string][]string{}
+			for name, similarities := range similar {
+				metadata[name] = []string{}
+				for _, similarity := range similarities {
+					if similarity.Score <= 0 || similarity.Score > 1+1e-9 {
+						t.Errorf("incorrect score, received: %f, expected: (0, 1]", similarity.Score)
+					}
+					metadata[name] = append(metadata[name], similarity.Metadata)
+				}
+			}
+
+			if !reflect.DeepEqual(metadata, test.metadata) {
+				t.Errorf("incorrect metadata, received: %v, expected: %v", metadata, test.metadata)
+			}
+		})
+	}
+}
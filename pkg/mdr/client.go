@@ -0,0 +1,143 @@
+// Package mdr moderates questions before they reach the answer
+// cache or the LLM provider so that flagged questions cost
+// nothing and are never answered, including from the cache.
+package mdr
+
+import (
+	"context"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+var _ nlp.NLPer = &Client{}
+
+// Client implements the nlp.NLPer interface by moderating
+// questions with the wrapped NLPer before answering them and
+// returning a refusal Answer in place of flagged questions.
+//
+// The question Moderation is set on the context passed to the
+// wrapped NLPer so that the question is not moderated again and
+// is added to cached answers, which hold no moderations of their
+// own, so that it is stored with every question.
+type Client struct {
+	nlpClient nlp.NLPer
+}
+
+// New generates a pointer instance of Client.
+func New(nlpClient nlp.NLPer) *Client {
+	return &Client{
+		nlpClient: nlpClient,
+	}
+}
+
+// GetSummary implements the nlp.NLPer.GetSummary method with the
+// wrapped NLPer.
+func (c *Client) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
+	return c.nlpClient.GetSummary(ctx, text)
+}
+
+// SetDocuments implements the nlp.NLPer.SetDocuments method with
+// the wrapped NLPer.
+func (c *Client) SetDocuments(ctx context.Context, documents []dct.Document) error {
+	return c.nlpClient.SetDocuments(ctx, documents)
+}
+
+// GetAnswer implements the nlp.NLPer.GetAnswer method and
+// returns a refusal Answer without calling the wrapped NLPer
+// when the question is flagged.
+func (c *Client) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	moderation, refusal, err := c.moderate(ctx, question)
+	if err != nil || refusal != nil {
+		return refusal, err
+	}
+
+	answer, err := c.nlpClient.GetAnswer(nlp.WithQuestionModeration(ctx, *moderation), question, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return addModeration(answer, *moderation), nil
+}
+
+// StreamAnswer implements the nlp.NLPer.StreamAnswer method and
+// returns a refusal Answer without calling the wrapped NLPer or
+// sending any tokens when the question is flagged.
+func (c *Client) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	moderation, refusal, err := c.moderate(ctx, question)
+	if err != nil || refusal != nil {
+		return refusal, err
+	}
+
+	answer, err := c.nlpClient.StreamAnswer(nlp.WithQuestionModeration(ctx, *moderation), question, userID, send)
+	if err != nil {
+		return nil, err
+	}
+
+	return addModeration(answer, *moderation), nil
+}
+
+// SearchDocuments implements the nlp.NLPer.SearchDocuments
+// method with the wrapped NLPer.
+func (c *Client) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	return c.nlpClient.SearchDocuments(ctx, query)
+}
+
+// GetQuotes implements the nlp.NLPer.GetQuotes method with the
+// wrapped NLPer.
+func (c *Client) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return c.nlpClient.GetQuotes(ctx, question)
+}
+
+// GetTopic implements the nlp.NLPer.GetTopic method with the
+// wrapped NLPer.
+func (c *Client) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	return c.nlpClient.GetTopic(ctx, titles, terms)
+}
+
+// GetEmbedding implements the nlp.NLPer.GetEmbedding method with
+// the wrapped NLPer.
+func (c *Client) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return c.nlpClient.GetEmbedding(ctx, text)
+}
+
+// ModerateQuestion implements the nlp.NLPer.ModerateQuestion
+// method with the wrapped NLPer.
+func (c *Client) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return c.nlpClient.ModerateQuestion(ctx, question)
+}
+
+// GetPromptVersion implements the nlp.NLPer.GetPromptVersion
+// method with the wrapped NLPer.
+func (c *Client) GetPromptVersion(ctx context.Context) (string, error) {
+	return c.nlpClient.GetPromptVersion(ctx)
+}
+
+// moderate returns the question Moderation along with a refusal
+// Answer when the question is flagged.
+func (c *Client) moderate(ctx context.Context, question string) (*nlp.Moderation, *nlp.Answer, error) {
+	moderation, err := c.nlpClient.ModerateQuestion(ctx, question)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if moderation.Flagged {
+		return nil, nlp.GetRefusal([]nlp.Moderation{*moderation}), nil
+	}
+
+	return moderation, nil, nil
+}
+
+// addModeration adds the question Moderation to answers which do
+// not already hold it, such as those from the cache.
+func addModeration(answer *nlp.Answer, moderation nlp.Moderation) *nlp.Answer {
+	for _, answerModeration := range answer.Moderations {
+		if answerModeration.Target == nlp.QuestionTarget {
+			return answer
+		}
+	}
+
+	answer.Moderations = append([]nlp.Moderation{moderation}, answer.Moderations...)
+
+	return answer
+}
@@ -0,0 +1,219 @@
+package mdr
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+type mockNLPClient struct {
+	mockModerateQuestionOutput *nlp.Moderation
+	mockModerateQuestionError  error
+	mockGetAnswerOutput        *nlp.Answer
+	mockGetAnswerError         error
+	answers                    int
+}
+
+func (m *mockNLPClient) GetSummary(ctx context.Context, text string) (*nlp.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) SetDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockNLPClient) GetAnswer(ctx context.Context, question, userID string) (*nlp.Answer, error) {
+	m.answers++
+	return m.mockGetAnswerOutput, m.mockGetAnswerError
+}
+
+func (m *mockNLPClient) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*nlp.Answer, error) {
+	m.answers++
+	if m.mockGetAnswerOutput != nil {
+		if err := send(m.mockGetAnswerOutput.Text); err != nil {
+			return nil, err
+		}
+	}
+	return m.mockGetAnswerOutput, m.mockGetAnswerError
+}
+
+func (m *mockNLPClient) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetQuotes(ctx context.Context, question string) ([]nlp.Citation, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetTopic(ctx context.Context, titles, terms []string) (*nlp.Topic, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return nil, nil
+}
+
+func (m *mockNLPClient) ModerateQuestion(ctx context.Context, question string) (*nlp.Moderation, error) {
+	return m.mockModerateQuestionOutput, m.mockModerateQuestionError
+}
+
+func (m *mockNLPClient) GetPromptVersion(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func TestGetAnswer(t *testing.T) {
+	mockModerateQuestionErr := errors.New("mock moderate question error")
+	mockGetAnswerErr := errors.New("mock get answer error")
+
+	passed := &nlp.Moderation{
+		Target:     nlp.QuestionTarget,
+		Categories: []string{},
+	}
+
+	flagged := &nlp.Moderation{
+		Target:     nlp.QuestionTarget,
+		Flagged:    true,
+		Categories: []string{"harassment"},
+	}
+
+	tests := []struct {
+		description                string
+		mockModerateQuestionOutput *nlp.Moderation
+		mockModerateQuestionError  error
+		mockGetAnswerOutput        *nlp.Answer
+		mockGetAnswerError         error
+		answers                    int
+		answer                     *nlp.Answer
+		error                      error
+	}{
+		{
+			description:               "error moderating question",
+			mockModerateQuestionError: mockModerateQuestionErr,
+			answers:                   0,
+			answer:                    nil,
+			error:                     mockModerateQuestionErr,
+		},
+		{
+			description:                "flagged question refused",
+			mockModerateQuestionOutput: flagged,
+			answers:                    0,
+			answer:                     nlp.GetRefusal([]nlp.Moderation{*flagged}),
+			error:                      nil,
+		},
+		{
+			description:                "error getting answer",
+			mockModerateQuestionOutput: passed,
+			mockGetAnswerError:         mockGetAnswerErr,
+			answers:                    1,
+			answer:                     nil,
+			error:                      mockGetAnswerErr,
+		},
+		{
+			description:                "cached answer with question moderation added",
+			mockModerateQuestionOutput: passed,
+			mockGetAnswerOutput: &nlp.Answer{
+				Text:   "Cached answer.",
+				Cached: true,
+			},
+			answers: 1,
+			answer: &nlp.Answer{
+				Text:   "Cached answer.",
+				Cached: true,
+				Moderations: []nlp.Moderation{
+					*passed,
+				},
+			},
+			error: nil,
+		},
+		{
+			description:                "generated answer with question moderation kept",
+			mockModerateQuestionOutput: passed,
+			mockGetAnswerOutput: &nlp.Answer{
+				Text: "Generated answer.",
+				Moderations: []nlp.Moderation{
+					*passed,
+					{
+						Target: nlp.AnswerTarget,
+					},
+				},
+			},
+			answers: 1,
+			answer: &nlp.Answer{
+				Text: "Generated answer.",
+				Moderations: []nlp.Moderation{
+					*passed,
+					{
+						Target: nlp.AnswerTarget,
+					},
+				},
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			nlpClient := &mockNLPClient{
+				mockModerateQuestionOutput: test.mockModerateQuestionOutput,
+				mockModerateQuestionError:  test.mockModerateQuestionError,
+				mockGetAnswerOutput:        test.mockGetAnswerOutput,
+				mockGetAnswerError:         test.mockGetAnswerError,
+			}
+
+			c := New(nlpClient)
+
+			answer, err := c.GetAnswer(context.Background(), "Mock question?", "user_id")
+
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(answer, test.answer) {
+				t.Errorf("incorrect answer, received: %+v, expected: %+v", answer, test.answer)
+			}
+
+			if nlpClient.answers != test.answers {
+				t.Errorf("incorrect answers, received: %d, expected: %d", nlpClient.answers, test.answers)
+			}
+		})
+	}
+}
+
+func TestStreamAnswer(t *testing.T) {
+	flagged := &nlp.Moderation{
+		Target:     nlp.QuestionTarget,
+		Flagged:    true,
+		Categories: []string{"harassment"},
+	}
+
+	nlpClient := &mockNLPClient{
+		mockModerateQuestionOutput: flagged,
+		mockGetAnswerOutput: &nlp.Answer{
+			Text: "Generated answer.",
+		},
+	}
+
+	c := New(nlpClient)
+
+	tokens := []string{}
+	answer, err := c.StreamAnswer(context.Background(), "Mock question?", "user_id", func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("incorrect error, received: %v", err)
+	}
+
+	expected := nlp.GetRefusal([]nlp.Moderation{*flagged})
+	if !reflect.DeepEqual(answer, expected) {
+		t.Errorf("incorrect answer, received: %+v, expected: %+v", answer, expected)
+	}
+
+	if len(tokens) != 0 || nlpClient.answers != 0 {
+		t.Errorf("incorrect tokens, received: %v, expected: none", tokens)
+	}
+}
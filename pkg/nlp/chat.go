@@ -0,0 +1,150 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var _ completer = &chatCompleter{}
+
+// chatCompleter sends requests to the chat completions API
+// which accepts a list of role-tagged messages.
+type chatCompleter struct {
+	helper helper
+}
+
+type chatMessageJSON struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionReqJSON struct {
+	Model         string             `json:"model"`
+	Messages      []chatMessageJSON  `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   float64            `json:"temperature"`
+	Stop          []string           `json:"stop,omitempty"`
+	User          string             `json:"user,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	StreamOptions *streamOptionsJSON `json:"stream_options,omitempty"`
+}
+
+type chatCompletionRespJSON struct {
+	Choices []chatChoiceJSON `json:"choices"`
+	Usage   *usageJSON       `json:"usage"`
+}
+
+type chatChoiceJSON struct {
+	Message      chatMessageJSON `json:"message"`
+	Delta        chatMessageJSON `json:"delta"`
+	FinishReason string          `json:"finish_reason"`
+}
+
+func (c *chatCompleter) complete(ctx context.Context, request completionRequest) (*completion, error) {
+	data, err := json.Marshal(getChatCompletionReq(request))
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := chatCompletionRespJSON{}
+	if err := c.helper.sendRequest(
+		ctx,
+		http.MethodPost,
+		"/v1/chat/completions",
+		bytes.NewReader(data),
+		&responseBody,
+		map[string]string{
+			"Content-Type": "application/json",
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	if len(responseBody.Choices) == 0 {
+		return nil, errors.New("nlp: no completion choices received")
+	}
+
+	response := &completion{
+		text: responseBody.Choices[0].Message.Content,
+	}
+	if responseBody.Usage != nil {
+		response.usage = *responseBody.Usage
+	}
+
+	return response, nil
+}
+
+func (c *chatCompleter) stream(ctx context.Context, request completionRequest, send func(text string) error) (*completion, error) {
+	completionReq := getChatCompletionReq(request)
+	completionReq.Stream = true
+	completionReq.StreamOptions = &streamOptionsJSON{
+		IncludeUsage: true,
+	}
+
+	data, err := json.Marshal(completionReq)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &completion{}
+	text := strings.Builder{}
+	if err := c.helper.streamRequest(
+		ctx,
+		http.MethodPost,
+		"/v1/chat/completions",
+		bytes.NewReader(data),
+		map[string]string{
+			"Content-Type": "application/json",
+		},
+		func(data []byte) error {
+			chunk := chatCompletionRespJSON{}
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				return err
+			}
+
+			if chunk.Usage != nil {
+				response.usage = *chunk.Usage
+			}
+
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+				return nil
+			}
+
+			text.WriteString(chunk.Choices[0].Delta.Content)
+			return send(chunk.Choices[0].Delta.Content)
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	response.text = text.String()
+
+	return response, nil
+}
+
+func getChatCompletionReq(request completionRequest) chatCompletionReqJSON {
+	messages := []chatMessageJSON{}
+	if request.system != "" {
+		messages = append(messages, chatMessageJSON{
+			Role:    "system",
+			Content: request.system,
+		})
+	}
+	messages = append(messages, chatMessageJSON{
+		Role:    "user",
+		Content: request.prompt,
+	})
+
+	return chatCompletionReqJSON{
+		Model:       request.model,
+		Messages:    messages,
+		MaxTokens:   request.maxTokens,
+		Temperature: request.temperature,
+		Stop:        request.stop,
+		User:        request.user,
+	}
+}
@@ -0,0 +1,256 @@
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_chatCompleter_complete(t *testing.T) {
+	tests := []struct {
+		description string
+		statusCode  int
+		body        string
+		messages    []chatMessageJSON
+		completion  *completion
+		error       bool
+	}{
+		{
+			description: "error response from server",
+			statusCode:  http.StatusInternalServerError,
+			body:        `{"error": {"message": "mock server error"}}`,
+			completion:  nil,
+			error:       true,
+		},
+		{
+			description: "no choices in response",
+			statusCode:  http.StatusOK,
+			body:        `{"choices": []}`,
+			completion:  nil,
+			error:       true,
+		},
+		{
+			description: "successful invocation",
+			statusCode:  http.StatusOK,
+			body:        `{"choices": [{"message": {"role": "assistant", "content": "mock answer"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`,
+			messages: []chatMessageJSON{
+				{
+					Role:    "system",
+					Content: "mock system",
+				},
+				{
+					Role:    "user",
+					Content: "mock prompt",
+				},
+			},
+			completion: &completion{
+				text: "mock answer",
+				usage: usageJSON{
+					PromptTokens:     10,
+					CompletionTokens: 2,
+					TotalTokens:      12,
+				},
+			},
+			error: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var received chatCompletionReqJSON
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer api_key" {
+					t.Errorf("incorrect authorization header, received: %s", r.Header.Get("Authorization"))
+				}
+
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					t.Errorf("error decoding request body: %v", err)
+				}
+
+				w.WriteHeader(test.statusCode)
+				fmt.Fprint(w, test.body)
+			})
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			c := &chatCompleter{
+				helper: &help{
+					provider: Provider{
+						BaseURL: server.URL,
+						APIKey:  "api_key",
+					}.withDefaults(),
+					httpClient: http.Client{},
+					sleep:      func(ctx context.Context, d time.Duration) error { return nil },
+				},
+			}
+
+			response, err := c.complete(context.Background(), completionRequest{
+				model:  "mock_model",
+				system: "mock system",
+				prompt: "mock prompt",
+			})
+			if (err != nil) != test.error {
+				t.Errorf("incorrect error, received: %v, expected error: %t", err, test.error)
+			}
+
+			if !reflect.DeepEqual(response, test.completion) {
+				t.Errorf("incorrect completion, received: %+v, expected: %+v", response, test.completion)
+			}
+
+			if test.messages != nil && !reflect.DeepEqual(received.Messages, test.messages) {
+				t.Errorf("incorrect messages, received: %+v, expected: %+v", received.Messages, test.messages)
+			}
+		})
+	}
+}
+
+func Test_chatCompleter_stream(t *testing.T) {
+	tests := []struct {
+		description string
+		statusCode  int
+		body        string
+		tokens      []string
+		completion  *completion
+		error       bool
+	}{
+		{
+			description: "error response from server",
+			statusCode:  http.StatusTooManyRequests,
+			body:        `{"error": {"message": "mock rate limit error"}}`,
+			tokens:      nil,
+			completion:  nil,
+			error:       true,
+		},
+		{
+			description: "malformed event",
+			statusCode:  http.StatusOK,
+			body:        "data: {\"choices\": [\n\n",
+			tokens:      nil,
+			completion:  nil,
+			error:       true,
+		},
+		{
+			description: "successful invocation",
+			statusCode:  http.StatusOK,
+			body: "data: {\"choices\": [{\"delta\": {\"role\": \"assistant\"}}]}\n\n" +
+				"data: {\"choices\": [{\"delta\": {\"content\": \"mock\"}}]}\n\n" +
+				": keep-alive comment\n\n" +
+				"data: {\"choices\": [{\"delta\": {\"content\": \" answer\"}, \"finish_reason\": \"stop\"}]}\n\n" +
+				"data: {\"choices\": [], \"usage\": {\"prompt_tokens\": 10, \"completion_tokens\": 2, \"total_tokens\": 12}}\n\n" +
+				"data: [DONE]\n\n",
+			tokens: []string{
+				"mock",
+				" answer",
+			},
+			completion: &completion{
+				text: "mock answer",
+				usage: usageJSON{
+					PromptTokens:     10,
+					CompletionTokens: 2,
+					TotalTokens:      12,
+				},
+			},
+			error: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var received chatCompletionReqJSON
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					t.Errorf("error decoding request body: %v", err)
+				}
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.WriteHeader(test.statusCode)
+				fmt.Fprint(w, test.body)
+			})
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			c := &chatCompleter{
+				helper: &help{
+					provider: Provider{
+						BaseURL: server.URL,
+						APIKey:  "api_key",
+					}.withDefaults(),
+					httpClient: http.Client{},
+					sleep:      func(ctx context.Context, d time.Duration) error { return nil },
+				},
+			}
+
+			var tokens []string
+			response, err := c.stream(context.Background(), completionRequest{
+				model:  "mock_model",
+				prompt: "mock prompt",
+			}, func(token string) error {
+				tokens = append(tokens, token)
+				return nil
+			})
+			if (err != nil) != test.error {
+				t.Errorf("incorrect error, received: %v, expected error: %t", err, test.error)
+			}
+
+			if !received.Stream || received.StreamOptions == nil || !received.StreamOptions.IncludeUsage {
+				t.Errorf("incorrect stream request, received: %+v", received)
+			}
+
+			if !reflect.DeepEqual(tokens, test.tokens) {
+				t.Errorf("incorrect tokens, received: %q, expected: %q", tokens, test.tokens)
+			}
+
+			if !reflect.DeepEqual(response, test.completion) {
+				t.Errorf("incorrect completion, received: %+v, expected: %+v", response, test.completion)
+			}
+		})
+	}
+}
+
+func TestGetSummaryChat(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": " mock summary"}}], "usage": {"prompt_tokens": 5, "completion_tokens": 2, "total_tokens": 7}}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	h := &help{
+		provider: Provider{
+			BaseURL: server.URL,
+			APIKey:  "api_key",
+		}.withDefaults(),
+		httpClient: http.Client{},
+	}
+
+	c := &Client{
+		helper: h,
+		completer: &chatCompleter{
+			helper: h,
+		},
+		summariesModel: chatModel,
+		contextTokens:  defaultContextTokens,
+		s3Client:       mockPromptsS3Client(),
+	}
+
+	summary, err := c.GetSummary(context.Background(), "mock text")
+	if err != nil {
+		t.Fatalf("incorrect error, received: %v", err)
+	}
+
+	if summary.Text != "Mock summary." {
+		t.Errorf("incorrect summary, received: %s, expected: %s", summary.Text, "Mock summary.")
+	}
+}
@@ -6,344 +6,766 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"mime/multipart"
 	"net/http"
-	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/forstmeier/askpaulgraham/pkg/dct"
-)
-
-const documentsFilename = "documents.jsonl"
-
-const (
-	summariesModel = "curie"
-	answersModel   = "davinci"
+	"github.com/forstmeier/askpaulgraham/pkg/idx"
+	"github.com/forstmeier/askpaulgraham/pkg/tkn"
 )
 
 const (
-	maxContextTokenLength = 2049 // most OpenAI model max
-	summariesMaxTokens    = 60
-	summariesTemperature  = 0.50
-	answersMaxTokens      = 120
-	answersTemperature    = 0.45
+	promptOverheadTokens = 8 // chat message formatting tokens
+	summariesBudgetRatio = 4 // room to merge chunk summaries
+	answersPassages      = 5
+	embeddingsBatchSize  = 100
+	searchResults        = 10
+	quotesResults        = 5
+	quotesCandidateRatio = 4
+	minQuoteWords        = 6 // skips fragments like "Why?"
 )
 
 var _ NLPer = &Client{}
 
 // Client implements the nlp.NLPer interface.
 type Client struct {
-	helper     helper
-	bucketName string
-	s3Client   s3Client
+	helper             helper
+	completer          completer
+	summariesModel     string
+	answersModel       string
+	moderator          Moderator
+	verifier           Verifier
+	contextTokens      int
+	bucketName         string
+	s3Client           s3Client
+	retrieval          Retrieval
+	groundingThreshold float64
+	embeddings         *embeddingsRetriever
+	keywords           *keywordRetriever
+	sentences          *keywordRetriever
+
+	promptsMutex  sync.Mutex
+	prompts       *prompts
+	promptsLoaded time.Time
 }
 
 type s3Client interface {
-	GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+	PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error)
 }
 
 // New generates a pointer instance of Client.
-func New(newSession *session.Session, apiKey, bucketName string) *Client {
-	return &Client{
-		helper: &help{
-			apiKey:     apiKey,
-			httpClient: http.Client{},
-		},
-		bucketName: bucketName,
-		s3Client:   s3.New(newSession),
+//
+// The provider argument selects the API, authentication, and
+// models used and the retrieval argument selects how paragraphs
+// are chosen when answering questions and defaults to
+// EmbeddingsRetrieval.
+func New(newSession *session.Session, provider Provider, bucketName string, retrieval Retrieval) *Client {
+	provider = provider.withDefaults()
+
+	h := &help{
+		provider:   provider,
+		httpClient: http.Client{},
 	}
-}
+	s3Client := s3.New(newSession)
 
-type getSummaryReqJSON struct {
-	Prompt           string   `json:"prompt"`
-	MaxTokens        int      `json:"max_tokens"`
-	Temperature      float64  `json:"temperature"`
-	TopP             float64  `json:"top_p"`
-	FrequencyPenalty float64  `json:"frequency_penalty"`
-	PresencePenalty  float64  `json:"presence_penalty"`
-	Stop             []string `json:"stop"`
-}
+	moderator := provider.Moderator
+	if moderator == nil {
+		moderator = &apiModerator{
+			helper: h,
+			model:  provider.ModerationModel,
+		}
+	}
 
-type getSummaryRespJSON struct {
-	Choices []getSummaryRespChoiceJSON `json:"choices"`
-	Created int                        `json:"created"`
-}
+	c := &Client{
+		helper:             h,
+		completer:          provider.getCompleter(h),
+		summariesModel:     provider.SummariesModel,
+		answersModel:       provider.AnswersModel,
+		moderator:          moderator,
+		contextTokens:      provider.ContextTokens,
+		bucketName:         bucketName,
+		s3Client:           s3Client,
+		retrieval:          retrieval,
+		groundingThreshold: provider.GroundingThreshold,
+		embeddings: &embeddingsRetriever{
+			helper:     h,
+			model:      provider.EmbeddingsModel,
+			dimensions: provider.EmbeddingsDimensions,
+			bucketName: bucketName,
+			s3Client:   s3Client,
+		},
+		keywords: &keywordRetriever{
+			filename:   indexFilename,
+			bucketName: bucketName,
+			s3Client:   s3Client,
+		},
+		sentences: &keywordRetriever{
+			filename:   sentencesFilename,
+			bucketName: bucketName,
+			s3Client:   s3Client,
+		},
+	}
+	c.verifier = provider.getVerifier(h, c.getPrompts)
 
-type getSummaryRespChoiceJSON struct {
-	Text string `json:"text"`
+	return c
 }
 
 // GetSummary implements the nlp.NLPer.GetSummary method
 // and generates a summary of the provided text with OpenAI.
-func (c *Client) GetSummary(ctx context.Context, text string) (*string, error) {
-	// approximate check to work within OpenAI token limits
-	characters := len(text)
-	if (characters / 4) > (maxContextTokenLength - summariesMaxTokens) {
-		message := "Surpassed maximum word count permitted by OpenAI."
-		return &message, nil
-	}
-
-	data, err := json.Marshal(getSummaryReqJSON{
-		Prompt:           text + "\n\ntl;dr:",
-		MaxTokens:        summariesMaxTokens,
-		Temperature:      summariesTemperature,
-		TopP:             1.0,
-		FrequencyPenalty: 0.0,
-		PresencePenalty:  0.0,
-		Stop:             []string{".", "<|endoftext|>"},
-	})
+//
+// Text over the context window of the summaries model is split
+// into chunks of paragraphs which are summarized separately and
+// the chunk summaries are then summarized together.
+func (c *Client) GetSummary(ctx context.Context, text string) (*Summary, error) {
+	p, err := c.getPrompts(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	responseBody := getSummaryRespJSON{}
-	if err := c.helper.sendRequest(
-		http.MethodPost,
-		fmt.Sprintf("https://api.openai.com/v1/engines/%s/completions", summariesModel),
-		bytes.NewReader(data),
-		&responseBody,
-		map[string]string{
-			"Content-Type": "application/json",
-		},
-	); err != nil {
+	encoding, err := getEncoding(c.summariesModel)
+	if err != nil {
 		return nil, err
 	}
 
-	summary := formatString(responseBody.Choices[0].Text)
+	base, err := p.Summaries.execute(summaryData{})
+	if err != nil {
+		return nil, err
+	}
 
-	return &summary, nil
-}
+	budget := c.contextTokens - p.Summaries.MaxTokens - promptOverheadTokens - encoding.Count(p.Summaries.System+base)
+	if budget < summariesBudgetRatio*p.Summaries.MaxTokens {
+		return nil, fmt.Errorf("nlp: %d context tokens too few for summaries", c.contextTokens)
+	}
+
+	for encoding.Count(text) > budget {
+		summaries := []string{}
+		for _, chunk := range getSummaryChunks(encoding, budget, text) {
+			summary, err := c.summarize(ctx, p, chunk)
+			if err != nil {
+				return nil, err
+			}
+
+			if summary != "" {
+				summaries = append(summaries, summary)
+			}
+		}
 
-type getFilesRespJSON struct {
-	Data []getFilesRespDataJSON `json:"data"`
+		text = strings.Join(summaries, "\n")
+	}
+
+	summary, err := c.summarize(ctx, p, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Summary{
+		Text:          summary,
+		PromptVersion: p.Version,
+	}, nil
 }
 
-type getFilesRespDataJSON struct {
-	ID   string `json:"id"`
-	Name string `json:"filename"`
+func (c *Client) summarize(ctx context.Context, p *prompts, text string) (string, error) {
+	prompt, err := p.Summaries.execute(summaryData{
+		Text: text,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.completer.complete(ctx, completionRequest{
+		model:       c.summariesModel,
+		system:      p.Summaries.System,
+		prompt:      prompt,
+		maxTokens:   p.Summaries.MaxTokens,
+		temperature: p.Summaries.Temperature,
+		stop:        p.Summaries.Stop,
+	})
+	if err != nil {
+		return "", err
+	}
+	recordUsage(ctx, c.summariesModel, &response.usage)
+
+	return p.Summaries.format(response.text), nil
 }
 
-type documentJSON struct {
-	Text     string `json:"text"`
-	Metadata string `json:"metadata"`
+// getSummaryChunks groups the paragraphs of the provided text
+// into chunks within the token budget. Paragraphs over the
+// budget on their own are split on token boundaries.
+func getSummaryChunks(encoding *tkn.Encoding, budget int, text string) []string {
+	paragraphs := dct.SplitParagraphs([]dct.Document{
+		{
+			Text: text,
+		},
+	})
+
+	chunks := []string{}
+	chunk := ""
+	for i, paragraph := range paragraphs {
+		piece := strings.TrimSpace(paragraph.Text)
+		if i < len(paragraphs)-1 {
+			piece += "." // restore the period removed by the split
+		}
+
+		candidate := piece
+		if chunk != "" {
+			candidate = chunk + "\n" + piece
+		}
+
+		if encoding.Count(candidate) <= budget {
+			chunk = candidate
+			continue
+		}
+
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		chunk = piece
+
+		if encoding.Count(piece) > budget {
+			tokens := encoding.Encode(piece)
+			for start := 0; start < len(tokens); start += budget {
+				end := start + budget
+				if end > len(tokens) {
+					end = len(tokens)
+				}
+
+				chunks = append(chunks, encoding.Decode(tokens[start:end]))
+			}
+			chunk = ""
+		}
+	}
+
+	if chunk != "" {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
 }
 
 // SetDocuments implements the nlp.NLPer.SetDocuments method
-// and stores the provided slice of structs representing the
-// documents.jsonl file in OpenAI.
+// and stores embeddings and a keyword index of the paragraphs
+// and a keyword index of the sentences in the provided slice of
+// structs representing the documents.jsonl file.
+//
+// Embeddings are not requested or stored when the Client uses
+// KeywordRetrieval since they are never read.
 func (c *Client) SetDocuments(ctx context.Context, documents []dct.Document) error {
-	documentsBody := bytes.Buffer{}
-	encoder := json.NewEncoder(&documentsBody)
-	re, err := regexp.Compile(`\w\.\w`)
-	if err != nil {
-		return err
+	paragraphs := dct.SplitParagraphs(documents)
+
+	if c.retrieval.Method != KeywordRetrieval {
+		if err := c.setEmbeddings(ctx, paragraphs); err != nil {
+			return err
+		}
 	}
 
-	for _, document := range documents {
-		text := re.ReplaceAllStringFunc(document.Text, replaceFunc)
-		for _, paragraph := range strings.Split(text, ".\n") {
-			if paragraph == "" {
-				continue
-			}
+	indexes := []struct {
+		filename  string
+		documents []dct.Document
+	}{
+		{
+			filename:  indexFilename,
+			documents: paragraphs,
+		},
+		{
+			filename:  sentencesFilename,
+			documents: dct.SplitSentences(paragraphs),
+		},
+	}
+
+	for _, index := range indexes {
+		indexBytes, err := json.Marshal(idx.New(index.documents))
+		if err != nil {
+			return err
+		}
+
+		_, err = c.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket: &c.bucketName,
+			Key:    aws.String(index.filename),
+			Body:   bytes.NewReader(indexBytes),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	c.embeddings.reset()
+	c.keywords.reset()
+	c.sentences.reset()
+
+	return nil
+}
+
+// setEmbeddings stores the embeddings of the paragraphs in the
+// embeddings.jsonl file read by the embeddings retriever.
+func (c *Client) setEmbeddings(ctx context.Context, paragraphs []dct.Document) error {
+	embeddingsBody := bytes.Buffer{}
+	encoder := json.NewEncoder(&embeddingsBody)
+	for i := 0; i < len(paragraphs); i += embeddingsBatchSize {
+		end := i + embeddingsBatchSize
+		if end > len(paragraphs) {
+			end = len(paragraphs)
+		}
+
+		texts := make([]string, end-i)
+		for j, paragraph := range paragraphs[i:end] {
+			texts[j] = paragraph.Text
+		}
 
-			if err := encoder.Encode(dct.Document{
-				Text:     paragraph,
-				Metadata: document.Metadata,
+		vectors, err := c.embeddings.getEmbeddings(ctx, texts)
+		if err != nil {
+			return err
+		}
+
+		for j, paragraph := range paragraphs[i:end] {
+			if err := encoder.Encode(embeddingJSON{
+				Text:      paragraph.Text,
+				Metadata:  paragraph.Metadata,
+				Embedding: vectors[j],
 			}); err != nil {
 				return err
 			}
 		}
 	}
 
-	openAIDocumentsBody := bytes.Buffer{}
-	multipartWriter := multipart.NewWriter(&openAIDocumentsBody)
+	_, err := c.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: &c.bucketName,
+		Key:    aws.String(embeddingsFilename),
+		Body:   bytes.NewReader(embeddingsBody.Bytes()),
+	})
 
-	var fileWriter, purposeWriter io.Writer
+	return err
+}
 
-	purposeWriter, err = multipartWriter.CreateFormField("purpose")
+// SearchDocuments implements the nlp.NLPer.SearchDocuments
+// method and returns the paragraphs best matching the provided
+// query from the keyword index.
+func (c *Client) SearchDocuments(ctx context.Context, query string) ([]dct.Document, error) {
+	passages, err := c.keywords.retrieve(ctx, query, searchResults)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = io.Copy(purposeWriter, strings.NewReader("answers"))
-	if err != nil {
-		return err
+	documents := make([]dct.Document, len(passages))
+	for i, passage := range passages {
+		documents[i] = dct.Document{
+			Text:     passage.text,
+			Metadata: passage.metadata,
+		}
 	}
 
-	fileWriter, err = multipartWriter.CreateFormFile("file", documentsFilename)
-	if err != nil {
-		return err
-	}
+	return documents, nil
+}
 
-	_, err = io.Copy(fileWriter, &documentsBody)
+// GetQuotes implements the nlp.NLPer.GetQuotes method and
+// returns the essay sentences best matching the provided question
+// from the sentences keyword index verbatim and without calling
+// the LLM provider.
+//
+// Sentences shorter than minQuoteWords words and repeated
+// sentences are skipped.
+func (c *Client) GetQuotes(ctx context.Context, question string) ([]Citation, error) {
+	passages, err := c.sentences.retrieve(ctx, question, quotesResults*quotesCandidateRatio)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	multipartWriter.Close()
+	quotes := []Citation{}
+	quoted := map[string]bool{}
+	for _, passage := range passages {
+		if len(quotes) == quotesResults {
+			break
+		}
 
-	if err := c.helper.sendRequest(
-		http.MethodPost,
-		"https://api.openai.com/v1/files",
-		&openAIDocumentsBody,
-		nil,
-		map[string]string{
-			"Content-Type": multipartWriter.FormDataContentType(),
-		},
-	); err != nil {
-		return err
+		if len(strings.Fields(passage.text)) < minQuoteWords || quoted[passage.text] {
+			continue
+		}
+		quoted[passage.text] = true
+
+		quotes = append(quotes, Citation{
+			ID:      passage.metadata,
+			Excerpt: passage.text,
+		})
 	}
 
-	return nil
+	return quotes, nil
 }
 
-func replaceFunc(input string) string {
-	return strings.Replace(input, ".", ".\n", -1)
-}
+// GetEmbedding implements the nlp.NLPer.GetEmbedding method
+// and returns the embedding vector of the provided text.
+func (c *Client) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	vectors, err := c.embeddings.getEmbeddings(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
 
-type getAnswerReqJSON struct {
-	Model           string     `json:"model"`
-	Question        string     `json:"question"`
-	Examples        [][]string `json:"examples"`
-	ExamplesContext string     `json:"examples_context"`
-	File            string     `json:"file"`
-	Temperature     float64    `json:"temperature"`
-	MaxTokens       int        `json:"max_tokens"`
-	Stop            []string   `json:"stop"`
-	User            string     `json:"user"`
+	return vectors[0], nil
 }
 
-type getAnswersRespJSON struct {
-	Answers []string `json:"answers"`
+func (c *Client) getRetriever() retriever {
+	switch c.retrieval.Method {
+	case KeywordRetrieval:
+		return c.keywords
+	case HybridRetrieval:
+		return &fusionRetriever{
+			retrievers: []weightedRetriever{
+				{
+					retriever: c.embeddings,
+					weight:    defaultWeight(c.retrieval.EmbeddingsWeight),
+				},
+				{
+					retriever: c.keywords,
+					weight:    defaultWeight(c.retrieval.KeywordWeight),
+				},
+			},
+		}
+	default:
+		return c.embeddings
+	}
 }
 
-type getFilterReqJSON struct {
-	Prompt      string  `json:"prompt"`
-	Temperature float64 `json:"temperature"`
-	MaxTokens   int     `json:"max_tokens"`
-	TopP        float64 `json:"top_p"`
-	LogProbs    int     `json:"logprobs"`
+// getEncoding returns the tokenizer of the provided model and
+// falls back to cl100k_base for models unknown to the tkn package
+// such as those served by other providers.
+func getEncoding(model string) (*tkn.Encoding, error) {
+	encoding, err := tkn.ForModel(model)
+	if errors.Is(err, tkn.ErrUnknownModel) {
+		return tkn.Get(tkn.CL100KBase)
+	}
+
+	return encoding, err
 }
 
-type getFilterRespJSON struct {
-	Choices []choice `json:"choices"`
+func defaultWeight(weight float64) float64 {
+	if weight == 0 {
+		return 1.0
+	}
+	return weight
 }
 
-type choice struct {
-	Text string `json:"text"`
+// getAnswerPrompt builds the answer prompt from the provided
+// conversation history and passages in order, stopping at the
+// first passage that would take the prompt over the token
+// budget, and returns the passages that were included.
+func getAnswerPrompt(encoding *tkn.Encoding, answers *answersTemplate, budget int, question string, history []Turn, passages []passage) (string, []passage, error) {
+	data := answerData{
+		ExamplesContext: answers.ExamplesContext,
+		Examples:        answers.Examples,
+		History:         history,
+		Question:        question,
+	}
+
+	prompt, err := answers.execute(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	included := []passage{}
+	for _, passage := range passages {
+		data.Context += strings.TrimSpace(passage.text) + "\n---\n"
+		candidate, err := answers.execute(data)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if encoding.Count(candidate) > budget {
+			break
+		}
+
+		prompt = candidate
+		included = append(included, passage)
+	}
+
+	return prompt, included, nil
 }
 
 // GetAnswer implements the nlp.NLPer.GetAnswer method
-// and generates answers to the provided question using OpenAI.
-func (c *Client) GetAnswer(ctx context.Context, question, userID string) (*string, error) {
-	getFilesRespBody := getFilesRespJSON{}
-	if err := c.helper.sendRequest(
-		http.MethodGet,
-		"https://api.openai.com/v1/files",
-		nil,
-		&getFilesRespBody,
-		map[string]string{
-			"Content-Type": "application/json",
-		},
-	); err != nil {
+// and generates answers to the provided question using the
+// most relevant stored document paragraphs and OpenAI.
+//
+// Questions are answered as follow-ups to the conversation
+// history set on the context with WithHistory, keeping the most
+// recent turns within a quarter of the context window, and in
+// the answer mode set with WithMode.
+//
+// The question is moderated before any other requests are made,
+// unless its Moderation is set on the context, and the answer
+// before it is returned, and either being flagged returns a
+// refusal Answer. Answers are then checked against the
+// passages they were generated from and flagged rather than
+// regenerated when they are not grounded in them.
+func (c *Client) GetAnswer(ctx context.Context, question, userID string) (*Answer, error) {
+	moderations, refusal, err := c.moderateQuestion(ctx, question)
+	if err != nil || refusal != nil {
+		return refusal, err
+	}
+
+	answers, answer, err := c.getAnswersPrompt(ctx, moderations)
+	if err != nil {
 		return nil, err
 	}
 
-	fileID := ""
-	for _, file := range getFilesRespBody.Data {
-		if file.Name == documentsFilename {
-			fileID = file.ID
-		}
+	request, passages, err := c.getAnswerRequest(ctx, answers, question, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(question) > 100 {
-		return nil, errors.New("question must be less than or equal to 100 characters")
+	response, err := c.completer.complete(ctx, *request)
+	if err != nil {
+		return nil, err
 	}
+	recordUsage(ctx, request.model, &response.usage)
 
-	getAnswerReq := getAnswerReqJSON{
-		Model:    answersModel,
-		Question: question,
-		Examples: [][]string{
-			{
-				"What is the secret to a successful startup?",
-				"What you need to succeed in a startup is not expertise in startups. What you need is expertise in your own users.",
-			},
-			{
-				"What do I do to grow my company?",
-				"The way to make your startup grow, is to make something users really love.",
-			},
-		},
-		ExamplesContext: "Users are the most important thing to a startup.",
-		File:            fileID,
-		MaxTokens:       answersMaxTokens,
-		Temperature:     answersTemperature,
-		Stop: []string{
-			"\n---",
-			"\n===",
-			".",
-			"<|endoftext|>",
-		},
-		User: userID,
+	return c.getAnswer(ctx, answers, response.text, passages, answer)
+}
+
+// StreamAnswer implements the nlp.NLPer.StreamAnswer method
+// and generates answers in the same way as GetAnswer while
+// passing each token to the send function as it is received.
+//
+// Tokens are held back until the sentences they complete pass
+// moderation, and the text after the last complete sentence
+// until the whole answer passes, so that no part of a flagged
+// answer is sent. Held back tokens are sent joined together. The
+// streamed tokens are unformatted so the returned Answer should
+// replace them once the stream ends.
+func (c *Client) StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*Answer, error) {
+	moderations, refusal, err := c.moderateQuestion(ctx, question)
+	if err != nil || refusal != nil {
+		return refusal, err
 	}
 
-	getAnswerReqBytes, err := json.Marshal(getAnswerReq)
+	answers, answer, err := c.getAnswersPrompt(ctx, moderations)
 	if err != nil {
 		return nil, err
 	}
 
-	getAnswersRespBody := getAnswersRespJSON{}
-	if err := c.helper.sendRequest(
-		http.MethodPost,
-		"https://api.openai.com/v1/answers",
-		bytes.NewReader(getAnswerReqBytes),
-		&getAnswersRespBody,
-		map[string]string{
-			"Content-Type": "application/json",
-		},
-	); err != nil {
+	request, passages, err := c.getAnswerRequest(ctx, answers, question, userID)
+	if err != nil {
 		return nil, err
 	}
 
-	answer := ""
-	for _, responseAnswer := range getAnswersRespBody.Answers {
-		answer = formatString(responseAnswer)
+	stream := &sentenceStream{
+		ctx:    ctx,
+		client: c,
+		send:   send,
 	}
 
-	getFilterReq := getFilterReqJSON{
-		Prompt:      fmt.Sprintf("<|endoftext|>%s\n--\nLabel:", answer),
-		Temperature: 0,
-		MaxTokens:   1,
-		TopP:        0,
-		LogProbs:    10,
+	response, err := c.completer.stream(ctx, *request, stream.receive)
+	if err != nil {
+		return nil, err
+	}
+	recordUsage(ctx, request.model, &response.usage)
+
+	if stream.flagged != nil {
+		return getAnswerRefusal(answer, *stream.flagged), nil
 	}
 
-	getFilterReqBytes, err := json.Marshal(getFilterReq)
+	answer, err = c.getAnswer(ctx, answers, response.text, passages, answer)
 	if err != nil {
 		return nil, err
 	}
 
-	getFilterRespBody := getFilterRespJSON{}
-	if err := c.helper.sendRequest(
-		http.MethodPost,
-		"https://api.openai.com/v1/engines/content-filter-alpha/completions",
-		bytes.NewReader(getFilterReqBytes),
-		&getFilterRespBody,
-		map[string]string{
-			"Content-Type": "application/json",
-		},
-	); err != nil {
+	if answer.Refusal == nil {
+		if err := stream.flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return answer, nil
+}
+
+// sentenceStream passes streamed tokens to send a sentence at a
+// time once each sentence passes moderation.
+type sentenceStream struct {
+	ctx     context.Context
+	client  *Client
+	send    func(token string) error
+	pending string
+	flagged *Moderation
+}
+
+// receive holds back the token and moderates the sentences it
+// completes, found with dct.SentencesEnd, sending them when they
+// pass and dropping every later token when flagged. Only newly
+// completed sentences are moderated so that each part of the
+// answer is moderated once while streaming.
+func (s *sentenceStream) receive(token string) error {
+	if s.flagged != nil {
+		return nil
+	}
+
+	s.pending += token
+	end := dct.SentencesEnd(s.pending)
+	if end == 0 {
+		return nil
+	}
+
+	sentences := s.pending[:end]
+	moderation, err := s.client.moderate(s.ctx, AnswerTarget, sentences)
+	if err != nil {
+		return err
+	}
+
+	if moderation.Flagged {
+		s.flagged = moderation
+		return nil
+	}
+
+	s.pending = s.pending[end:]
+
+	return s.send(sentences)
+}
+
+// flush sends the held back text.
+func (s *sentenceStream) flush() error {
+	if s.pending == "" {
+		return nil
+	}
+
+	pending := s.pending
+	s.pending = ""
+
+	return s.send(pending)
+}
+
+type answersModelKey struct{}
+
+// WithAnswersModel returns a copy of the provided context which
+// generates answers with the provided model in place of the
+// Provider AnswersModel.
+func WithAnswersModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, answersModelKey{}, model)
+}
+
+// GetAnswersModel returns the model set on the provided context
+// with WithAnswersModel and false when there is none.
+func GetAnswersModel(ctx context.Context) (string, bool) {
+	model, ok := ctx.Value(answersModelKey{}).(string)
+	return model, ok
+}
+
+// getAnswersPrompt returns the answers prompt of the answer mode
+// set on the context along with the Answer to complete holding
+// the moderations, prompt version, and mode.
+func (c *Client) getAnswersPrompt(ctx context.Context, moderations []Moderation) (*answersTemplate, *Answer, error) {
+	p, err := c.getPrompts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mode := GetMode(ctx)
+	answers, ok := p.Answers[mode]
+	if !ok {
+		return nil, nil, fmt.Errorf("nlp: unsupported answer mode %q", mode)
+	}
+
+	return answers, &Answer{
+		Moderations:   moderations,
+		PromptVersion: p.Version,
+		Mode:          mode,
+	}, nil
+}
+
+func (c *Client) getAnswerRequest(ctx context.Context, answers *answersTemplate, question, userID string) (*completionRequest, []passage, error) {
+	model, ok := GetAnswersModel(ctx)
+	if !ok {
+		model = c.answersModel
+	}
+
+	encoding, err := getEncoding(model)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	history := getHistory(encoding, c.contextTokens/historyBudgetRatio, GetHistory(ctx))
+
+	passages, err := c.getRetriever().retrieve(ctx, getRetrievalQuery(history, question), answersPassages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	budget := c.contextTokens - answers.MaxTokens - promptOverheadTokens - encoding.Count(answers.System)
+	prompt, passages, err := getAnswerPrompt(encoding, answers, budget, question, history, passages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &completionRequest{
+		model:       model,
+		system:      answers.System,
+		prompt:      prompt,
+		maxTokens:   answers.MaxTokens,
+		temperature: answers.Temperature,
+		stop:        answers.Stop,
+		user:        userID,
+	}, passages, nil
+}
+
+// getAnswer completes the provided Answer with the formatted
+// text, citations, and grounding or returns a refusal in its
+// place when the text is flagged.
+func (c *Client) getAnswer(ctx context.Context, answers *answersTemplate, text string, passages []passage, answer *Answer) (*Answer, error) {
+	text = answers.format(text)
+	if text == "" {
+		return answer, nil
+	}
+
+	moderation, err := c.moderate(ctx, AnswerTarget, text)
+	if err != nil {
+		return nil, err
+	}
+
+	if moderation.Flagged {
+		return getAnswerRefusal(answer, *moderation), nil
+	}
+
+	grounding, err := c.verify(ctx, text, passages)
+	if err != nil {
 		return nil, err
 	}
 
-	if len(getFilterRespBody.Choices) > 0 && getFilterRespBody.Choices[0].Text == "2" {
-		answer = ""
+	answer.Text = text
+	answer.Citations = getCitations(passages)
+	answer.Moderations = append(answer.Moderations, *moderation)
+	answer.Grounding = grounding
+
+	return answer, nil
+}
+
+// getAnswerRefusal returns the refusal sent in place of the
+// provided Answer when its text is flagged by the moderation.
+func getAnswerRefusal(answer *Answer, moderation Moderation) *Answer {
+	refusal := GetRefusal(append(answer.Moderations, moderation))
+	refusal.PromptVersion = answer.PromptVersion
+	refusal.Mode = answer.Mode
+
+	return refusal
+}
+
+func getCitations(passages []passage) []Citation {
+	citations := []Citation{}
+	cited := map[string]bool{}
+	for _, passage := range passages {
+		if cited[passage.metadata] {
+			continue
+		}
+		cited[passage.metadata] = true
+
+		citations = append(citations, Citation{
+			ID:      passage.metadata,
+			Excerpt: strings.TrimSpace(passage.text),
+		})
 	}
 
-	return &answer, nil
+	return citations
 }
 
 func formatString(input string) string {
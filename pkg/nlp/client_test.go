@@ -1,22 +1,30 @@
 package nlp
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/idx"
 )
 
 func TestNew(t *testing.T) {
-	client := New(session.New(), "api_key", "bucket_name")
+	client := New(session.New(), OpenAIProvider("api_key"), "bucket_name", Retrieval{
+		Method: HybridRetrieval,
+	})
 	if client == nil {
 		t.Errorf("incorrect client, received: %v", client)
 	}
@@ -28,11 +36,12 @@ type mockHelper struct {
 }
 
 type response struct {
-	body  []byte
-	error error
+	body   []byte
+	events []string
+	error  error
 }
 
-func (m *mockHelper) sendRequest(method, url string, body io.Reader, payload interface{}, headers map[string]string) error {
+func (m *mockHelper) sendRequest(ctx context.Context, method, path string, body io.Reader, payload interface{}, headers map[string]string) error {
 	if len(m.responses) == 0 {
 		m.t.Fatal("no mock responses")
 	}
@@ -52,27 +61,123 @@ func (m *mockHelper) sendRequest(method, url string, body io.Reader, payload int
 	return nil
 }
 
+func (m *mockHelper) streamRequest(ctx context.Context, method, path string, body io.Reader, headers map[string]string, receive func(data []byte) error) error {
+	if len(m.responses) == 0 {
+		m.t.Fatal("no mock responses")
+	}
+
+	resp := response{}
+	resp, m.responses = m.responses[0], m.responses[1:]
+
+	if resp.error != nil {
+		return resp.error
+	}
+	for _, event := range resp.events {
+		if err := receive([]byte(event)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var mockModerator = KeywordModerator{
+	Keywords: map[string][]string{
+		"violence": {
+			"attack",
+		},
+	},
+}
+
+// recordingModerator records the texts moderated with the
+// wrapped Moderator.
+type recordingModerator struct {
+	moderator Moderator
+	texts     []string
+}
+
+func (r *recordingModerator) Moderate(ctx context.Context, text string) (*Moderation, error) {
+	r.texts = append(r.texts, text)
+	return r.moderator.Moderate(ctx, text)
+}
+
+// mockPromptsS3Client returns an S3 client without uploaded
+// prompts so that defaultPrompts are used.
+func mockPromptsS3Client() *mockS3Client {
+	return &mockS3Client{
+		mockGetObjectError: awserr.New(s3.ErrCodeNoSuchKey, "mock no such key", nil),
+	}
+}
+
 type mockS3Client struct {
 	mockGetObjectOutput *s3.GetObjectOutput
 	mockGetObjectError  error
+	mockPutObjectError  error
 }
 
-func (m *mockS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+func (m *mockS3Client) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
 	return m.mockGetObjectOutput, m.mockGetObjectError
 }
 
+func (m *mockS3Client) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	return nil, m.mockPutObjectError
+}
+
 func TestGetSummary(t *testing.T) {
 	getSummaryErr := errors.New("mock get summary error")
-	summary := "Mock summary."
+	summary := &Summary{
+		Text:          "Mock summary.",
+		PromptVersion: defaultPrompts.Version,
+	}
+	finalSummary := &Summary{
+		Text:          "Mock final summary.",
+		PromptVersion: defaultPrompts.Version,
+	}
+	paragraph := strings.Repeat("mock ", 1500)
+	longText := strings.Join([]string{paragraph, paragraph, paragraph}, ".\n")
 
 	tests := []struct {
 		description string
+		text        string
 		responses   []response
-		summary     *string
+		summary     *Summary
 		error       error
 	}{
+		{
+			description: "error getting chunk summary",
+			text:        longText,
+			responses: []response{
+				{
+					body:  nil,
+					error: getSummaryErr,
+				},
+			},
+			summary: nil,
+			error:   getSummaryErr,
+		},
+		{
+			description: "successful invocation over context token budget",
+			text:        longText,
+			responses: []response{
+				{
+					body:  []byte(`{"choices": [{"text": "first chunk summary"}]}`),
+					error: nil,
+				},
+				{
+					body:  []byte(`{"choices": [{"text": "second chunk summary"}]}`),
+					error: nil,
+				},
+				{
+					body:  []byte(`{"choices": [{"text": "mock final summary"}]}`),
+					error: nil,
+				},
+			},
+			summary: finalSummary,
+			error:   nil,
+		},
 		{
 			description: "error getting summary",
+			text:        "mock text",
 			responses: []response{
 				{
 					body:  nil,
@@ -84,13 +189,14 @@ func TestGetSummary(t *testing.T) {
 		},
 		{
 			description: "successful invocation",
+			text:        "mock text",
 			responses: []response{
 				{
 					body:  []byte(`{"choices": [{"text": "mock summary"}]}`),
 					error: nil,
 				},
 			},
-			summary: &summary,
+			summary: summary,
 			error:   nil,
 		},
 	}
@@ -104,58 +210,149 @@ func TestGetSummary(t *testing.T) {
 
 			c := &Client{
 				helper: h,
+				completer: &textCompleter{
+					helper: h,
+				},
+				contextTokens: defaultContextTokens,
+				s3Client:      mockPromptsS3Client(),
 			}
 
-			summary, err := c.GetSummary(context.Background(), "mock text")
+			summary, err := c.GetSummary(context.Background(), test.text)
 			if err != test.error {
 				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
 			}
 
-			if test.summary != nil && *summary != *test.summary {
-				t.Errorf("incorrect summary, received: %v, expected: %v", *summary, *test.summary)
+			if !reflect.DeepEqual(summary, test.summary) {
+				t.Errorf("incorrect summary, received: %+v, expected: %+v", summary, test.summary)
+			}
+
+			if len(h.responses) != 0 {
+				t.Errorf("incorrect requests, %d mock responses unused", len(h.responses))
 			}
 		})
 	}
 }
 
-func TestSetDocuments(t *testing.T) {
-	setDocumentsErr := errors.New("mock set answers error")
+func Test_getSummaryChunks(t *testing.T) {
+	encoding, err := getEncoding(chatModel)
+	if err != nil {
+		t.Fatalf("error getting encoding: %v", err)
+	}
 
 	tests := []struct {
 		description string
-		responses   []response
-		error       error
+		text        string
+		chunks      []string
+	}{
+		{
+			description: "paragraphs within budget",
+			text:        "first paragraph.\nsecond paragraph",
+			chunks: []string{
+				"first paragraph.\nsecond paragraph",
+			},
+		},
+		{
+			description: "paragraphs over budget",
+			text:        strings.Repeat("mock ", 6) + ".\n" + strings.Repeat("mock ", 6),
+			chunks: []string{
+				strings.TrimSpace(strings.Repeat("mock ", 6)) + ".",
+				strings.TrimSpace(strings.Repeat("mock ", 6)),
+			},
+		},
+		{
+			description: "single paragraph over budget",
+			text:        strings.Repeat("mock ", 10),
+			chunks: []string{
+				strings.Repeat("mock ", 8) + "mock",
+				" mock",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			chunks := getSummaryChunks(encoding, 9, test.text)
+			if !reflect.DeepEqual(chunks, test.chunks) {
+				t.Errorf("incorrect chunks, received: %q, expected: %q", chunks, test.chunks)
+			}
+		})
+	}
+}
+
+func TestSetDocuments(t *testing.T) {
+	getEmbeddingsErr := errors.New("mock get embeddings error")
+	putObjectErr := errors.New("mock put object error")
+
+	tests := []struct {
+		description        string
+		retrieval          Retrieval
+		responses          []response
+		mockPutObjectError error
+		error              error
 	}{
 		{
-			description: "error setting documents",
+			description: "error getting embeddings",
 			responses: []response{
 				{
 					body:  nil,
-					error: setDocumentsErr,
+					error: getEmbeddingsErr,
+				},
+			},
+			mockPutObjectError: nil,
+			error:              getEmbeddingsErr,
+		},
+		{
+			description: "error putting embeddings object",
+			responses: []response{
+				{
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+					error: nil,
 				},
 			},
-			error: setDocumentsErr,
+			mockPutObjectError: putObjectErr,
+			error:              putObjectErr,
 		},
 		{
 			description: "successful invocation",
 			responses: []response{
 				{
-					body:  nil,
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
 					error: nil,
 				},
 			},
-			error: nil,
+			mockPutObjectError: nil,
+			error:              nil,
+		},
+		{
+			description: "successful invocation without embeddings",
+			retrieval: Retrieval{
+				Method: KeywordRetrieval,
+			},
+			responses:          []response{},
+			mockPutObjectError: nil,
+			error:              nil,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.description, func(t *testing.T) {
+			h := &mockHelper{
+				t:         t,
+				responses: test.responses,
+			}
+
 			c := &Client{
-				helper: &mockHelper{
-					t:         t,
-					responses: test.responses,
-				},
+				helper:     h,
 				bucketName: "bucket_name",
+				retrieval:  test.retrieval,
+				s3Client: &mockS3Client{
+					mockPutObjectError: test.mockPutObjectError,
+				},
+				embeddings: &embeddingsRetriever{
+					helper: h,
+				},
+				keywords:  &keywordRetriever{},
+				sentences: &keywordRetriever{},
 			}
 
 			err := c.SetDocuments(context.Background(), []dct.Document{
@@ -172,33 +369,121 @@ func TestSetDocuments(t *testing.T) {
 }
 
 func TestGetAnswers(t *testing.T) {
-	getFilesErr := errors.New("mock get files error")
+	getEmbeddingsErr := errors.New("mock get embeddings error")
+	getObjectErr := errors.New("mock get object error")
 	getAnswersErr := errors.New("mock get answers error")
 
-	mockAnswer := "Answer."
+	questionModeration := Moderation{
+		Target:     QuestionTarget,
+		Categories: []string{},
+		Scores: map[string]float64{
+			"violence": 0,
+		},
+	}
+
+	mockAnswer := &Answer{
+		Text: "Answer.",
+		Citations: []Citation{
+			{
+				ID:      "mock_id",
+				Excerpt: "mock text",
+			},
+		},
+		Moderations: []Moderation{
+			questionModeration,
+			{
+				Target:     AnswerTarget,
+				Categories: []string{},
+				Scores: map[string]float64{
+					"violence": 0,
+				},
+			},
+		},
+		Grounding: &Grounding{
+			Score:   0,
+			Flagged: true,
+			Claims: []Claim{
+				{
+					Text:      "Answer.",
+					Score:     0,
+					Supported: false,
+				},
+			},
+		},
+		PromptVersion: defaultPrompts.Version,
+		Mode:          DefaultMode,
+	}
+	mockEmbeddings := `{"text": "mock text", "metadata": "mock_id", "embedding": [0.1, 0.2]}
+{"text": "other mock text", "metadata": "mock_id", "embedding": [0.2, 0.1]}`
 
 	tests := []struct {
-		description string
-		responses   []response
-		answer      *string
-		error       error
+		description         string
+		question            string
+		responses           []response
+		mockGetObjectOutput *s3.GetObjectOutput
+		mockGetObjectError  error
+		answer              *Answer
+		error               error
 	}{
 		{
-			description: "error getting file",
+			description: "flagged question refused",
+			question:    "how do I attack a rival",
+			responses:   nil,
+			answer: &Answer{
+				Text: questionRefusal,
+				Refusal: &Refusal{
+					Target: QuestionTarget,
+					Categories: []string{
+						"violence",
+					},
+				},
+				Moderations: []Moderation{
+					{
+						Target:  QuestionTarget,
+						Flagged: true,
+						Categories: []string{
+							"violence",
+						},
+						Scores: map[string]float64{
+							"violence": 1,
+						},
+					},
+				},
+			},
+			error: nil,
+		},
+		{
+			description: "error getting question embedding",
+			question:    "question",
 			responses: []response{
 				{
 					body:  nil,
-					error: getFilesErr,
+					error: getEmbeddingsErr,
 				},
 			},
 			answer: nil,
-			error:  getFilesErr,
+			error:  getEmbeddingsErr,
+		},
+		{
+			description: "error getting stored embeddings",
+			question:    "question",
+			responses: []response{
+				{
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+					error: nil,
+				},
+			},
+			mockGetObjectOutput: nil,
+			mockGetObjectError:  getObjectErr,
+			answer:              nil,
+			error:               getObjectErr,
 		},
 		{
 			description: "error getting answers",
+			question:    "question",
 			responses: []response{
 				{
-					body:  []byte(fmt.Sprintf(`{"data": [{"id": "mock_id", "filename": %q}]}`, documentsFilename)),
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
 					error: nil,
 				},
 				{
@@ -206,27 +491,101 @@ func TestGetAnswers(t *testing.T) {
 					error: getAnswersErr,
 				},
 			},
-			answer: nil,
-			error:  getAnswersErr,
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(mockEmbeddings)),
+			},
+			mockGetObjectError: nil,
+			answer:             nil,
+			error:              getAnswersErr,
 		},
 		{
 			description: "successful invocation",
+			question:    "question",
 			responses: []response{
 				{
-					body:  []byte(fmt.Sprintf(`{"data": [{"id": "mock_id", "filename": %q}]}`, documentsFilename)),
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
 					error: nil,
 				},
 				{
-					body:  []byte(`{"answers": [" answer "]}`),
+					body:  []byte(`{"choices": [{"text": " answer "}]}`),
+					error: nil,
+				},
+			},
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(mockEmbeddings)),
+			},
+			mockGetObjectError: nil,
+			answer:             mockAnswer,
+			error:              nil,
+		},
+		{
+			description: "successful invocation with flagged answer",
+			question:    "question",
+			responses: []response{
+				{
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
 					error: nil,
 				},
 				{
-					body:  []byte(`{"choices": [{"text": "0"}]}`),
+					body:  []byte(`{"choices": [{"text": " attack them "}]}`),
 					error: nil,
 				},
 			},
-			answer: &mockAnswer,
-			error:  nil,
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(mockEmbeddings)),
+			},
+			mockGetObjectError: nil,
+			answer: &Answer{
+				Text: answerRefusal,
+				Refusal: &Refusal{
+					Target: AnswerTarget,
+					Categories: []string{
+						"violence",
+					},
+				},
+				Moderations: []Moderation{
+					questionModeration,
+					{
+						Target:  AnswerTarget,
+						Flagged: true,
+						Categories: []string{
+							"violence",
+						},
+						Scores: map[string]float64{
+							"violence": 1,
+						},
+					},
+				},
+				PromptVersion: defaultPrompts.Version,
+				Mode:          DefaultMode,
+			},
+			error: nil,
+		},
+		{
+			description: "successful invocation without answer",
+			question:    "question",
+			responses: []response{
+				{
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+					error: nil,
+				},
+				{
+					body:  []byte(`{"choices": [{"text": ""}]}`),
+					error: nil,
+				},
+			},
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(mockEmbeddings)),
+			},
+			mockGetObjectError: nil,
+			answer: &Answer{
+				Moderations: []Moderation{
+					questionModeration,
+				},
+				PromptVersion: defaultPrompts.Version,
+				Mode:          DefaultMode,
+			},
+			error: nil,
 		},
 	}
 
@@ -239,14 +598,591 @@ func TestGetAnswers(t *testing.T) {
 
 			c := &Client{
 				helper: h,
+				completer: &textCompleter{
+					helper: h,
+				},
+				moderator:          mockModerator,
+				verifier:           LexicalVerifier{},
+				groundingThreshold: DefaultGroundingThreshold,
+				contextTokens:      defaultContextTokens,
+				s3Client:           mockPromptsS3Client(),
+				embeddings: &embeddingsRetriever{
+					helper: h,
+					s3Client: &mockS3Client{
+						mockGetObjectOutput: test.mockGetObjectOutput,
+						mockGetObjectError:  test.mockGetObjectError,
+					},
+				},
 			}
 
-			answers, err := c.GetAnswer(context.Background(), "question", "userID")
+			answers, err := c.GetAnswer(context.Background(), test.question, "userID")
 			if err != test.error {
 				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
 			}
 			if !reflect.DeepEqual(answers, test.answer) {
-				t.Errorf("incorrect answers, received: %v, expected: %v", answers, test.answer)
+				t.Errorf("incorrect answers, received: %+v, expected: %+v", answers, test.answer)
+			}
+		})
+	}
+}
+
+func TestStreamAnswer(t *testing.T) {
+	getAnswersErr := errors.New("mock get answers error")
+	sendErr := errors.New("mock send error")
+
+	mockEmbeddings := `{"text": "mock text", "metadata": "mock_id", "embedding": [0.1, 0.2]}`
+
+	tests := []struct {
+		description string
+		responses   []response
+		sendError   error
+		tokens      []string
+		moderated   []string
+		answer      *Answer
+		error       error
+	}{
+		{
+			description: "error streaming answer",
+			responses: []response{
+				{
+					body: []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+				},
+				{
+					error: getAnswersErr,
+				},
+			},
+			tokens: nil,
+			answer: nil,
+			error:  getAnswersErr,
+		},
+		{
+			description: "error sending token",
+			responses: []response{
+				{
+					body: []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+				},
+				{
+					events: []string{
+						`{"choices": [{"text": " an"}]}`,
+						`{"choices": [{"text": "swer "}]}`,
+					},
+				},
+			},
+			sendError: sendErr,
+			tokens: []string{
+				" answer ",
+			},
+			answer: nil,
+			error:  sendErr,
+		},
+		{
+			description: "successful invocation",
+			responses: []response{
+				{
+					body: []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+				},
+				{
+					events: []string{
+						`{"choices": [{"text": " an"}]}`,
+						`{"choices": [{"text": "swer "}]}`,
+						`{"choices": [], "usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`,
+					},
+				},
+			},
+			tokens: []string{
+				" answer ",
+			},
+			answer: &Answer{
+				Text: "Answer.",
+				Citations: []Citation{
+					{
+						ID:      "mock_id",
+						Excerpt: "mock text",
+					},
+				},
+				Moderations: []Moderation{
+					{
+						Target:     QuestionTarget,
+						Categories: []string{},
+						Scores: map[string]float64{
+							"violence": 0,
+						},
+					},
+					{
+						Target:     AnswerTarget,
+						Categories: []string{},
+						Scores: map[string]float64{
+							"violence": 0,
+						},
+					},
+				},
+				Grounding: &Grounding{
+					Score:   0,
+					Flagged: true,
+					Claims: []Claim{
+						{
+							Text:      "Answer.",
+							Score:     0,
+							Supported: false,
+						},
+					},
+				},
+				PromptVersion: defaultPrompts.Version,
+				Mode:          DefaultMode,
+			},
+			error: nil,
+		},
+		{
+			description: "flagged sentence not sent",
+			responses: []response{
+				{
+					body: []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+				},
+				{
+					events: []string{
+						`{"choices": [{"text": " Ideas matter!"}]}`,
+						`{"choices": [{"text": " It costs 3.5"}]}`,
+						`{"choices": [{"text": " times more. Then attack"}]}`,
+						`{"choices": [{"text": "! Later"}]}`,
+						`{"choices": [{"text": " text."}]}`,
+					},
+				},
+			},
+			tokens: []string{
+				" Ideas matter! ",
+				"It costs 3.5 times more. ",
+			},
+			moderated: []string{
+				"question",
+				" Ideas matter! ",
+				"It costs 3.5 times more. ",
+				"Then attack! ",
+			},
+			answer: &Answer{
+				Text: answerRefusal,
+				Refusal: &Refusal{
+					Target: AnswerTarget,
+					Categories: []string{
+						"violence",
+					},
+				},
+				Moderations: []Moderation{
+					{
+						Target:     QuestionTarget,
+						Categories: []string{},
+						Scores: map[string]float64{
+							"violence": 0,
+						},
+					},
+					{
+						Target:  AnswerTarget,
+						Flagged: true,
+						Categories: []string{
+							"violence",
+						},
+						Scores: map[string]float64{
+							"violence": 1,
+						},
+					},
+				},
+				PromptVersion: defaultPrompts.Version,
+				Mode:          DefaultMode,
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			h := &mockHelper{
+				t:         t,
+				responses: test.responses,
+			}
+
+			c := &Client{
+				helper: h,
+				completer: &textCompleter{
+					helper: h,
+				},
+				moderator:          mockModerator,
+				verifier:           LexicalVerifier{},
+				groundingThreshold: DefaultGroundingThreshold,
+				contextTokens:      defaultContextTokens,
+				s3Client:           mockPromptsS3Client(),
+				embeddings: &embeddingsRetriever{
+					helper: h,
+					s3Client: &mockS3Client{
+						mockGetObjectOutput: &s3.GetObjectOutput{
+							Body: io.NopCloser(strings.NewReader(mockEmbeddings)),
+						},
+					},
+				},
+			}
+
+			moderator := &recordingModerator{
+				moderator: mockModerator,
+			}
+			c.moderator = moderator
+
+			var tokens []string
+			answer, err := c.StreamAnswer(context.Background(), "question", "userID", func(token string) error {
+				tokens = append(tokens, token)
+				return test.sendError
+			})
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(tokens, test.tokens) {
+				t.Errorf("incorrect tokens, received: %q, expected: %q", tokens, test.tokens)
+			}
+
+			if test.moderated != nil && !reflect.DeepEqual(moderator.texts, test.moderated) {
+				t.Errorf("incorrect moderated texts, received: %q, expected: %q", moderator.texts, test.moderated)
+			}
+
+			if !reflect.DeepEqual(answer, test.answer) {
+				t.Errorf("incorrect answer, received: %v, expected: %v", answer, test.answer)
+			}
+		})
+	}
+}
+
+func Test_getAnswerPrompt(t *testing.T) {
+	encoding, err := getEncoding(chatModel)
+	if err != nil {
+		t.Fatalf("error getting encoding: %v", err)
+	}
+
+	passages := []passage{
+		{
+			text:     "first mock text",
+			metadata: "first_id",
+		},
+		{
+			text:     strings.Repeat("second mock text ", 100),
+			metadata: "second_id",
+		},
+		{
+			text:     "third mock text",
+			metadata: "third_id",
+		},
+	}
+
+	base, _, err := getAnswerPrompt(encoding, defaultPrompts.Answers[DefaultMode], defaultContextTokens, "question", nil, nil)
+	if err != nil {
+		t.Fatalf("error getting base prompt: %v", err)
+	}
+	baseTokens := encoding.Count(base)
+
+	history := []Turn{
+		{
+			Question: "earlier question",
+			Answer:   "Earlier answer.",
+		},
+	}
+
+	tests := []struct {
+		description string
+		budget      int
+		history     []Turn
+		included    []passage
+		turn        string
+	}{
+		{
+			description: "no passages within budget",
+			budget:      baseTokens,
+			history:     nil,
+			included:    []passage{},
+			turn:        "",
+		},
+		{
+			description: "passages after budget exceeded dropped",
+			budget:      baseTokens + 20,
+			history:     nil,
+			included:    passages[:1],
+			turn:        "",
+		},
+		{
+			description: "all passages within budget",
+			budget:      defaultContextTokens,
+			history:     nil,
+			included:    passages,
+			turn:        "",
+		},
+		{
+			description: "history included before question",
+			budget:      defaultContextTokens,
+			history:     history,
+			included:    passages,
+			turn:        "Q: earlier question\nA: Earlier answer.\n\nQ: question",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			prompt, included, err := getAnswerPrompt(encoding, defaultPrompts.Answers[DefaultMode], test.budget, "question", test.history, passages)
+			if err != nil {
+				t.Fatalf("error getting prompt: %v", err)
+			}
+
+			if !reflect.DeepEqual(included, test.included) {
+				t.Errorf("incorrect passages, received: %v, expected: %v", included, test.included)
+			}
+
+			if tokens := encoding.Count(prompt); tokens > test.budget {
+				t.Errorf("incorrect prompt tokens, received: %d, expected at most: %d", tokens, test.budget)
+			}
+
+			if !strings.HasSuffix(prompt, "\nQ: question\nA:") {
+				t.Errorf("incorrect prompt, received: %q", prompt)
+			}
+
+			if !strings.Contains(prompt, test.turn) {
+				t.Errorf("incorrect prompt history, received: %q, expected: %q", prompt, test.turn)
+			}
+		})
+	}
+}
+
+func TestSearchDocuments(t *testing.T) {
+	getObjectErr := errors.New("mock get object error")
+
+	index, _ := json.Marshal(idx.New([]dct.Document{
+		{
+			Text:     "ramen profitable",
+			Metadata: "mock_id",
+		},
+	}))
+
+	tests := []struct {
+		description         string
+		mockGetObjectOutput *s3.GetObjectOutput
+		mockGetObjectError  error
+		documents           []dct.Document
+		error               error
+	}{
+		{
+			description:         "error getting stored index",
+			mockGetObjectOutput: nil,
+			mockGetObjectError:  getObjectErr,
+			documents:           nil,
+			error:               getObjectErr,
+		},
+		{
+			description: "successful invocation",
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(bytes.NewReader(index)),
+			},
+			mockGetObjectError: nil,
+			documents: []dct.Document{
+				{
+					Text:     "ramen profitable",
+					Metadata: "mock_id",
+				},
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				keywords: &keywordRetriever{
+					s3Client: &mockS3Client{
+						mockGetObjectOutput: test.mockGetObjectOutput,
+						mockGetObjectError:  test.mockGetObjectError,
+					},
+				},
+			}
+
+			documents, err := c.SearchDocuments(context.Background(), "ramen")
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+			if !reflect.DeepEqual(documents, test.documents) {
+				t.Errorf("incorrect documents, received: %v, expected: %v", documents, test.documents)
+			}
+		})
+	}
+}
+
+func TestGetQuotes(t *testing.T) {
+	getObjectErr := errors.New("mock get object error")
+
+	index, _ := json.Marshal(idx.New([]dct.Document{
+		{
+			Text:     "Ramen profitable?",
+			Metadata: "mock_id",
+		},
+		{
+			Text:     "Being ramen profitable changes the relationship with investors.",
+			Metadata: "mock_id",
+		},
+		{
+			Text:     "Being ramen profitable changes the relationship with investors.",
+			Metadata: "other_id",
+		},
+		{
+			Text:     "A startup that is ramen profitable can take its time raising money.",
+			Metadata: "other_id",
+		},
+		{
+			Text:     "Unrelated sentences are not quoted at all here.",
+			Metadata: "other_id",
+		},
+	}))
+
+	tests := []struct {
+		description         string
+		mockGetObjectOutput *s3.GetObjectOutput
+		mockGetObjectError  error
+		quotes              []Citation
+		error               error
+	}{
+		{
+			description:         "error getting stored index",
+			mockGetObjectOutput: nil,
+			mockGetObjectError:  getObjectErr,
+			quotes:              nil,
+			error:               getObjectErr,
+		},
+		{
+			description: "successful invocation",
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(bytes.NewReader(index)),
+			},
+			mockGetObjectError: nil,
+			quotes: []Citation{
+				{
+					ID:      "mock_id",
+					Excerpt: "Being ramen profitable changes the relationship with investors.",
+				},
+				{
+					ID:      "other_id",
+					Excerpt: "A startup that is ramen profitable can take its time raising money.",
+				},
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				sentences: &keywordRetriever{
+					s3Client: &mockS3Client{
+						mockGetObjectOutput: test.mockGetObjectOutput,
+						mockGetObjectError:  test.mockGetObjectError,
+					},
+				},
+			}
+
+			quotes, err := c.GetQuotes(context.Background(), "ramen profitable")
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+			if !reflect.DeepEqual(quotes, test.quotes) {
+				t.Errorf("incorrect quotes, received: %+v, expected: %+v", quotes, test.quotes)
+			}
+		})
+	}
+}
+
+func TestGetEmbedding(t *testing.T) {
+	getEmbeddingsErr := errors.New("mock get embeddings error")
+
+	tests := []struct {
+		description string
+		responses   []response
+		embedding   []float64
+		error       error
+	}{
+		{
+			description: "error getting embeddings",
+			responses: []response{
+				{
+					body:  nil,
+					error: getEmbeddingsErr,
+				},
+			},
+			embedding: nil,
+			error:     getEmbeddingsErr,
+		},
+		{
+			description: "successful invocation",
+			responses: []response{
+				{
+					body:  []byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`),
+					error: nil,
+				},
+			},
+			embedding: []float64{0.1, 0.2},
+			error:     nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				embeddings: &embeddingsRetriever{
+					helper: &mockHelper{
+						t:         t,
+						responses: test.responses,
+					},
+				},
+			}
+
+			embedding, err := c.GetEmbedding(context.Background(), "mock question")
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+			if !reflect.DeepEqual(embedding, test.embedding) {
+				t.Errorf("incorrect embedding, received: %v, expected: %v", embedding, test.embedding)
+			}
+		})
+	}
+}
+
+func TestWithAnswersModel(t *testing.T) {
+	tests := []struct {
+		description string
+		ctx         context.Context
+		model       string
+	}{
+		{
+			description: "provider answers model",
+			ctx:         context.Background(),
+			model:       "gpt-4o",
+		},
+		{
+			description: "override answers model",
+			ctx:         WithAnswersModel(context.Background(), "gpt-4o-mini"),
+			model:       "gpt-4o-mini",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				answersModel:  "gpt-4o",
+				contextTokens: defaultContextTokens,
+				retrieval: Retrieval{
+					Method: KeywordRetrieval,
+				},
+				keywords: &keywordRetriever{
+					index:  &idx.Index{},
+					loaded: time.Now(),
+				},
+			}
+
+			request, _, err := c.getAnswerRequest(test.ctx, defaultPrompts.Answers[DefaultMode], "question", "userID")
+			if err != nil {
+				t.Fatalf("error getting answer request: %v", err)
+			}
+
+			if request.model != test.model {
+				t.Errorf("incorrect model, received: %s, expected: %s", request.model, test.model)
 			}
 		})
 	}
@@ -0,0 +1,166 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type completer interface {
+	complete(ctx context.Context, request completionRequest) (*completion, error)
+	stream(ctx context.Context, request completionRequest, send func(text string) error) (*completion, error)
+}
+
+type completionRequest struct {
+	model       string
+	system      string
+	prompt      string
+	maxTokens   int
+	temperature float64
+	stop        []string
+	user        string
+}
+
+type completion struct {
+	text  string
+	usage usageJSON
+}
+
+type streamOptionsJSON struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type usageJSON struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+var _ completer = &textCompleter{}
+
+// textCompleter sends requests to the legacy completions API
+// which accepts a single prompt string.
+type textCompleter struct {
+	helper helper
+}
+
+type textCompletionReqJSON struct {
+	Model         string             `json:"model"`
+	Prompt        string             `json:"prompt"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   float64            `json:"temperature"`
+	Stop          []string           `json:"stop,omitempty"`
+	User          string             `json:"user,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	StreamOptions *streamOptionsJSON `json:"stream_options,omitempty"`
+}
+
+type textCompletionRespJSON struct {
+	Choices []choice   `json:"choices"`
+	Usage   *usageJSON `json:"usage"`
+}
+
+type choice struct {
+	Text string `json:"text"`
+}
+
+func (t *textCompleter) complete(ctx context.Context, request completionRequest) (*completion, error) {
+	data, err := json.Marshal(getTextCompletionReq(request))
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := textCompletionRespJSON{}
+	if err := t.helper.sendRequest(
+		ctx,
+		http.MethodPost,
+		"/v1/completions",
+		bytes.NewReader(data),
+		&responseBody,
+		map[string]string{
+			"Content-Type": "application/json",
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	if len(responseBody.Choices) == 0 {
+		return nil, errors.New("nlp: no completion choices received")
+	}
+
+	response := &completion{
+		text: responseBody.Choices[0].Text,
+	}
+	if responseBody.Usage != nil {
+		response.usage = *responseBody.Usage
+	}
+
+	return response, nil
+}
+
+func (t *textCompleter) stream(ctx context.Context, request completionRequest, send func(text string) error) (*completion, error) {
+	completionReq := getTextCompletionReq(request)
+	completionReq.Stream = true
+	completionReq.StreamOptions = &streamOptionsJSON{
+		IncludeUsage: true,
+	}
+
+	data, err := json.Marshal(completionReq)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &completion{}
+	text := strings.Builder{}
+	if err := t.helper.streamRequest(
+		ctx,
+		http.MethodPost,
+		"/v1/completions",
+		bytes.NewReader(data),
+		map[string]string{
+			"Content-Type": "application/json",
+		},
+		func(data []byte) error {
+			chunk := textCompletionRespJSON{}
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				return err
+			}
+
+			if chunk.Usage != nil {
+				response.usage = *chunk.Usage
+			}
+
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Text == "" {
+				return nil
+			}
+
+			text.WriteString(chunk.Choices[0].Text)
+			return send(chunk.Choices[0].Text)
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	response.text = text.String()
+
+	return response, nil
+}
+
+func getTextCompletionReq(request completionRequest) textCompletionReqJSON {
+	prompt := request.prompt
+	if request.system != "" {
+		prompt = request.system + "\n\n" + prompt
+	}
+
+	return textCompletionReqJSON{
+		Model:       request.model,
+		Prompt:      prompt,
+		MaxTokens:   request.maxTokens,
+		Temperature: request.temperature,
+		Stop:        request.stop,
+		User:        request.user,
+	}
+}
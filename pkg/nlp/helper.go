@@ -1,51 +1,229 @@
 package nlp
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxRetries     = 3
+	baseBackoff    = 500 * time.Millisecond
+	maxBackoff     = 8 * time.Second
+	maxErrorLength = 512
 )
 
 type helper interface {
-	sendRequest(method, url string, body io.Reader, payload interface{}, headers map[string]string) error
+	sendRequest(ctx context.Context, method, path string, body io.Reader, payload interface{}, headers map[string]string) error
+	streamRequest(ctx context.Context, method, path string, body io.Reader, headers map[string]string, receive func(data []byte) error) error
 }
 
 var _ helper = &help{}
 
 type help struct {
-	apiKey     string
+	provider   Provider
 	httpClient http.Client
+	sleep      func(ctx context.Context, d time.Duration) error
 }
 
-func (h *help) sendRequest(method, url string, body io.Reader, payload interface{}, headers map[string]string) error {
-	req, err := http.NewRequest(method, url, body)
+func (h *help) sendRequest(ctx context.Context, method, path string, body io.Reader, payload interface{}, headers map[string]string) error {
+	resp, err := h.do(ctx, method, path, body, headers)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", h.apiKey))
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	if err := decoder.Decode(&payload); err != nil {
+		return err
 	}
 
-	resp, err := h.httpClient.Do(req)
+	return nil
+}
+
+// streamRequest reads a server-sent events response and passes
+// the data of each event to the receive function until the
+// "[DONE]" event or the end of the response.
+func (h *help) streamRequest(ctx context.Context, method, path string, body io.Reader, headers map[string]string, receive func(data []byte) error) error {
+	resp, err := h.do(ctx, method, path, body, headers)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if string(data) == "[DONE]" {
+			return nil
+		}
+
+		if err := receive(data); err != nil {
 			return err
 		}
+	}
 
-		return fmt.Errorf("nlp: %s", body)
+	return scanner.Err()
+}
+
+// do sends the request and retries responses with a 429 or 5xx
+// status using jittered exponential backoff, waiting at least as
+// long as the provider Retry-After header asks. A Retry-After
+// over the maximum backoff, or one which would outlast the
+// context deadline, is returned to the caller instead.
+func (h *help) do(ctx context.Context, method, path string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	decoder := json.NewDecoder(resp.Body)
-	if err := decoder.Decode(&payload); err != nil {
-		return err
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, h.provider.BaseURL+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(h.provider.AuthHeader, h.provider.authorization())
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := h.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		apiErr, err := newAPIError(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		if !apiErr.Temporary() || attempt == maxRetries || apiErr.RetryAfter > maxBackoff {
+			return nil, apiErr
+		}
+
+		wait := getBackoff(attempt)
+		if apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, apiErr
+		}
+
+		sleep := h.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
 	}
+}
 
-	return nil
+// sleepContext waits for the provided duration or until the
+// context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getBackoff returns a random wait of up to the exponential
+// backoff for the provided attempt ("full jitter").
+func getBackoff(attempt int) time.Duration {
+	backoff := baseBackoff << uint(attempt)
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff))) + 1
+}
+
+type errorRespJSON struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func newAPIError(resp *http.Response) (*APIError, error) {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	errorResp := errorRespJSON{}
+	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+		apiErr.Type = errorResp.Error.Type
+		apiErr.Message = errorResp.Error.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+		if len(apiErr.Message) > maxErrorLength {
+			apiErr.Message = apiErr.Message[:maxErrorLength]
+		}
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return apiErr, nil
+}
+
+// parseRetryAfter reads a Retry-After header in either seconds
+// or HTTP date form.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
 }
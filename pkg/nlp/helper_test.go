@@ -0,0 +1,267 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockHTTPResponse struct {
+	statusCode int
+	retryAfter string
+	body       string
+}
+
+func Test_help_sendRequest(t *testing.T) {
+	tests := []struct {
+		description string
+		responses   []mockHTTPResponse
+		timeout     time.Duration
+		requests    int
+		waits       int
+		minimumWait time.Duration
+		payload     map[string]string
+		error       *APIError
+	}{
+		{
+			description: "successful invocation",
+			responses: []mockHTTPResponse{
+				{
+					statusCode: http.StatusOK,
+					body:       `{"text": "mock text"}`,
+				},
+			},
+			requests: 1,
+			waits:    0,
+			payload: map[string]string{
+				"text": "mock text",
+			},
+			error: nil,
+		},
+		{
+			description: "client error not retried",
+			responses: []mockHTTPResponse{
+				{
+					statusCode: http.StatusBadRequest,
+					body:       `{"error": {"message": "mock invalid request", "type": "invalid_request_error"}}`,
+				},
+			},
+			requests: 1,
+			waits:    0,
+			payload:  nil,
+			error: &APIError{
+				StatusCode: http.StatusBadRequest,
+				Type:       "invalid_request_error",
+				Message:    "mock invalid request",
+			},
+		},
+		{
+			description: "rate limit retried after retry-after",
+			responses: []mockHTTPResponse{
+				{
+					statusCode: http.StatusTooManyRequests,
+					retryAfter: "2",
+					body:       `{"error": {"message": "mock rate limit", "type": "rate_limit_exceeded"}}`,
+				},
+				{
+					statusCode: http.StatusOK,
+					body:       `{"text": "mock text"}`,
+				},
+			},
+			requests:    2,
+			waits:       1,
+			minimumWait: 2 * time.Second,
+			payload: map[string]string{
+				"text": "mock text",
+			},
+			error: nil,
+		},
+		{
+			description: "server errors retried until exhausted",
+			responses: []mockHTTPResponse{
+				{
+					statusCode: http.StatusServiceUnavailable,
+					body:       "mock unavailable",
+				},
+			},
+			requests: maxRetries + 1,
+			waits:    maxRetries,
+			payload:  nil,
+			error: &APIError{
+				StatusCode: http.StatusServiceUnavailable,
+				Message:    "mock unavailable",
+			},
+		},
+		{
+			description: "retry-after past context deadline returned",
+			responses: []mockHTTPResponse{
+				{
+					statusCode: http.StatusTooManyRequests,
+					retryAfter: "5",
+					body:       `{"error": {"message": "mock rate limit", "type": "rate_limit_exceeded"}}`,
+				},
+			},
+			timeout:  time.Second,
+			requests: 1,
+			waits:    0,
+			payload:  nil,
+			error: &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "rate_limit_exceeded",
+				Message:    "mock rate limit",
+				RetryAfter: 5 * time.Second,
+			},
+		},
+		{
+			description: "retry-after over maximum backoff returned",
+			responses: []mockHTTPResponse{
+				{
+					statusCode: http.StatusTooManyRequests,
+					retryAfter: "60",
+					body:       `{"error": {"message": "mock quota", "type": "insufficient_quota"}}`,
+				},
+			},
+			requests: 1,
+			waits:    0,
+			payload:  nil,
+			error: &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "insufficient_quota",
+				Message:    "mock quota",
+				RetryAfter: 60 * time.Second,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			requests := 0
+			bodies := []string{}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("error reading request body: %v", err)
+				}
+				bodies = append(bodies, string(body))
+
+				response := test.responses[len(test.responses)-1]
+				if requests < len(test.responses) {
+					response = test.responses[requests]
+				}
+				requests++
+
+				if response.retryAfter != "" {
+					w.Header().Set("Retry-After", response.retryAfter)
+				}
+				w.WriteHeader(response.statusCode)
+				fmt.Fprint(w, response.body)
+			}))
+			defer server.Close()
+
+			waits := []time.Duration{}
+			h := &help{
+				provider: Provider{
+					BaseURL: server.URL,
+					APIKey:  "api_key",
+				}.withDefaults(),
+				httpClient: http.Client{},
+				sleep: func(ctx context.Context, d time.Duration) error {
+					waits = append(waits, d)
+					return nil
+				},
+			}
+
+			ctx := context.Background()
+			if test.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+
+			var payload map[string]string
+			err := h.sendRequest(ctx, http.MethodPost, "/v1/mock", strings.NewReader(`{"mock": "body"}`), &payload, nil)
+
+			var apiErr *APIError
+			if test.error == nil && err != nil {
+				t.Errorf("incorrect error, received: %v, expected: nil", err)
+			} else if test.error != nil && (!errors.As(err, &apiErr) || !reflect.DeepEqual(apiErr, test.error)) {
+				t.Errorf("incorrect error, received: %#v, expected: %#v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(payload, test.payload) {
+				t.Errorf("incorrect payload, received: %v, expected: %v", payload, test.payload)
+			}
+
+			if requests != test.requests {
+				t.Errorf("incorrect requests, received: %d, expected: %d", requests, test.requests)
+			}
+
+			for _, body := range bodies {
+				if body != `{"mock": "body"}` {
+					t.Errorf("incorrect request body, received: %q", body)
+				}
+			}
+
+			if len(waits) != test.waits {
+				t.Errorf("incorrect waits, received: %v, expected: %d", waits, test.waits)
+			}
+
+			for _, wait := range waits {
+				if wait < test.minimumWait || wait > maxBackoff {
+					t.Errorf("incorrect wait, received: %v", wait)
+				}
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	tests := []struct {
+		description string
+		value       string
+		minimum     time.Duration
+		maximum     time.Duration
+	}{
+		{
+			description: "empty value",
+			value:       "",
+			minimum:     0,
+			maximum:     0,
+		},
+		{
+			description: "seconds value",
+			value:       "1.5",
+			minimum:     1500 * time.Millisecond,
+			maximum:     1500 * time.Millisecond,
+		},
+		{
+			description: "date value",
+			value:       time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat),
+			minimum:     8 * time.Second,
+			maximum:     10 * time.Second,
+		},
+		{
+			description: "invalid value",
+			value:       "soon",
+			minimum:     0,
+			maximum:     0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			received := parseRetryAfter(test.value)
+			if received < test.minimum || received > test.maximum {
+				t.Errorf("incorrect retry after, received: %v, expected between: %v and %v", received, test.minimum, test.maximum)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package nlp
+
+import (
+	"context"
+	"strings"
+
+	"github.com/forstmeier/askpaulgraham/pkg/tkn"
+)
+
+// historyBudgetRatio is the fraction of the context window,
+// as its inverse, which the conversation history may take up
+// in answer prompts.
+const historyBudgetRatio = 4
+
+// retrievalTurns is the number of most recent questions added
+// to a follow-up question to retrieve its paragraphs.
+const retrievalTurns = 2
+
+// Turn represents an earlier question and answer of a
+// conversation.
+type Turn struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+type historyKey struct{}
+
+// WithHistory returns a copy of the provided context which
+// answers questions as follow-ups to the provided turns, oldest
+// first.
+func WithHistory(ctx context.Context, turns []Turn) context.Context {
+	return context.WithValue(ctx, historyKey{}, turns)
+}
+
+// GetHistory returns the turns set on the provided context with
+// WithHistory and nil when there are none.
+func GetHistory(ctx context.Context) []Turn {
+	turns, _ := ctx.Value(historyKey{}).([]Turn)
+	return turns
+}
+
+// getHistory returns the most recent turns which fit in the
+// token budget, oldest first.
+func getHistory(encoding *tkn.Encoding, budget int, turns []Turn) []Turn {
+	start := len(turns)
+	for start > 0 {
+		turn := turns[start-1]
+		tokens := encoding.Count(turn.Question) + encoding.Count(turn.Answer)
+		if tokens > budget {
+			break
+		}
+
+		budget -= tokens
+		start--
+	}
+
+	return turns[start:]
+}
+
+// getRetrievalQuery prefixes the question with the most recent
+// questions of the conversation so that follow-ups such as "why
+// does he think that?" retrieve paragraphs on its topic.
+func getRetrievalQuery(history []Turn, question string) string {
+	start := len(history) - retrievalTurns
+	if start < 0 {
+		start = 0
+	}
+
+	questions := []string{}
+	for _, turn := range history[start:] {
+		questions = append(questions, turn.Question)
+	}
+	questions = append(questions, question)
+
+	return strings.Join(questions, "\n")
+}
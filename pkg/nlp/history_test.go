@@ -0,0 +1,113 @@
+package nlp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWithHistory(t *testing.T) {
+	turns := []Turn{
+		{
+			Question: "question",
+			Answer:   "Answer.",
+		},
+	}
+
+	if history := GetHistory(context.Background()); history != nil {
+		t.Errorf("incorrect history, received: %v, expected: nil", history)
+	}
+
+	history := GetHistory(WithHistory(context.Background(), turns))
+	if !reflect.DeepEqual(history, turns) {
+		t.Errorf("incorrect history, received: %v, expected: %v", history, turns)
+	}
+}
+
+func Test_getHistory(t *testing.T) {
+	encoding, err := getEncoding(chatModel)
+	if err != nil {
+		t.Fatalf("error getting encoding: %v", err)
+	}
+
+	turns := []Turn{
+		{
+			Question: "first question",
+			Answer:   "First answer.",
+		},
+		{
+			Question: "second question",
+			Answer:   "Second answer.",
+		},
+	}
+	turnTokens := encoding.Count(turns[1].Question) + encoding.Count(turns[1].Answer)
+
+	tests := []struct {
+		description string
+		budget      int
+		history     []Turn
+	}{
+		{
+			description: "no turns within budget",
+			budget:      turnTokens - 1,
+			history:     []Turn{},
+		},
+		{
+			description: "older turns over budget dropped",
+			budget:      turnTokens,
+			history:     turns[1:],
+		},
+		{
+			description: "all turns within budget",
+			budget:      defaultContextTokens,
+			history:     turns,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			history := getHistory(encoding, test.budget, turns)
+			if !reflect.DeepEqual(history, test.history) {
+				t.Errorf("incorrect history, received: %v, expected: %v", history, test.history)
+			}
+		})
+	}
+}
+
+func Test_getRetrievalQuery(t *testing.T) {
+	tests := []struct {
+		description string
+		history     []Turn
+		query       string
+	}{
+		{
+			description: "no history",
+			history:     nil,
+			query:       "question",
+		},
+		{
+			description: "most recent questions added",
+			history: []Turn{
+				{
+					Question: "first question",
+				},
+				{
+					Question: "second question",
+				},
+				{
+					Question: "third question",
+				},
+			},
+			query: "second question\nthird question\nquestion",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			query := getRetrievalQuery(test.history, "question")
+			if query != test.query {
+				t.Errorf("incorrect query, received: %q, expected: %q", query, test.query)
+			}
+		})
+	}
+}
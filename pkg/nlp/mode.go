@@ -0,0 +1,72 @@
+package nlp
+
+import "context"
+
+// Answer modes select the answers prompt and with it the length
+// and style of the generated answers.
+const (
+	ConciseMode  = "concise"
+	DetailedMode = "detailed"
+	BulletsMode  = "bullets"
+	QuotesMode   = "quotes"
+)
+
+// DefaultMode is used for questions asked without an answer
+// mode.
+const DefaultMode = ConciseMode
+
+// Modes lists the supported answer modes.
+var Modes = []string{
+	ConciseMode,
+	DetailedMode,
+	BulletsMode,
+	QuotesMode,
+}
+
+// promptLimits bound a prompt so that prompts uploaded to S3
+// cannot run up the completion cost or produce unusable output.
+type promptLimits struct {
+	maxTokens      int
+	maxTemperature float64
+}
+
+// modeLimits bound the answers prompt of each answer mode so that
+// short modes keep short token budgets and the quotes mode stays
+// close to the context.
+var modeLimits = map[string]promptLimits{
+	ConciseMode: {
+		maxTokens:      150,
+		maxTemperature: 0.7,
+	},
+	DetailedMode: {
+		maxTokens:      600,
+		maxTemperature: 0.8,
+	},
+	BulletsMode: {
+		maxTokens:      300,
+		maxTemperature: 0.7,
+	},
+	QuotesMode: {
+		maxTokens:      300,
+		maxTemperature: 0.2,
+	},
+}
+
+type modeKey struct{}
+
+// WithMode returns a copy of the provided context which generates
+// answers in the provided answer mode.
+func WithMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, modeKey{}, mode)
+}
+
+// GetMode returns the answer mode set on the provided context
+// with WithMode and DefaultMode when there is none.
+func GetMode(ctx context.Context) string {
+	mode, _ := ctx.Value(modeKey{}).(string)
+	if mode == "" {
+		return DefaultMode
+	}
+
+	return mode
+}
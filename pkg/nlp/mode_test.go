@@ -0,0 +1,69 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithMode(t *testing.T) {
+	if mode := GetMode(context.Background()); mode != DefaultMode {
+		t.Errorf("incorrect mode, received: %s, expected: %s", mode, DefaultMode)
+	}
+
+	if mode := GetMode(WithMode(context.Background(), DetailedMode)); mode != DetailedMode {
+		t.Errorf("incorrect mode, received: %s, expected: %s", mode, DetailedMode)
+	}
+}
+
+func Test_getAnswersPrompt(t *testing.T) {
+	tests := []struct {
+		description string
+		mode        string
+		maxTokens   int
+		error       error
+	}{
+		{
+			description: "unsupported mode",
+			mode:        "mock_mode",
+			maxTokens:   0,
+			error:       errors.New(`nlp: unsupported answer mode "mock_mode"`),
+		},
+		{
+			description: "default mode",
+			mode:        "",
+			maxTokens:   defaultPrompts.Answers[ConciseMode].MaxTokens,
+			error:       nil,
+		},
+		{
+			description: "detailed mode",
+			mode:        DetailedMode,
+			maxTokens:   defaultPrompts.Answers[DetailedMode].MaxTokens,
+			error:       nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				s3Client: mockPromptsS3Client(),
+			}
+
+			answers, answer, err := c.getAnswersPrompt(WithMode(context.Background(), test.mode), nil)
+			if err != nil {
+				if test.error == nil || err.Error() != test.error.Error() {
+					t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+				}
+				return
+			}
+
+			if answers.MaxTokens != test.maxTokens {
+				t.Errorf("incorrect max tokens, received: %d, expected: %d", answers.MaxTokens, test.maxTokens)
+			}
+
+			if answer.Mode != GetMode(WithMode(context.Background(), test.mode)) || answer.PromptVersion != defaultPrompts.Version {
+				t.Errorf("incorrect answer, received: %+v", answer)
+			}
+		})
+	}
+}
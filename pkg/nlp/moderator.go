@@ -0,0 +1,219 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// QuestionTarget marks the Moderation of a question.
+	QuestionTarget = "question"
+
+	// AnswerTarget marks the Moderation of a generated answer.
+	AnswerTarget = "answer"
+)
+
+const (
+	questionRefusal = "I can't answer that question."
+	answerRefusal   = "I wasn't able to give an appropriate answer to that question."
+)
+
+// Moderator checks text for content which should not be answered
+// or returned to users.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (*Moderation, error)
+}
+
+// Moderation represents the result of checking a question or
+// answer with a Moderator.
+//
+// Categories lists the flagged categories and Scores holds the
+// score of every category checked.
+type Moderation struct {
+	Target     string             `json:"target"`
+	Flagged    bool               `json:"flagged"`
+	Categories []string           `json:"categories"`
+	Scores     map[string]float64 `json:"scores"`
+}
+
+var _ Moderator = &apiModerator{}
+
+type apiModerator struct {
+	helper helper
+	model  string
+}
+
+type moderationReqJSON struct {
+	Model string `json:"model,omitempty"`
+	Input string `json:"input"`
+}
+
+type moderationRespJSON struct {
+	Results []moderationResultJSON `json:"results"`
+}
+
+type moderationResultJSON struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+func (a *apiModerator) Moderate(ctx context.Context, text string) (*Moderation, error) {
+	data, err := json.Marshal(moderationReqJSON{
+		Model: a.model,
+		Input: text,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := moderationRespJSON{}
+	if err := a.helper.sendRequest(
+		ctx,
+		http.MethodPost,
+		"/v1/moderations",
+		bytes.NewReader(data),
+		&responseBody,
+		map[string]string{
+			"Content-Type": "application/json",
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	if len(responseBody.Results) == 0 {
+		return nil, fmt.Errorf("nlp: received no moderation results")
+	}
+
+	result := responseBody.Results[0]
+	categories := []string{}
+	for category, flagged := range result.Categories {
+		if flagged {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	return &Moderation{
+		Flagged:    result.Flagged,
+		Categories: categories,
+		Scores:     result.CategoryScores,
+	}, nil
+}
+
+var _ Moderator = KeywordModerator{}
+
+// KeywordModerator implements the Moderator interface by flagging
+// text containing any of the keywords or phrases listed for a
+// category and requires no API calls.
+//
+// Keywords are matched against whole words without regard to
+// case or punctuation.
+type KeywordModerator struct {
+	Keywords map[string][]string
+}
+
+// Moderate implements the nlp.Moderator.Moderate method.
+func (k KeywordModerator) Moderate(ctx context.Context, text string) (*Moderation, error) {
+	words := " " + strings.Join(getWords(text), " ") + " "
+
+	moderation := &Moderation{
+		Categories: []string{},
+		Scores:     map[string]float64{},
+	}
+	for category, keywords := range k.Keywords {
+		moderation.Scores[category] = 0
+		for _, keyword := range keywords {
+			if strings.Contains(words, " "+strings.Join(getWords(keyword), " ")+" ") {
+				moderation.Flagged = true
+				moderation.Categories = append(moderation.Categories, category)
+				moderation.Scores[category] = 1
+				break
+			}
+		}
+	}
+	sort.Strings(moderation.Categories)
+
+	return moderation, nil
+}
+
+func getWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+}
+
+// moderate checks the text with the moderator and marks the
+// result with the provided target.
+func (c *Client) moderate(ctx context.Context, target, text string) (*Moderation, error) {
+	moderation, err := c.moderator.Moderate(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	moderation.Target = target
+
+	return moderation, nil
+}
+
+// ModerateQuestion implements the nlp.NLPer.ModerateQuestion
+// method and checks the question with the moderator.
+func (c *Client) ModerateQuestion(ctx context.Context, question string) (*Moderation, error) {
+	return c.moderate(ctx, QuestionTarget, question)
+}
+
+type questionModerationKey struct{}
+
+// WithQuestionModeration returns a copy of the provided context
+// carrying the Moderation of the question being answered so that
+// it is used in place of moderating the question again.
+func WithQuestionModeration(ctx context.Context, moderation Moderation) context.Context {
+	return context.WithValue(ctx, questionModerationKey{}, moderation)
+}
+
+// moderateQuestion returns the question Moderation, taken from
+// the context when set with WithQuestionModeration, along with a
+// refusal Answer when the question is flagged.
+func (c *Client) moderateQuestion(ctx context.Context, question string) ([]Moderation, *Answer, error) {
+	moderation, ok := ctx.Value(questionModerationKey{}).(Moderation)
+	if !ok {
+		questionModeration, err := c.ModerateQuestion(ctx, question)
+		if err != nil {
+			return nil, nil, err
+		}
+		moderation = *questionModeration
+	}
+
+	moderations := []Moderation{moderation}
+	if moderation.Flagged {
+		return nil, GetRefusal(moderations), nil
+	}
+
+	return moderations, nil, nil
+}
+
+// GetRefusal returns the Answer sent in place of the question
+// or answer of the last of the provided moderations, which is
+// expected to be flagged.
+func GetRefusal(moderations []Moderation) *Answer {
+	flagged := moderations[len(moderations)-1]
+
+	text := questionRefusal
+	if flagged.Target == AnswerTarget {
+		text = answerRefusal
+	}
+
+	return &Answer{
+		Text: text,
+		Refusal: &Refusal{
+			Target:     flagged.Target,
+			Categories: flagged.Categories,
+		},
+		Moderations: moderations,
+	}
+}
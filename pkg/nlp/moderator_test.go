@@ -0,0 +1,237 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_apiModerator_Moderate(t *testing.T) {
+	moderationErr := errors.New("mock moderation error")
+
+	tests := []struct {
+		description string
+		responses   []response
+		moderation  *Moderation
+		error       error
+	}{
+		{
+			description: "error sending request",
+			responses: []response{
+				{
+					error: moderationErr,
+				},
+			},
+			moderation: nil,
+			error:      moderationErr,
+		},
+		{
+			description: "no moderation results",
+			responses: []response{
+				{
+					body: []byte(`{"results": []}`),
+				},
+			},
+			moderation: nil,
+			error:      errors.New("nlp: received no moderation results"),
+		},
+		{
+			description: "successful invocation",
+			responses: []response{
+				{
+					body: []byte(`{"results": [{"flagged": true, "categories": {"violence": true, "hate": false, "harassment": true}, "category_scores": {"violence": 0.91, "hate": 0.02, "harassment": 0.6}}]}`),
+				},
+			},
+			moderation: &Moderation{
+				Flagged: true,
+				Categories: []string{
+					"harassment",
+					"violence",
+				},
+				Scores: map[string]float64{
+					"violence":   0.91,
+					"hate":       0.02,
+					"harassment": 0.6,
+				},
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			a := &apiModerator{
+				helper: &mockHelper{
+					t:         t,
+					responses: test.responses,
+				},
+				model: moderationModel,
+			}
+
+			moderation, err := a.Moderate(context.Background(), "text")
+			if !reflect.DeepEqual(err, test.error) {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(moderation, test.moderation) {
+				t.Errorf("incorrect moderation, received: %+v, expected: %+v", moderation, test.moderation)
+			}
+		})
+	}
+}
+
+func TestKeywordModerator_Moderate(t *testing.T) {
+	moderator := KeywordModerator{
+		Keywords: map[string][]string{
+			"violence": {
+				"attack",
+				"Burn It Down",
+			},
+			"self-harm": {
+				"hurt myself",
+			},
+		},
+	}
+
+	tests := []struct {
+		description string
+		text        string
+		moderation  *Moderation
+	}{
+		{
+			description: "no keywords",
+			text:        "How do I find a cofounder?",
+			moderation: &Moderation{
+				Categories: []string{},
+				Scores: map[string]float64{
+					"violence":  0,
+					"self-harm": 0,
+				},
+			},
+		},
+		{
+			description: "partial word not matched",
+			text:        "What makes an attacker's mindset?",
+			moderation: &Moderation{
+				Categories: []string{},
+				Scores: map[string]float64{
+					"violence":  0,
+					"self-harm": 0,
+				},
+			},
+		},
+		{
+			description: "keywords and phrases matched",
+			text:        "Should I ATTACK them and burn it, down?",
+			moderation: &Moderation{
+				Flagged: true,
+				Categories: []string{
+					"violence",
+				},
+				Scores: map[string]float64{
+					"violence":  1,
+					"self-harm": 0,
+				},
+			},
+		},
+		{
+			description: "multiple categories matched",
+			text:        "I want to hurt myself and attack",
+			moderation: &Moderation{
+				Flagged: true,
+				Categories: []string{
+					"self-harm",
+					"violence",
+				},
+				Scores: map[string]float64{
+					"violence":  1,
+					"self-harm": 1,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			moderation, err := moderator.Moderate(context.Background(), test.text)
+			if err != nil {
+				t.Fatalf("incorrect error, received: %v", err)
+			}
+
+			if !reflect.DeepEqual(moderation, test.moderation) {
+				t.Errorf("incorrect moderation, received: %+v, expected: %+v", moderation, test.moderation)
+			}
+		})
+	}
+}
+
+func Test_moderateQuestion(t *testing.T) {
+	moderator := KeywordModerator{
+		Keywords: map[string][]string{
+			"violence": {
+				"attack",
+			},
+		},
+	}
+
+	flagged := Moderation{
+		Target:  QuestionTarget,
+		Flagged: true,
+		Categories: []string{
+			"violence",
+		},
+	}
+
+	tests := []struct {
+		description string
+		ctx         context.Context
+		question    string
+		moderations []Moderation
+		refusal     *Answer
+	}{
+		{
+			description: "question moderated",
+			ctx:         context.Background(),
+			question:    "How do I find a cofounder?",
+			moderations: []Moderation{
+				{
+					Target:     QuestionTarget,
+					Categories: []string{},
+					Scores: map[string]float64{
+						"violence": 0,
+					},
+				},
+			},
+			refusal: nil,
+		},
+		{
+			description: "question moderation taken from context",
+			ctx:         WithQuestionModeration(context.Background(), flagged),
+			question:    "How do I find a cofounder?",
+			moderations: nil,
+			refusal:     GetRefusal([]Moderation{flagged}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				moderator: moderator,
+			}
+
+			moderations, refusal, err := c.moderateQuestion(test.ctx, test.question)
+			if err != nil {
+				t.Fatalf("incorrect error, received: %v", err)
+			}
+
+			if !reflect.DeepEqual(moderations, test.moderations) {
+				t.Errorf("incorrect moderations, received: %+v, expected: %+v", moderations, test.moderations)
+			}
+
+			if !reflect.DeepEqual(refusal, test.refusal) {
+				t.Errorf("incorrect refusal, received: %+v, expected: %+v", refusal, test.refusal)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package nlp
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/forstmeier/askpaulgraham/pkg/dct"
 )
@@ -9,7 +12,99 @@ import (
 // NLPer defines the methods for interacting with the
 // OpenAI natural language processing API.
 type NLPer interface {
-	GetSummary(ctx context.Context, text string) (*string, error)
+	GetSummary(ctx context.Context, text string) (*Summary, error)
 	SetDocuments(ctx context.Context, documents []dct.Document) error
-	GetAnswer(ctx context.Context, question, userID string) (*string, error)
+	GetAnswer(ctx context.Context, question, userID string) (*Answer, error)
+	StreamAnswer(ctx context.Context, question, userID string, send func(token string) error) (*Answer, error)
+	SearchDocuments(ctx context.Context, query string) ([]dct.Document, error)
+	GetQuotes(ctx context.Context, question string) ([]Citation, error)
+	GetTopic(ctx context.Context, titles, terms []string) (*Topic, error)
+	GetEmbedding(ctx context.Context, text string) ([]float64, error)
+	ModerateQuestion(ctx context.Context, question string) (*Moderation, error)
+	GetPromptVersion(ctx context.Context) (string, error)
+}
+
+// Answer represents a generated answer and the essays it
+// was based on.
+//
+// Cached is set when the answer was reused from an earlier
+// question instead of being generated. Refusal is set when the
+// question or answer was flagged by moderation and Text holds a
+// refusal message in its place. Grounding holds the result of
+// checking the answer against the essays it was based on.
+//
+// Moderations holds the results of checking the question and
+// answer, PromptVersion the version of the prompts used to
+// generate the answer, and Mode its answer mode, all for storage
+// and not included in API responses.
+type Answer struct {
+	Text          string       `json:"text"`
+	Citations     []Citation   `json:"citations"`
+	Cached        bool         `json:"cached"`
+	Refusal       *Refusal     `json:"refusal,omitempty"`
+	Grounding     *Grounding   `json:"grounding,omitempty"`
+	Moderations   []Moderation `json:"-"`
+	PromptVersion string       `json:"-"`
+	Mode          string       `json:"-"`
+}
+
+// Summary represents a generated essay summary and the version
+// of the prompts used to generate it.
+type Summary struct {
+	Text          string `json:"text"`
+	PromptVersion string `json:"prompt_version"`
+}
+
+// Topic represents a generated label for the theme shared by a
+// group of essays and the version of the prompts used to
+// generate it.
+type Topic struct {
+	Name          string `json:"name"`
+	PromptVersion string `json:"prompt_version"`
+}
+
+// Refusal represents the reason an answer was withheld.
+//
+// Target is QuestionTarget or AnswerTarget and Categories lists
+// the moderation categories which were flagged.
+type Refusal struct {
+	Target     string   `json:"target"`
+	Categories []string `json:"categories"`
+}
+
+// Citation represents an essay paragraph used to generate
+// an answer or an essay sentence quoted verbatim.
+//
+// Title and URL are not known to the NLPer and are left
+// for the caller to populate from the stored summaries.
+type Citation struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Excerpt string `json:"excerpt"`
+}
+
+// APIError represents an error response from the LLM provider.
+//
+// Type is the provider error type (e.g. "insufficient_quota")
+// when the response body includes one and RetryAfter is the
+// wait requested by the provider with the Retry-After header.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("nlp: %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("nlp: %d %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Temporary reports whether the request may succeed if it is
+// retried later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
 }
@@ -0,0 +1,49 @@
+package nlp
+
+import "strings"
+
+// Price represents the price of a model in US dollars per
+// million tokens.
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// prices lists the published OpenAI prices of the models used by
+// the application and must be updated when the prices change.
+var prices = map[string]Price{
+	"gpt-3.5-turbo":          {Prompt: 0.50, Completion: 1.50},
+	"gpt-3.5-turbo-instruct": {Prompt: 1.50, Completion: 2.00},
+	"gpt-4":                  {Prompt: 30.00, Completion: 60.00},
+	"gpt-4-turbo":            {Prompt: 10.00, Completion: 30.00},
+	"gpt-4o":                 {Prompt: 2.50, Completion: 10.00},
+	"gpt-4o-mini":            {Prompt: 0.15, Completion: 0.60},
+	"gpt-4.1":                {Prompt: 2.00, Completion: 8.00},
+	"gpt-4.1-mini":           {Prompt: 0.40, Completion: 1.60},
+	"gpt-4.1-nano":           {Prompt: 0.10, Completion: 0.40},
+	"text-embedding-3-small": {Prompt: 0.02},
+	"text-embedding-3-large": {Prompt: 0.13},
+	"text-embedding-ada-002": {Prompt: 0.10},
+}
+
+// GetPrice returns the price of the provided model and false
+// when it is not known. Dated snapshots such as
+// "gpt-4o-mini-2024-07-18" use the price of their base model.
+func GetPrice(model string) (Price, bool) {
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+
+	base := ""
+	for name := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(base) {
+			base = name
+		}
+	}
+
+	if base == "" {
+		return Price{}, false
+	}
+
+	return prices[base], true
+}
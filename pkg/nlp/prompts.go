@@ -0,0 +1,252 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const promptsFilename = "prompts.json"
+
+// summariesLimits, topicsLimits and verifierLimits bound the
+// summaries, topics and verifier prompts in the same way as
+// modeLimits bound the answers prompts.
+var (
+	summariesLimits = promptLimits{
+		maxTokens:      200,
+		maxTemperature: 1.0,
+	}
+	topicsLimits = promptLimits{
+		maxTokens:      20,
+		maxTemperature: 1.0,
+	}
+	verifierLimits = promptLimits{
+		maxTokens:      200,
+		maxTemperature: 0.5,
+	}
+)
+
+// promptsRefreshInterval is how long loaded prompts are kept
+// before being loaded again to pick up prompts uploaded to S3.
+const promptsRefreshInterval = 10 * time.Minute
+
+//go:embed prompts/default.json
+var promptsFS embed.FS
+
+// defaultPrompts are used when no prompts have been uploaded to
+// the data bucket.
+var defaultPrompts = mustParsePrompts("prompts/default.json")
+
+// prompts represents a versioned set of prompt templates with
+// an answers template for each answer mode.
+//
+// The summaries template is executed with the text to summarize
+// as .Text and the answers templates with .Question, .Context
+// holding the essay paragraphs, .History holding the earlier
+// turns of the conversation, and the examples fields. The
+// topics template is executed with the .Titles of a group of
+// essays and their .Keywords and the verifier template with the
+// .Passages an answer was generated from and its numbered
+// .Claims.
+type prompts struct {
+	Version   string                      `json:"version"`
+	Summaries promptTemplate              `json:"summaries"`
+	Answers   map[string]*answersTemplate `json:"answers"`
+	Topics    promptTemplate              `json:"topics"`
+	Verifier  promptTemplate              `json:"verifier"`
+}
+
+type promptTemplate struct {
+	System      string   `json:"system"`
+	Template    string   `json:"template"`
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature float64  `json:"temperature"`
+	Stop        []string `json:"stop"`
+
+	template *template.Template
+}
+
+type answersTemplate struct {
+	promptTemplate
+	ExamplesContext string          `json:"examples_context"`
+	Examples        []promptExample `json:"examples"`
+}
+
+type promptExample struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+type summaryData struct {
+	Text string
+}
+
+type answerData struct {
+	ExamplesContext string
+	Examples        []promptExample
+	Context         string
+	History         []Turn
+	Question        string
+}
+
+type topicData struct {
+	Titles   []string
+	Keywords string
+}
+
+type verifierData struct {
+	Passages []string
+	Claims   []verifierClaim
+}
+
+type verifierClaim struct {
+	Number int
+	Text   string
+}
+
+func parsePrompts(data []byte) (*prompts, error) {
+	p := &prompts{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+
+	if p.Version == "" {
+		return nil, errors.New("nlp: prompts version is required")
+	}
+
+	names := []string{"summaries", "topics", "verifier"}
+	templates := []*promptTemplate{&p.Summaries, &p.Topics, &p.Verifier}
+	limits := []promptLimits{summariesLimits, topicsLimits, verifierLimits}
+	for _, mode := range Modes {
+		answers, ok := p.Answers[mode]
+		if !ok || answers == nil {
+			return nil, fmt.Errorf("nlp: %s answers prompt is required", mode)
+		}
+		names = append(names, mode+" answers")
+		templates = append(templates, &answers.promptTemplate)
+		limits = append(limits, modeLimits[mode])
+	}
+
+	for i, prompt := range templates {
+		name, limit := names[i], limits[i]
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(prompt.Template)
+		if err != nil {
+			return nil, fmt.Errorf("nlp: parsing %s prompt: %w", name, err)
+		}
+		prompt.template = tmpl
+
+		if prompt.MaxTokens <= 0 || prompt.MaxTokens > limit.maxTokens {
+			return nil, fmt.Errorf("nlp: %s prompt max_tokens must be between 1 and %d", name, limit.maxTokens)
+		}
+
+		if prompt.Temperature < 0 || prompt.Temperature > limit.maxTemperature {
+			return nil, fmt.Errorf("nlp: %s prompt temperature must be between 0 and %g", name, limit.maxTemperature)
+		}
+	}
+
+	return p, nil
+}
+
+func mustParsePrompts(filename string) *prompts {
+	data, err := promptsFS.ReadFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	p, err := parsePrompts(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+func (p *promptTemplate) execute(data interface{}) (string, error) {
+	output := strings.Builder{}
+	if err := p.template.Execute(&output, data); err != nil {
+		return "", err
+	}
+
+	return output.String(), nil
+}
+
+// format formats the completion of the prompt and restores the
+// period removed by prompts which stop at the end of a sentence.
+func (p *promptTemplate) format(text string) string {
+	for _, stop := range p.Stop {
+		if stop == "." {
+			return formatString(text)
+		}
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// getPrompts returns the prompts uploaded to the data bucket as
+// "prompts.json" or defaultPrompts when there are none. Prompts
+// are reloaded after promptsRefreshInterval and the last loaded
+// prompts are kept if reloading them fails.
+func (c *Client) getPrompts(ctx context.Context) (*prompts, error) {
+	c.promptsMutex.Lock()
+	defer c.promptsMutex.Unlock()
+
+	if c.prompts != nil && time.Since(c.promptsLoaded) < promptsRefreshInterval {
+		return c.prompts, nil
+	}
+
+	p, err := c.loadPrompts(ctx)
+	if err != nil {
+		if c.prompts != nil {
+			return c.prompts, nil
+		}
+		return nil, err
+	}
+
+	c.prompts = p
+	c.promptsLoaded = time.Now()
+
+	return p, nil
+}
+
+// GetPromptVersion implements the nlp.NLPer.GetPromptVersion
+// method and returns the version of the current prompts.
+func (c *Client) GetPromptVersion(ctx context.Context) (string, error) {
+	p, err := c.getPrompts(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return p.Version, nil
+}
+
+func (c *Client) loadPrompts(ctx context.Context) (*prompts, error) {
+	response, err := c.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &c.bucketName,
+		Key:    aws.String(promptsFilename),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return defaultPrompts, nil
+		}
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data := bytes.Buffer{}
+	if _, err := data.ReadFrom(response.Body); err != nil {
+		return nil, err
+	}
+
+	return parsePrompts(data.Bytes())
+}
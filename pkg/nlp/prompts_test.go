@@ -0,0 +1,274 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const mockTopicsPrompt = `{"template": "{{range .Titles}}{{.}} {{end}}{{.Keywords}}", "max_tokens": 10}`
+
+const mockVerifierPrompt = `{"template": "{{range .Claims}}{{.Number}}. {{.Text}}\\n{{end}}", "max_tokens": 100}`
+
+// getMockPrompts returns prompts JSON with the same answers
+// prompt for every answer mode.
+func getMockPrompts(version, summaries, answers string) string {
+	modes := make([]string, len(Modes))
+	for i, mode := range Modes {
+		modes[i] = fmt.Sprintf("%q: %s", mode, answers)
+	}
+
+	return fmt.Sprintf(
+		`{"version": %q, "summaries": %s, "answers": {%s}, "topics": %s, "verifier": %s}`,
+		version,
+		summaries,
+		strings.Join(modes, ", "),
+		mockTopicsPrompt,
+		mockVerifierPrompt,
+	)
+}
+
+var mockPrompts = getMockPrompts(
+	"v2",
+	`{"template": "{{.Text}} tl;dr:", "max_tokens": 40}`,
+	`{"template": "{{.Context}} Q: {{.Question}} A:", "max_tokens": 80}`,
+)
+
+func Test_parsePrompts(t *testing.T) {
+	tests := []struct {
+		description string
+		data        string
+		version     string
+		error       string
+	}{
+		{
+			description: "invalid json",
+			data:        "{",
+			version:     "",
+			error:       "unexpected end of JSON input",
+		},
+		{
+			description: "missing version",
+			data: getMockPrompts(
+				"",
+				`{"template": "{{.Text}}", "max_tokens": 40}`,
+				`{"template": "{{.Question}}", "max_tokens": 80}`,
+			),
+			version: "",
+			error:   "nlp: prompts version is required",
+		},
+		{
+			description: "missing answer mode",
+			data:        `{"version": "v2", "summaries": {"template": "{{.Text}}", "max_tokens": 40}, "answers": {"concise": {"template": "{{.Question}}", "max_tokens": 80}}}`,
+			version:     "",
+			error:       "nlp: detailed answers prompt is required",
+		},
+		{
+			description: "invalid template",
+			data: getMockPrompts(
+				"v2",
+				`{"template": "{{.Text", "max_tokens": 40}`,
+				`{"template": "{{.Question}}", "max_tokens": 80}`,
+			),
+			version: "",
+			error:   "nlp: parsing summaries prompt",
+		},
+		{
+			description: "missing max tokens",
+			data: getMockPrompts(
+				"v2",
+				`{"template": "{{.Text}}", "max_tokens": 40}`,
+				`{"template": "{{.Question}}"}`,
+			),
+			version: "",
+			error:   "nlp: concise answers prompt max_tokens must be between 1 and 150",
+		},
+		{
+			description: "max tokens over bound",
+			data: getMockPrompts(
+				"v2",
+				`{"template": "{{.Text}}", "max_tokens": 4000}`,
+				`{"template": "{{.Question}}", "max_tokens": 80}`,
+			),
+			version: "",
+			error:   "nlp: summaries prompt max_tokens must be between 1 and 200",
+		},
+		{
+			description: "max tokens over mode bound",
+			data: getMockPrompts(
+				"v2",
+				`{"template": "{{.Text}}", "max_tokens": 40}`,
+				`{"template": "{{.Question}}", "max_tokens": 400}`,
+			),
+			version: "",
+			error:   "nlp: concise answers prompt max_tokens must be between 1 and 150",
+		},
+		{
+			description: "temperature over mode bound",
+			data: getMockPrompts(
+				"v2",
+				`{"template": "{{.Text}}", "max_tokens": 40}`,
+				`{"template": "{{.Question}}", "max_tokens": 80, "temperature": 0.5}`,
+			),
+			version: "",
+			error:   "nlp: quotes answers prompt temperature must be between 0 and 0.2",
+		},
+		{
+			description: "temperature over bound",
+			data: getMockPrompts(
+				"v2",
+				`{"template": "{{.Text}}", "max_tokens": 40, "temperature": 1.5}`,
+				`{"template": "{{.Question}}", "max_tokens": 80}`,
+			),
+			version: "",
+			error:   "nlp: summaries prompt temperature must be between 0 and 1",
+		},
+		{
+			description: "missing topics prompt",
+			data:        `{"version": "v2", "summaries": {"template": "{{.Text}}", "max_tokens": 40}, "answers": {"concise": {"template": "{{.Question}}", "max_tokens": 80}, "detailed": {"template": "{{.Question}}", "max_tokens": 80}, "bullets": {"template": "{{.Question}}", "max_tokens": 80}, "quotes": {"template": "{{.Question}}", "max_tokens": 80}}}`,
+			version:     "",
+			error:       "nlp: topics prompt max_tokens must be between 1 and 20",
+		},
+		{
+			description: "missing verifier prompt",
+			data:        `{"version": "v2", "summaries": {"template": "{{.Text}}", "max_tokens": 40}, "topics": {"template": "{{.Keywords}}", "max_tokens": 10}, "answers": {"concise": {"template": "{{.Question}}", "max_tokens": 80}, "detailed": {"template": "{{.Question}}", "max_tokens": 80}, "bullets": {"template": "{{.Question}}", "max_tokens": 80}, "quotes": {"template": "{{.Question}}", "max_tokens": 80}}}`,
+			version:     "",
+			error:       "nlp: verifier prompt max_tokens must be between 1 and 200",
+		},
+		{
+			description: "successful invocation",
+			data:        mockPrompts,
+			version:     "v2",
+			error:       "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			p, err := parsePrompts([]byte(test.data))
+			if err != nil {
+				if test.error == "" || !strings.HasPrefix(err.Error(), test.error) {
+					t.Errorf("incorrect error, received: %v, expected: %s", err, test.error)
+				}
+				return
+			}
+			if test.error != "" {
+				t.Fatalf("incorrect error, received: nil, expected: %s", test.error)
+			}
+
+			if p.Version != test.version {
+				t.Errorf("incorrect version, received: %s, expected: %s", p.Version, test.version)
+			}
+
+			summary, err := p.Summaries.execute(summaryData{
+				Text: "text",
+			})
+			if err != nil {
+				t.Fatalf("error executing summaries prompt: %v", err)
+			}
+			if summary != "text tl;dr:" {
+				t.Errorf("incorrect summaries prompt, received: %q, expected: %q", summary, "text tl;dr:")
+			}
+		})
+	}
+}
+
+func Test_promptTemplate_format(t *testing.T) {
+	tests := []struct {
+		description string
+		stop        []string
+		text        string
+		formatted   string
+	}{
+		{
+			description: "sentence stop restored",
+			stop: []string{
+				".",
+			},
+			text:      " users matter",
+			formatted: "Users matter.",
+		},
+		{
+			description: "text without sentence stop trimmed",
+			stop: []string{
+				"\nQ:",
+			},
+			text:      "\n- Users matter.\n- Growth matters.\n",
+			formatted: "- Users matter.\n- Growth matters.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			p := &promptTemplate{
+				Stop: test.stop,
+			}
+
+			if formatted := p.format(test.text); formatted != test.formatted {
+				t.Errorf("incorrect text, received: %q, expected: %q", formatted, test.formatted)
+			}
+		})
+	}
+}
+
+func Test_getPrompts(t *testing.T) {
+	getObjectErr := errors.New("mock get object error")
+
+	tests := []struct {
+		description         string
+		mockGetObjectOutput *s3.GetObjectOutput
+		mockGetObjectError  error
+		version             string
+		error               error
+	}{
+		{
+			description:         "error getting prompts object",
+			mockGetObjectOutput: nil,
+			mockGetObjectError:  getObjectErr,
+			version:             "",
+			error:               getObjectErr,
+		},
+		{
+			description:         "no prompts object",
+			mockGetObjectOutput: nil,
+			mockGetObjectError:  awserr.New(s3.ErrCodeNoSuchKey, "mock no such key", nil),
+			version:             defaultPrompts.Version,
+			error:               nil,
+		},
+		{
+			description: "successful invocation",
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(mockPrompts)),
+			},
+			mockGetObjectError: nil,
+			version:            "v2",
+			error:              nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				s3Client: &mockS3Client{
+					mockGetObjectOutput: test.mockGetObjectOutput,
+					mockGetObjectError:  test.mockGetObjectError,
+				},
+			}
+
+			p, err := c.getPrompts(context.Background())
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if p != nil && p.Version != test.version {
+				t.Errorf("incorrect version, received: %s, expected: %s", p.Version, test.version)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package nlp
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// CompletionsAPI selects the legacy completions API which
+	// accepts a single prompt string.
+	CompletionsAPI = "completions"
+
+	// ChatAPI selects the chat completions API which accepts
+	// system and user messages.
+	ChatAPI = "chat"
+)
+
+const (
+	openAIBaseURL        = "https://api.openai.com"
+	defaultAuthHeader    = "Authorization"
+	defaultAuthScheme    = "Bearer"
+	completionsModel     = "gpt-3.5-turbo-instruct"
+	chatModel            = "gpt-3.5-turbo"
+	embeddingsModel      = "text-embedding-3-small"
+	embeddingsDimensions = 256
+	moderationModel      = "omni-moderation-latest"
+	defaultContextTokens = 4096 // smallest context window of the default models
+)
+
+// Provider configures the OpenAI-compatible API used by the
+// Client.
+//
+// Empty fields are populated with the OpenAI defaults and any
+// API other than CompletionsAPI is treated as ChatAPI.
+// ContextTokens is the context window of the summaries and
+// answers models which prompts are budgeted against. AuthScheme
+// defaults to "Bearer" only when it and AuthHeader are both
+// empty so it may be left empty with an AuthHeader to send the
+// bare API key (e.g. "api-key: <key>").
+// Questions and answers are checked with the moderations API
+// using ModerationModel unless a Moderator is provided, such as
+// a KeywordModerator for providers without one. Answers are
+// checked against the passages they were generated from with a
+// LexicalVerifier unless a Verifier is provided or VerifierModel
+// is set to check them with that model, and are flagged when
+// their grounding score is below GroundingThreshold, which
+// defaults to DefaultGroundingThreshold and never flags answers
+// when negative.
+type Provider struct {
+	BaseURL              string
+	APIKey               string
+	AuthHeader           string
+	AuthScheme           string
+	API                  string
+	SummariesModel       string
+	AnswersModel         string
+	EmbeddingsModel      string
+	EmbeddingsDimensions int
+	ContextTokens        int
+	ModerationModel      string
+	Moderator            Moderator
+	VerifierModel        string
+	Verifier             Verifier
+	GroundingThreshold   float64
+}
+
+// OpenAIProvider returns a Provider for the OpenAI API using
+// the chat completions API and default models.
+func OpenAIProvider(apiKey string) Provider {
+	return Provider{
+		APIKey: apiKey,
+	}.withDefaults()
+}
+
+func (p Provider) withDefaults() Provider {
+	if p.BaseURL == "" {
+		p.BaseURL = openAIBaseURL
+	}
+
+	if p.AuthHeader == "" {
+		p.AuthHeader = defaultAuthHeader
+		if p.AuthScheme == "" {
+			p.AuthScheme = defaultAuthScheme
+		}
+	}
+
+	if p.API != CompletionsAPI {
+		p.API = ChatAPI
+	}
+
+	defaultModel := chatModel
+	if p.API == CompletionsAPI {
+		defaultModel = completionsModel
+	}
+
+	if p.SummariesModel == "" {
+		p.SummariesModel = defaultModel
+	}
+
+	if p.AnswersModel == "" {
+		p.AnswersModel = defaultModel
+	}
+
+	if p.EmbeddingsModel == "" {
+		p.EmbeddingsModel = embeddingsModel
+		if p.EmbeddingsDimensions == 0 {
+			p.EmbeddingsDimensions = embeddingsDimensions
+		}
+	}
+
+	if p.ContextTokens == 0 {
+		p.ContextTokens = defaultContextTokens
+	}
+
+	if p.ModerationModel == "" {
+		p.ModerationModel = moderationModel
+	}
+
+	if p.GroundingThreshold == 0 {
+		p.GroundingThreshold = DefaultGroundingThreshold
+	}
+
+	return p
+}
+
+func (p Provider) authorization() string {
+	if p.AuthScheme == "" {
+		return p.APIKey
+	}
+	return fmt.Sprintf("%s %s", p.AuthScheme, p.APIKey)
+}
+
+func (p Provider) getVerifier(h helper, prompts func(ctx context.Context) (*prompts, error)) Verifier {
+	if p.Verifier != nil {
+		return p.Verifier
+	}
+
+	if p.VerifierModel != "" {
+		return &llmVerifier{
+			completer: p.getCompleter(h),
+			model:     p.VerifierModel,
+			prompts:   prompts,
+		}
+	}
+
+	return LexicalVerifier{}
+}
+
+func (p Provider) getCompleter(h helper) completer {
+	if p.API == CompletionsAPI {
+		return &textCompleter{
+			helper: h,
+		}
+	}
+
+	return &chatCompleter{
+		helper: h,
+	}
+}
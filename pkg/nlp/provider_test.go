@@ -0,0 +1,151 @@
+package nlp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestOpenAIProvider(t *testing.T) {
+	received := OpenAIProvider("api_key")
+	expected := Provider{
+		BaseURL:              openAIBaseURL,
+		APIKey:               "api_key",
+		AuthHeader:           "Authorization",
+		AuthScheme:           "Bearer",
+		API:                  ChatAPI,
+		SummariesModel:       chatModel,
+		AnswersModel:         chatModel,
+		EmbeddingsModel:      embeddingsModel,
+		EmbeddingsDimensions: embeddingsDimensions,
+		ContextTokens:        defaultContextTokens,
+		ModerationModel:      moderationModel,
+		GroundingThreshold:   DefaultGroundingThreshold,
+	}
+
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("incorrect provider, received: %+v, expected: %+v", received, expected)
+	}
+}
+
+func TestProvider_withDefaults(t *testing.T) {
+	tests := []struct {
+		description string
+		provider    Provider
+		expected    Provider
+	}{
+		{
+			description: "completions api defaults",
+			provider: Provider{
+				API: CompletionsAPI,
+			},
+			expected: Provider{
+				BaseURL:              openAIBaseURL,
+				AuthHeader:           "Authorization",
+				AuthScheme:           "Bearer",
+				API:                  CompletionsAPI,
+				SummariesModel:       completionsModel,
+				AnswersModel:         completionsModel,
+				EmbeddingsModel:      embeddingsModel,
+				EmbeddingsDimensions: embeddingsDimensions,
+				ContextTokens:        defaultContextTokens,
+				ModerationModel:      moderationModel,
+				GroundingThreshold:   DefaultGroundingThreshold,
+			},
+		},
+		{
+			description: "custom auth scheme kept with default header",
+			provider: Provider{
+				AuthScheme: "Token",
+			},
+			expected: Provider{
+				BaseURL:              openAIBaseURL,
+				AuthHeader:           "Authorization",
+				AuthScheme:           "Token",
+				API:                  ChatAPI,
+				SummariesModel:       chatModel,
+				AnswersModel:         chatModel,
+				EmbeddingsModel:      embeddingsModel,
+				EmbeddingsDimensions: embeddingsDimensions,
+				ContextTokens:        defaultContextTokens,
+				ModerationModel:      moderationModel,
+				GroundingThreshold:   DefaultGroundingThreshold,
+			},
+		},
+		{
+			description: "custom server values kept",
+			provider: Provider{
+				BaseURL:         "http://localhost:8080",
+				AuthHeader:      "api-key",
+				API:             "unknown",
+				SummariesModel:  "local-model",
+				AnswersModel:    "local-model",
+				EmbeddingsModel: "local-embeddings",
+				ContextTokens:   8192,
+			},
+			expected: Provider{
+				BaseURL:            "http://localhost:8080",
+				AuthHeader:         "api-key",
+				AuthScheme:         "",
+				API:                ChatAPI,
+				SummariesModel:     "local-model",
+				AnswersModel:       "local-model",
+				EmbeddingsModel:    "local-embeddings",
+				ContextTokens:      8192,
+				ModerationModel:    moderationModel,
+				GroundingThreshold: DefaultGroundingThreshold,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			received := test.provider.withDefaults()
+			if !reflect.DeepEqual(received, test.expected) {
+				t.Errorf("incorrect provider, received: %+v, expected: %+v", received, test.expected)
+			}
+		})
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/completions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "api_key" {
+			t.Errorf("incorrect api-key header, received: %q", r.Header.Get("api-key"))
+		}
+
+		fmt.Fprint(w, `{"choices": [{"text": " mock summary"}]}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New(session.New(), Provider{
+		BaseURL:    server.URL,
+		APIKey:     "api_key",
+		AuthHeader: "api-key",
+		API:        CompletionsAPI,
+	}, "bucket_name", Retrieval{})
+
+	if _, ok := client.completer.(*textCompleter); !ok {
+		t.Fatalf("incorrect completer, received: %T", client.completer)
+	}
+
+	summary, err := client.completer.complete(context.Background(), completionRequest{
+		model:  client.summariesModel,
+		prompt: "mock text",
+	})
+	if err != nil {
+		t.Fatalf("incorrect error, received: %v", err)
+	}
+
+	if summary.text != " mock summary" {
+		t.Errorf("incorrect summary, received: %q", summary.text)
+	}
+}
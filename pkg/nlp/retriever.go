@@ -0,0 +1,396 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/forstmeier/askpaulgraham/pkg/idx"
+)
+
+const (
+	embeddingsFilename = "embeddings.jsonl"
+	indexFilename      = "index.json"
+	sentencesFilename  = "sentences.json"
+)
+
+const (
+	// EmbeddingsRetrieval selects paragraphs for answers by
+	// embedding similarity to the question.
+	EmbeddingsRetrieval = "embeddings"
+
+	// KeywordRetrieval selects paragraphs for answers with the
+	// BM25 keyword index and requires no API calls.
+	KeywordRetrieval = "keyword"
+
+	// HybridRetrieval selects paragraphs for answers by merging
+	// the embeddings and keyword results with weighted reciprocal
+	// rank fusion.
+	HybridRetrieval = "hybrid"
+)
+
+const (
+	fusionRankConstant   = 60 // standard reciprocal rank fusion constant
+	fusionCandidateRatio = 4
+)
+
+// documentsRefreshInterval is how long the loaded embeddings and
+// keyword index are kept before being loaded again to pick up
+// documents uploaded to S3 by the documents CLI.
+const documentsRefreshInterval = 10 * time.Minute
+
+// Retrieval configures how paragraphs are selected when
+// answering questions.
+//
+// The weights are only applied with HybridRetrieval, default
+// to 1.0 when left at zero, and a negative weight excludes the
+// matching retriever from the fusion.
+type Retrieval struct {
+	Method           string
+	EmbeddingsWeight float64
+	KeywordWeight    float64
+}
+
+type retriever interface {
+	retrieve(ctx context.Context, question string, count int) ([]passage, error)
+	reset()
+}
+
+type passage struct {
+	text     string
+	metadata string
+	score    float64
+}
+
+var _ retriever = &embeddingsRetriever{}
+
+type embeddingsRetriever struct {
+	helper     helper
+	model      string
+	dimensions int
+	bucketName string
+	s3Client   s3Client
+
+	mutex      sync.Mutex
+	embeddings []embeddingJSON
+	loaded     time.Time
+}
+
+type getEmbeddingsReqJSON struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type getEmbeddingsRespJSON struct {
+	Data  []getEmbeddingsRespDataJSON `json:"data"`
+	Usage *usageJSON                  `json:"usage"`
+}
+
+type getEmbeddingsRespDataJSON struct {
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type embeddingJSON struct {
+	Text      string    `json:"text"`
+	Metadata  string    `json:"metadata"`
+	Embedding []float64 `json:"embedding"`
+}
+
+func (e *embeddingsRetriever) getEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	data, err := json.Marshal(getEmbeddingsReqJSON{
+		Model:      e.model,
+		Input:      texts,
+		Dimensions: e.dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := getEmbeddingsRespJSON{}
+	if err := e.helper.sendRequest(
+		ctx,
+		http.MethodPost,
+		"/v1/embeddings",
+		bytes.NewReader(data),
+		&responseBody,
+		map[string]string{
+			"Content-Type": "application/json",
+		},
+	); err != nil {
+		return nil, err
+	}
+	recordUsage(ctx, e.model, responseBody.Usage)
+
+	if len(responseBody.Data) != len(texts) {
+		return nil, fmt.Errorf("nlp: received %d embeddings for %d inputs", len(responseBody.Data), len(texts))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, item := range responseBody.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("nlp: received embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+
+	return vectors, nil
+}
+
+func (e *embeddingsRetriever) retrieve(ctx context.Context, question string, count int) ([]passage, error) {
+	vectors, err := e.getEmbeddings(ctx, []string{question})
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings, err := e.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	passages := make([]passage, len(embeddings))
+	for i, embedding := range embeddings {
+		passages[i] = passage{
+			text:     embedding.Text,
+			metadata: embedding.Metadata,
+			score:    CosineSimilarity(vectors[0], embedding.Embedding),
+		}
+	}
+
+	sort.SliceStable(passages, func(i, j int) bool {
+		return passages[i].score > passages[j].score
+	})
+
+	if len(passages) > count {
+		passages = passages[:count]
+	}
+
+	return passages, nil
+}
+
+// load returns the embeddings uploaded to the data bucket. They
+// are reloaded after documentsRefreshInterval and the last loaded
+// embeddings are kept if reloading them fails.
+func (e *embeddingsRetriever) load(ctx context.Context) ([]embeddingJSON, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.embeddings != nil && time.Since(e.loaded) < documentsRefreshInterval {
+		return e.embeddings, nil
+	}
+
+	embeddings, err := e.loadEmbeddings(ctx)
+	if err != nil {
+		if e.embeddings != nil {
+			return e.embeddings, nil
+		}
+		return nil, err
+	}
+
+	e.embeddings = embeddings
+	e.loaded = time.Now()
+
+	return embeddings, nil
+}
+
+func (e *embeddingsRetriever) loadEmbeddings(ctx context.Context) ([]embeddingJSON, error) {
+	response, err := e.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &e.bucketName,
+		Key:    aws.String(embeddingsFilename),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	embeddings := []embeddingJSON{}
+	decoder := json.NewDecoder(response.Body)
+	for decoder.More() {
+		var embedding embeddingJSON
+		if err := decoder.Decode(&embedding); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, nil
+}
+
+func (e *embeddingsRetriever) reset() {
+	e.mutex.Lock()
+	e.embeddings = nil
+	e.mutex.Unlock()
+}
+
+// CosineSimilarity returns the cosine similarity of the two
+// embeddings or 0 when their lengths differ or either is zero.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+var _ retriever = &keywordRetriever{}
+
+type keywordRetriever struct {
+	filename   string
+	bucketName string
+	s3Client   s3Client
+
+	mutex  sync.Mutex
+	index  *idx.Index
+	loaded time.Time
+}
+
+func (k *keywordRetriever) retrieve(ctx context.Context, question string, count int) ([]passage, error) {
+	index, err := k.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := index.Search(question, count)
+	passages := make([]passage, len(results))
+	for i, result := range results {
+		passages[i] = passage{
+			text:     result.Document.Text,
+			metadata: result.Document.Metadata,
+			score:    result.Score,
+		}
+	}
+
+	return passages, nil
+}
+
+// load returns the keyword index uploaded to the data bucket and
+// is refreshed in the same way as embeddingsRetriever.load.
+func (k *keywordRetriever) load(ctx context.Context) (*idx.Index, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.index != nil && time.Since(k.loaded) < documentsRefreshInterval {
+		return k.index, nil
+	}
+
+	index, err := k.loadIndex(ctx)
+	if err != nil {
+		if k.index != nil {
+			return k.index, nil
+		}
+		return nil, err
+	}
+
+	k.index = index
+	k.loaded = time.Now()
+
+	return index, nil
+}
+
+func (k *keywordRetriever) loadIndex(ctx context.Context) (*idx.Index, error) {
+	response, err := k.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &k.bucketName,
+		Key:    &k.filename,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	index := &idx.Index{}
+	if err := json.NewDecoder(response.Body).Decode(index); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func (k *keywordRetriever) reset() {
+	k.mutex.Lock()
+	k.index = nil
+	k.mutex.Unlock()
+}
+
+var _ retriever = &fusionRetriever{}
+
+type weightedRetriever struct {
+	retriever retriever
+	weight    float64
+}
+
+type fusionRetriever struct {
+	retrievers []weightedRetriever
+}
+
+func (f *fusionRetriever) retrieve(ctx context.Context, question string, count int) ([]passage, error) {
+	scores := map[string]float64{}
+	passages := map[string]passage{}
+	for _, weighted := range f.retrievers {
+		if weighted.weight <= 0 {
+			continue
+		}
+
+		candidates, err := weighted.retriever.retrieve(ctx, question, count*fusionCandidateRatio)
+		if err != nil {
+			return nil, err
+		}
+
+		for rank, candidate := range candidates {
+			key := candidate.metadata + "\n" + candidate.text
+			scores[key] += weighted.weight / float64(fusionRankConstant+rank+1)
+			if _, ok := passages[key]; !ok {
+				passages[key] = candidate
+			}
+		}
+	}
+
+	fused := make([]passage, 0, len(passages))
+	for key, candidate := range passages {
+		candidate.score = scores[key]
+		fused = append(fused, candidate)
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		if fused[i].score == fused[j].score {
+			return fused[i].metadata+fused[i].text < fused[j].metadata+fused[j].text
+		}
+		return fused[i].score > fused[j].score
+	})
+
+	if len(fused) > count {
+		fused = fused[:count]
+	}
+
+	return fused, nil
+}
+
+func (f *fusionRetriever) reset() {
+	for _, weighted := range f.retrievers {
+		weighted.retriever.reset()
+	}
+}
@@ -0,0 +1,252 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		description string
+		a           []float64
+		b           []float64
+		similarity  float64
+	}{
+		{
+			description: "mismatched lengths",
+			a:           []float64{1, 0},
+			b:           []float64{1},
+			similarity:  0,
+		},
+		{
+			description: "zero vector",
+			a:           []float64{0, 0},
+			b:           []float64{1, 0},
+			similarity:  0,
+		},
+		{
+			description: "identical vectors",
+			a:           []float64{0.5, 0.5},
+			b:           []float64{0.5, 0.5},
+			similarity:  1,
+		},
+		{
+			description: "orthogonal vectors",
+			a:           []float64{1, 0},
+			b:           []float64{0, 1},
+			similarity:  0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			similarity := CosineSimilarity(test.a, test.b)
+			if math.Abs(similarity-test.similarity) > 1e-9 {
+				t.Errorf("incorrect similarity, received: %f, expected: %f", similarity, test.similarity)
+			}
+		})
+	}
+}
+
+func Test_embeddingsRetriever_load(t *testing.T) {
+	getObjectErr := errors.New("mock get object error")
+
+	loaded := []embeddingJSON{
+		{
+			Text: "loaded",
+		},
+	}
+
+	tests := []struct {
+		description         string
+		embeddings          []embeddingJSON
+		loaded              time.Time
+		mockGetObjectOutput *s3.GetObjectOutput
+		mockGetObjectError  error
+		text                string
+		error               error
+	}{
+		{
+			description:        "error getting embeddings object",
+			mockGetObjectError: getObjectErr,
+			text:               "",
+			error:              getObjectErr,
+		},
+		{
+			description: "loaded embeddings kept",
+			embeddings:  loaded,
+			loaded:      time.Now(),
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(`{"text": "uploaded"}`)),
+			},
+			text:  "loaded",
+			error: nil,
+		},
+		{
+			description: "stale embeddings reloaded",
+			embeddings:  loaded,
+			loaded:      time.Now().Add(-documentsRefreshInterval),
+			mockGetObjectOutput: &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(`{"text": "uploaded"}`)),
+			},
+			text:  "uploaded",
+			error: nil,
+		},
+		{
+			description:        "stale embeddings kept on error",
+			embeddings:         loaded,
+			loaded:             time.Now().Add(-documentsRefreshInterval),
+			mockGetObjectError: getObjectErr,
+			text:               "loaded",
+			error:              nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			e := &embeddingsRetriever{
+				s3Client: &mockS3Client{
+					mockGetObjectOutput: test.mockGetObjectOutput,
+					mockGetObjectError:  test.mockGetObjectError,
+				},
+				embeddings: test.embeddings,
+				loaded:     test.loaded,
+			}
+
+			embeddings, err := e.load(context.Background())
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			text := ""
+			if len(embeddings) > 0 {
+				text = embeddings[0].Text
+			}
+
+			if text != test.text {
+				t.Errorf("incorrect text, received: %s, expected: %s", text, test.text)
+			}
+		})
+	}
+}
+
+type mockRetriever struct {
+	passages []passage
+	error    error
+}
+
+func (m *mockRetriever) retrieve(ctx context.Context, question string, count int) ([]passage, error) {
+	if len(m.passages) > count {
+		return m.passages[:count], m.error
+	}
+	return m.passages, m.error
+}
+
+func (m *mockRetriever) reset() {}
+
+func Test_fusionRetriever_retrieve(t *testing.T) {
+	retrieveErr := errors.New("mock retrieve error")
+
+	first := passage{text: "first", metadata: "first_id"}
+	second := passage{text: "second", metadata: "second_id"}
+	third := passage{text: "third", metadata: "third_id"}
+
+	tests := []struct {
+		description string
+		retrievers  []weightedRetriever
+		count       int
+		texts       []string
+		error       error
+	}{
+		{
+			description: "error retrieving passages",
+			retrievers: []weightedRetriever{
+				{
+					retriever: &mockRetriever{error: retrieveErr},
+					weight:    1.0,
+				},
+			},
+			count: 2,
+			texts: nil,
+			error: retrieveErr,
+		},
+		{
+			description: "shared passage ranked first",
+			retrievers: []weightedRetriever{
+				{
+					retriever: &mockRetriever{passages: []passage{first, second}},
+					weight:    1.0,
+				},
+				{
+					retriever: &mockRetriever{passages: []passage{third, second}},
+					weight:    1.0,
+				},
+			},
+			count: 2,
+			texts: []string{"second", "first"},
+			error: nil,
+		},
+		{
+			description: "weights favor keyword passage",
+			retrievers: []weightedRetriever{
+				{
+					retriever: &mockRetriever{passages: []passage{first}},
+					weight:    1.0,
+				},
+				{
+					retriever: &mockRetriever{passages: []passage{third}},
+					weight:    2.0,
+				},
+			},
+			count: 2,
+			texts: []string{"third", "first"},
+			error: nil,
+		},
+		{
+			description: "negative weight excludes retriever",
+			retrievers: []weightedRetriever{
+				{
+					retriever: &mockRetriever{passages: []passage{first}},
+					weight:    -1.0,
+				},
+				{
+					retriever: &mockRetriever{passages: []passage{third}},
+					weight:    1.0,
+				},
+			},
+			count: 2,
+			texts: []string{"third"},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			f := &fusionRetriever{
+				retrievers: test.retrievers,
+			}
+
+			passages, err := f.retrieve(context.Background(), "question", test.count)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			var texts []string
+			for _, passage := range passages {
+				texts = append(texts, passage.text)
+			}
+
+			if !reflect.DeepEqual(texts, test.texts) {
+				t.Errorf("incorrect passages, received: %v, expected: %v", texts, test.texts)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package nlp
+
+import (
+	"context"
+	"strings"
+)
+
+// GetTopic implements the nlp.NLPer.GetTopic method using the
+// summaries model and the topics prompt and returns a short
+// lowercase label for the theme shared by the essays with the
+// provided titles and the terms which best describe them. The
+// first term is returned when the model does not provide a label.
+func (c *Client) GetTopic(ctx context.Context, titles, terms []string) (*Topic, error) {
+	p, err := c.getPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := p.Topics.execute(topicData{
+		Titles:   titles,
+		Keywords: strings.Join(terms, ", "),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request := completionRequest{
+		model:       c.summariesModel,
+		system:      p.Topics.System,
+		prompt:      prompt,
+		maxTokens:   p.Topics.MaxTokens,
+		temperature: p.Topics.Temperature,
+		stop:        p.Topics.Stop,
+	}
+
+	response, err := c.completer.complete(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	recordUsage(ctx, request.model, &response.usage)
+
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(response.text), `"'.`))
+	if name == "" && len(terms) > 0 {
+		name = terms[0]
+	}
+
+	return &Topic{
+		Name:          name,
+		PromptVersion: p.Version,
+	}, nil
+}
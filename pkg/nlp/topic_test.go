@@ -0,0 +1,100 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGetTopic(t *testing.T) {
+	getObjectErr := errors.New("mock get object error")
+	completeErr := errors.New("mock complete error")
+
+	tests := []struct {
+		description        string
+		mockGetObjectError error
+		responses          []response
+		topic              *Topic
+		error              error
+	}{
+		{
+			description:        "error getting prompts",
+			mockGetObjectError: getObjectErr,
+			responses:          []response{},
+			topic:              nil,
+			error:              getObjectErr,
+		},
+		{
+			description: "error completing topic",
+			responses: []response{
+				{
+					error: completeErr,
+				},
+			},
+			topic: nil,
+			error: completeErr,
+		},
+		{
+			description: "empty topic falls back to first term",
+			responses: []response{
+				{
+					body: []byte(`{"choices": [{"text": "  "}]}`),
+				},
+			},
+			topic: &Topic{
+				Name:          "startup",
+				PromptVersion: defaultPrompts.Version,
+			},
+			error: nil,
+		},
+		{
+			description: "successful invocation",
+			responses: []response{
+				{
+					body: []byte(`{"choices": [{"text": " \"Startup Fundraising.\""}]}`),
+				},
+			},
+			topic: &Topic{
+				Name:          "startup fundraising",
+				PromptVersion: defaultPrompts.Version,
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				completer: &textCompleter{
+					helper: &mockHelper{
+						t:         t,
+						responses: test.responses,
+					},
+				},
+				summariesModel: "gpt-3.5-turbo-instruct",
+				s3Client:       mockPromptsS3Client(),
+			}
+			if test.mockGetObjectError != nil {
+				c.s3Client = &mockS3Client{
+					mockGetObjectError: test.mockGetObjectError,
+				}
+			}
+
+			topic, err := c.GetTopic(context.Background(), []string{
+				"How to Raise Money",
+				"Startup = Growth",
+			}, []string{
+				"startup",
+				"investors",
+			})
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(topic, test.topic) {
+				t.Errorf("incorrect topic, received: %+v, expected: %+v", topic, test.topic)
+			}
+		})
+	}
+}
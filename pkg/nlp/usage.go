@@ -0,0 +1,110 @@
+package nlp
+
+import (
+	"context"
+	"sync"
+)
+
+// Usage represents the tokens used by the LLM provider requests
+// made with a model as reported in the API usage block.
+type Usage struct {
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// Cost returns the cost of the usage in US dollars and false
+// when the price of the model is not known.
+func (u Usage) Cost() (float64, bool) {
+	price, ok := GetPrice(u.Model)
+	if !ok {
+		return 0, false
+	}
+
+	return (float64(u.PromptTokens)*price.Prompt + float64(u.CompletionTokens)*price.Completion) / 1e6, true
+}
+
+// UsageRecorder collects the Usage of the requests made with a
+// context returned by WithUsageRecorder.
+//
+// Recorders may be nested and usage added to a recorder is
+// also added to the recorder of the context it was created from.
+type UsageRecorder struct {
+	parent *UsageRecorder
+
+	mutex sync.Mutex
+	usage []Usage
+}
+
+type usageRecorderKey struct{}
+
+// WithUsageRecorder returns a copy of the provided context which
+// records the Usage of every LLM provider request made with it.
+func WithUsageRecorder(ctx context.Context) (context.Context, *UsageRecorder) {
+	parent, _ := ctx.Value(usageRecorderKey{}).(*UsageRecorder)
+	recorder := &UsageRecorder{
+		parent: parent,
+	}
+	return context.WithValue(ctx, usageRecorderKey{}, recorder), recorder
+}
+
+// Usage returns the recorded Usage totalled by model in the
+// order the models were first used.
+func (r *UsageRecorder) Usage() []Usage {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	usage := make([]Usage, len(r.usage))
+	copy(usage, r.usage)
+
+	return usage
+}
+
+func (r *UsageRecorder) add(model string, promptTokens, completionTokens int) {
+	if r.parent != nil {
+		r.parent.add(model, promptTokens, completionTokens)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.usage {
+		if r.usage[i].Model == model {
+			r.usage[i].PromptTokens += promptTokens
+			r.usage[i].CompletionTokens += completionTokens
+			return
+		}
+	}
+
+	r.usage = append(r.usage, Usage{
+		Model:            model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+	})
+}
+
+// RecordUsage adds the provided usage to the recorder of the
+// context if it has one so that NLPer implementations wrapping
+// other providers are accounted for.
+func RecordUsage(ctx context.Context, usage Usage) {
+	recorder, ok := ctx.Value(usageRecorderKey{}).(*UsageRecorder)
+	if !ok || usage.PromptTokens+usage.CompletionTokens == 0 {
+		return
+	}
+
+	recorder.add(usage.Model, usage.PromptTokens, usage.CompletionTokens)
+}
+
+// recordUsage adds the usage block of a response to the recorder
+// of the context. Responses without a usage block are skipped.
+func recordUsage(ctx context.Context, model string, usage *usageJSON) {
+	if usage == nil {
+		return
+	}
+
+	RecordUsage(ctx, Usage{
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	})
+}
@@ -0,0 +1,137 @@
+package nlp
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestUsageRecorder(t *testing.T) {
+	ctx, recorder := WithUsageRecorder(context.Background())
+
+	recordUsage(ctx, "gpt-4o-mini", &usageJSON{
+		PromptTokens:     100,
+		CompletionTokens: 20,
+	})
+	recordUsage(ctx, "text-embedding-3-small", &usageJSON{
+		PromptTokens: 8,
+	})
+	recordUsage(ctx, "gpt-4o-mini", &usageJSON{
+		PromptTokens:     50,
+		CompletionTokens: 10,
+	})
+	recordUsage(ctx, "gpt-4o-mini", nil)
+	recordUsage(ctx, "gpt-4o-mini", &usageJSON{})
+	recordUsage(context.Background(), "gpt-4o-mini", &usageJSON{
+		PromptTokens: 1,
+	})
+
+	expected := []Usage{
+		{
+			Model:            "gpt-4o-mini",
+			PromptTokens:     150,
+			CompletionTokens: 30,
+		},
+		{
+			Model:        "text-embedding-3-small",
+			PromptTokens: 8,
+		},
+	}
+
+	if usage := recorder.Usage(); !reflect.DeepEqual(usage, expected) {
+		t.Errorf("incorrect usage, received: %+v, expected: %+v", usage, expected)
+	}
+}
+
+func TestUsageRecorder_nested(t *testing.T) {
+	ctx, parent := WithUsageRecorder(context.Background())
+	ctx, child := WithUsageRecorder(ctx)
+
+	recordUsage(ctx, "gpt-4o-mini", &usageJSON{
+		PromptTokens:     100,
+		CompletionTokens: 20,
+	})
+
+	expected := []Usage{
+		{
+			Model:            "gpt-4o-mini",
+			PromptTokens:     100,
+			CompletionTokens: 20,
+		},
+	}
+
+	if usage := child.Usage(); !reflect.DeepEqual(usage, expected) {
+		t.Errorf("incorrect child usage, received: %+v, expected: %+v", usage, expected)
+	}
+
+	if usage := parent.Usage(); !reflect.DeepEqual(usage, expected) {
+		t.Errorf("incorrect parent usage, received: %+v, expected: %+v", usage, expected)
+	}
+}
+
+func TestGetPrice(t *testing.T) {
+	tests := []struct {
+		description string
+		model       string
+		price       Price
+		ok          bool
+	}{
+		{
+			description: "unknown model",
+			model:       "mock-model",
+			price:       Price{},
+			ok:          false,
+		},
+		{
+			description: "exact model",
+			model:       "gpt-4o-mini",
+			price:       Price{Prompt: 0.15, Completion: 0.60},
+			ok:          true,
+		},
+		{
+			description: "dated snapshot of longest matching model",
+			model:       "gpt-4o-mini-2024-07-18",
+			price:       Price{Prompt: 0.15, Completion: 0.60},
+			ok:          true,
+		},
+		{
+			description: "model sharing a prefix without separator",
+			model:       "gpt-4omni",
+			price:       Price{},
+			ok:          false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			price, ok := GetPrice(test.model)
+			if ok != test.ok {
+				t.Errorf("incorrect ok, received: %t, expected: %t", ok, test.ok)
+			}
+
+			if price != test.price {
+				t.Errorf("incorrect price, received: %+v, expected: %+v", price, test.price)
+			}
+		})
+	}
+}
+
+func TestUsage_Cost(t *testing.T) {
+	cost, ok := Usage{
+		Model:            "gpt-3.5-turbo",
+		PromptTokens:     2000,
+		CompletionTokens: 1000,
+	}.Cost()
+	if !ok {
+		t.Fatal("incorrect ok, received: false, expected: true")
+	}
+
+	if expected := 0.0025; math.Abs(cost-expected) > 1e-12 {
+		t.Errorf("incorrect cost, received: %v, expected: %v", cost, expected)
+	}
+
+	if _, ok := (Usage{Model: "mock-model"}).Cost(); ok {
+		t.Error("incorrect ok, received: true, expected: false")
+	}
+}
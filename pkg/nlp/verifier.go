@@ -0,0 +1,240 @@
+package nlp
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/idx"
+)
+
+// DefaultGroundingThreshold is the fraction of the claims in an
+// answer which must be supported by the retrieved passages for
+// the answer not to be flagged.
+const DefaultGroundingThreshold = 0.5
+
+const supportedOverlap = 0.5 // fraction of claim terms found in a passage
+
+// Verifier checks whether the claims in a generated answer are
+// supported by the passages it was generated from.
+type Verifier interface {
+	Verify(ctx context.Context, claims, passages []string) ([]Claim, error)
+}
+
+// Grounding represents the result of checking an answer with a
+// Verifier.
+//
+// Score is the fraction of the claims which are supported and
+// Flagged is set when it falls below the grounding threshold.
+type Grounding struct {
+	Score   float64 `json:"score"`
+	Flagged bool    `json:"flagged"`
+	Claims  []Claim `json:"claims"`
+}
+
+// Claim represents a sentence of an answer and whether it is
+// supported by the passages the answer was generated from.
+type Claim struct {
+	Text      string  `json:"text"`
+	Score     float64 `json:"score"`
+	Supported bool    `json:"supported"`
+}
+
+var _ Verifier = LexicalVerifier{}
+
+// LexicalVerifier implements the Verifier interface by scoring
+// each claim with the largest fraction of its terms found in a
+// single passage and requires no API calls.
+type LexicalVerifier struct{}
+
+// Verify implements the nlp.Verifier.Verify method.
+func (l LexicalVerifier) Verify(ctx context.Context, claims, passages []string) ([]Claim, error) {
+	passageTerms := make([]map[string]bool, len(passages))
+	for i, passage := range passages {
+		passageTerms[i] = map[string]bool{}
+		for _, term := range getTerms(passage) {
+			passageTerms[i][term] = true
+		}
+	}
+
+	output := make([]Claim, len(claims))
+	for i, claim := range claims {
+		output[i] = Claim{
+			Text: claim,
+		}
+
+		terms := getTerms(claim)
+		if len(terms) == 0 {
+			continue
+		}
+
+		best := 0.0
+		for _, found := range passageTerms {
+			matches := 0
+			for _, term := range terms {
+				if found[term] {
+					matches++
+				}
+			}
+
+			if overlap := float64(matches) / float64(len(terms)); overlap > best {
+				best = overlap
+			}
+		}
+
+		output[i].Score = best
+		output[i].Supported = best >= supportedOverlap
+	}
+
+	return output, nil
+}
+
+// getTerms returns the keyword terms of the text with plural
+// endings removed so that "startups" supports "startup".
+func getTerms(text string) []string {
+	terms := idx.Tokenize(text)
+	for i, term := range terms {
+		if len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") {
+			terms[i] = strings.TrimSuffix(term, "s")
+		}
+	}
+
+	return terms
+}
+
+var _ Verifier = &llmVerifier{}
+
+// llmVerifier implements the Verifier interface by asking the
+// model whether each claim is supported by the passages with the
+// verifier prompt.
+type llmVerifier struct {
+	completer completer
+	model     string
+	prompts   func(ctx context.Context) (*prompts, error)
+}
+
+var verdictRegexp = regexp.MustCompile(`(?im)^\s*(\d+)\s*[:.)]\s*(yes|no)\b`)
+
+func (l *llmVerifier) Verify(ctx context.Context, claims, passages []string) ([]Claim, error) {
+	p, err := l.prompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := verifierData{
+		Passages: make([]string, len(passages)),
+		Claims:   make([]verifierClaim, len(claims)),
+	}
+	for i, passage := range passages {
+		data.Passages[i] = strings.TrimSpace(passage)
+	}
+	for i, claim := range claims {
+		data.Claims[i] = verifierClaim{
+			Number: i + 1,
+			Text:   claim,
+		}
+	}
+
+	prompt, err := p.Verifier.execute(data)
+	if err != nil {
+		return nil, err
+	}
+
+	request := completionRequest{
+		model:       l.model,
+		system:      p.Verifier.System,
+		prompt:      prompt,
+		maxTokens:   p.Verifier.MaxTokens,
+		temperature: p.Verifier.Temperature,
+		stop:        p.Verifier.Stop,
+	}
+
+	response, err := l.completer.complete(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	recordUsage(ctx, request.model, &response.usage)
+
+	output := make([]Claim, len(claims))
+	for i, claim := range claims {
+		output[i] = Claim{
+			Text: claim,
+		}
+	}
+
+	for _, match := range verdictRegexp.FindAllStringSubmatch(response.text, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || number < 1 || number > len(claims) {
+			continue
+		}
+
+		if strings.EqualFold(match[2], "yes") {
+			output[number-1].Score = 1
+			output[number-1].Supported = true
+		}
+	}
+
+	return output, nil
+}
+
+// getClaims splits the answer into the sentences to verify,
+// treating each bullet point as its own paragraph and skipping
+// sentences without any keyword terms.
+func getClaims(text string) []string {
+	lines := dct.SplitLines([]dct.Document{
+		{
+			Text: text,
+		},
+	})
+
+	claims := []string{}
+	for _, sentence := range dct.SplitSentences(lines) {
+		if len(getTerms(sentence.Text)) == 0 {
+			continue
+		}
+
+		claims = append(claims, sentence.Text)
+	}
+
+	return claims
+}
+
+// verify checks the answer text against the passages and flags
+// it when the fraction of supported claims is below the grounding
+// threshold. Answers without claims are fully grounded.
+func (c *Client) verify(ctx context.Context, text string, passages []passage) (*Grounding, error) {
+	claims := getClaims(text)
+	if len(claims) == 0 {
+		return &Grounding{
+			Score:  1,
+			Claims: []Claim{},
+		}, nil
+	}
+
+	texts := make([]string, len(passages))
+	for i, passage := range passages {
+		texts[i] = passage.text
+	}
+
+	verified, err := c.verifier.Verify(ctx, claims, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	supported := 0
+	for _, claim := range verified {
+		if claim.Supported {
+			supported++
+		}
+	}
+
+	score := float64(supported) / float64(len(claims))
+
+	return &Grounding{
+		Score:   score,
+		Flagged: score < c.groundingThreshold,
+		Claims:  verified,
+	}, nil
+}
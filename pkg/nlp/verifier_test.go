@@ -0,0 +1,229 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLexicalVerifier_Verify(t *testing.T) {
+	claims, err := LexicalVerifier{}.Verify(context.Background(), []string{
+		"Startups should be ramen profitable.",
+		"Investors prefer founders who code.",
+	}, []string{
+		"Being ramen profitable changes the relationship with investors for a startup.",
+		"Unrelated passage.",
+	})
+	if err != nil {
+		t.Fatalf("incorrect error, received: %v, expected: nil", err)
+	}
+
+	expected := []Claim{
+		{
+			Text:      "Startups should be ramen profitable.",
+			Score:     0.75,
+			Supported: true,
+		},
+		{
+			Text:      "Investors prefer founders who code.",
+			Score:     0.25,
+			Supported: false,
+		},
+	}
+
+	if !reflect.DeepEqual(claims, expected) {
+		t.Errorf("incorrect claims, received: %+v, expected: %+v", claims, expected)
+	}
+}
+
+func Test_llmVerifier_Verify(t *testing.T) {
+	promptsErr := errors.New("mock prompts error")
+	completeErr := errors.New("mock complete error")
+
+	tests := []struct {
+		description  string
+		promptsError error
+		responses    []response
+		claims       []Claim
+		error        error
+	}{
+		{
+			description:  "error getting prompts",
+			promptsError: promptsErr,
+			responses:    []response{},
+			claims:       nil,
+			error:        promptsErr,
+		},
+		{
+			description: "error completing verdicts",
+			responses: []response{
+				{
+					error: completeErr,
+				},
+			},
+			claims: nil,
+			error:  completeErr,
+		},
+		{
+			description: "successful invocation",
+			responses: []response{
+				{
+					body: []byte(`{"choices": [{"text": "1: yes\n2: No\n7: yes"}]}`),
+				},
+			},
+			claims: []Claim{
+				{
+					Text:      "First claim.",
+					Score:     1,
+					Supported: true,
+				},
+				{
+					Text:      "Second claim.",
+					Score:     0,
+					Supported: false,
+				},
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			l := &llmVerifier{
+				completer: &textCompleter{
+					helper: &mockHelper{
+						t:         t,
+						responses: test.responses,
+					},
+				},
+				model: "gpt-3.5-turbo-instruct",
+				prompts: func(ctx context.Context) (*prompts, error) {
+					if test.promptsError != nil {
+						return nil, test.promptsError
+					}
+					return defaultPrompts, nil
+				},
+			}
+
+			claims, err := l.Verify(context.Background(), []string{
+				"First claim.",
+				"Second claim.",
+			}, []string{
+				"passage",
+			})
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(claims, test.claims) {
+				t.Errorf("incorrect claims, received: %+v, expected: %+v", claims, test.claims)
+			}
+		})
+	}
+}
+
+func Test_getClaims(t *testing.T) {
+	tests := []struct {
+		description string
+		text        string
+		claims      []string
+	}{
+		{
+			description: "empty text",
+			text:        "",
+			claims:      []string{},
+		},
+		{
+			description: "sentences split",
+			text:        "Growth matters. Is it?",
+			claims: []string{
+				"Growth matters.",
+			},
+		},
+		{
+			description: "bullet points split",
+			text:        "- Make something people want\n- Talk to users.",
+			claims: []string{
+				"Make something people want.",
+				"Talk to users.",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if claims := getClaims(test.text); !reflect.DeepEqual(claims, test.claims) {
+				t.Errorf("incorrect claims, received: %q, expected: %q", claims, test.claims)
+			}
+		})
+	}
+}
+
+func Test_verify(t *testing.T) {
+	passages := []passage{
+		{
+			text:     "Startups that grow fast make something people want.",
+			metadata: "mock_id",
+		},
+	}
+
+	tests := []struct {
+		description string
+		text        string
+		threshold   float64
+		score       float64
+		flagged     bool
+	}{
+		{
+			description: "answer without claims",
+			text:        "It is.",
+			threshold:   DefaultGroundingThreshold,
+			score:       1,
+			flagged:     false,
+		},
+		{
+			description: "grounded answer",
+			text:        "Startups grow fast. They make something people want.",
+			threshold:   DefaultGroundingThreshold,
+			score:       1,
+			flagged:     false,
+		},
+		{
+			description: "ungrounded answer",
+			text:        "Startups grow fast. Founders should raise money from angels. Boards slow companies down.",
+			threshold:   DefaultGroundingThreshold,
+			score:       1.0 / 3,
+			flagged:     true,
+		},
+		{
+			description: "negative threshold never flags",
+			text:        "Founders should raise money from angels.",
+			threshold:   -1,
+			score:       0,
+			flagged:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := &Client{
+				verifier:           LexicalVerifier{},
+				groundingThreshold: test.threshold,
+			}
+
+			grounding, err := c.verify(context.Background(), test.text, passages)
+			if err != nil {
+				t.Fatalf("incorrect error, received: %v, expected: nil", err)
+			}
+
+			if grounding.Score != test.score {
+				t.Errorf("incorrect score, received: %f, expected: %f", grounding.Score, test.score)
+			}
+
+			if grounding.Flagged != test.flagged {
+				t.Errorf("incorrect flagged, received: %t, expected: %t", grounding.Flagged, test.flagged)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package ssn
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+// DefaultTTL is how long sessions are kept after their latest
+// question when no session TTL is set.
+const DefaultTTL = time.Hour
+
+// sessionTurns is the number of most recent turns kept in a
+// conversation session.
+const sessionTurns = 10
+
+// ErrInvalidSession is returned for session IDs which are not
+// UUIDs.
+var ErrInvalidSession = errors.New("session_id must be a uuid")
+
+// GetSession returns the conversation session of the provided ID
+// and nil when no ID was provided.
+func GetSession(ctx context.Context, dbClient db.Databaser, id string) (*db.Session, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	return dbClient.GetSession(ctx, id)
+}
+
+// StoreTurn adds the question and its answer to the session,
+// keeping the most recent turns, and stores it to expire after
+// the TTL. Refusals and empty answers are not added since they
+// give follow-up questions no context.
+func StoreTurn(ctx context.Context, dbClient db.Databaser, session *db.Session, ttl time.Duration, question string, answer *nlp.Answer) error {
+	if session == nil || answer.Text == "" || answer.Refusal != nil {
+		return nil
+	}
+
+	turns := append(session.Turns, nlp.Turn{
+		Question: question,
+		Answer:   answer.Text,
+	})
+	if len(turns) > sessionTurns {
+		turns = turns[len(turns)-sessionTurns:]
+	}
+
+	return dbClient.StoreSession(ctx, db.Session{
+		ID:        session.ID,
+		Turns:     turns,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	})
+}
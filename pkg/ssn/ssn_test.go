@@ -0,0 +1,295 @@
+package ssn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+type mockDBClient struct {
+	mockGetSessionOutput  *db.Session
+	mockGetSessionError   error
+	mockStoreSessionError error
+	storedSessions        []db.Session
+}
+
+func (m *mockDBClient) GetIDs(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummaries(ctx context.Context) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummariesByIDs(ctx context.Context, ids []string) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSummaries(ctx context.Context, summaries []db.Summary) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreRelated(ctx context.Context, id string, related []db.Related) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreText(ctx context.Context, id, text string) error {
+	return nil
+}
+
+func (m *mockDBClient) GetDocuments(ctx context.Context) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreQuestion(ctx context.Context, id, question string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	return nil
+}
+
+func (m *mockDBClient) GetCachedAnswer(ctx context.Context, question, mode string) (*db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetCachedAnswers(ctx context.Context) ([]db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreCachedAnswer(ctx context.Context, cachedAnswer db.CachedAnswer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreUsage(ctx context.Context, usage db.Usage) error {
+	return nil
+}
+
+func (m *mockDBClient) GetUsage(ctx context.Context) ([]db.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSpend(ctx context.Context, month string) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) GetSession(ctx context.Context, id string) (*db.Session, error) {
+	return m.mockGetSessionOutput, m.mockGetSessionError
+}
+
+func (m *mockDBClient) StoreSession(ctx context.Context, session db.Session) error {
+	m.storedSessions = append(m.storedSessions, session)
+	return m.mockStoreSessionError
+}
+
+func TestGetSession(t *testing.T) {
+	mockGetSessionErr := errors.New("mock get session error")
+
+	session := &db.Session{
+		ID: "9b2f5c4e-8d1a-4c3b-9e7f-1a2b3c4d5e6f",
+		Turns: []nlp.Turn{
+			{
+				Question: "What is a startup?",
+				Answer:   "A company designed to grow fast.",
+			},
+		},
+	}
+
+	tests := []struct {
+		description         string
+		id                  string
+		mockGetSessionError error
+		session             *db.Session
+		error               error
+	}{
+		{
+			description:         "no session id",
+			id:                  "",
+			mockGetSessionError: nil,
+			session:             nil,
+			error:               nil,
+		},
+		{
+			description:         "invalid session id",
+			id:                  "not-a-uuid",
+			mockGetSessionError: nil,
+			session:             nil,
+			error:               ErrInvalidSession,
+		},
+		{
+			description:         "error getting session",
+			id:                  session.ID,
+			mockGetSessionError: mockGetSessionErr,
+			session:             nil,
+			error:               mockGetSessionErr,
+		},
+		{
+			description:         "successful invocation",
+			id:                  session.ID,
+			mockGetSessionError: nil,
+			session:             session,
+			error:               nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockGetSessionError: test.mockGetSessionError,
+			}
+			if test.mockGetSessionError == nil {
+				dbClient.mockGetSessionOutput = session
+			}
+
+			received, err := GetSession(context.Background(), dbClient, test.id)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if !reflect.DeepEqual(received, test.session) {
+				t.Errorf("incorrect session, received: %+v, expected: %+v", received, test.session)
+			}
+		})
+	}
+}
+
+func TestStoreTurn(t *testing.T) {
+	mockStoreSessionErr := errors.New("mock store session error")
+
+	fullTurns := make([]nlp.Turn, sessionTurns)
+	for i := range fullTurns {
+		fullTurns[i] = nlp.Turn{
+			Question: fmt.Sprintf("question %d", i),
+			Answer:   fmt.Sprintf("answer %d", i),
+		}
+	}
+
+	tests := []struct {
+		description           string
+		session               *db.Session
+		answer                *nlp.Answer
+		mockStoreSessionError error
+		turns                 [][]nlp.Turn
+		error                 error
+	}{
+		{
+			description: "no session",
+			session:     nil,
+			answer: &nlp.Answer{
+				Text: "answer",
+			},
+			mockStoreSessionError: nil,
+			turns:                 nil,
+			error:                 nil,
+		},
+		{
+			description: "empty answer skipped",
+			session: &db.Session{
+				ID: "mock_id",
+			},
+			answer:                &nlp.Answer{},
+			mockStoreSessionError: nil,
+			turns:                 nil,
+			error:                 nil,
+		},
+		{
+			description: "refusal skipped",
+			session: &db.Session{
+				ID: "mock_id",
+			},
+			answer: &nlp.Answer{
+				Text:    "refusal",
+				Refusal: &nlp.Refusal{},
+			},
+			mockStoreSessionError: nil,
+			turns:                 nil,
+			error:                 nil,
+		},
+		{
+			description: "error storing session",
+			session: &db.Session{
+				ID: "mock_id",
+			},
+			answer: &nlp.Answer{
+				Text: "answer",
+			},
+			mockStoreSessionError: mockStoreSessionErr,
+			turns: [][]nlp.Turn{
+				{
+					{
+						Question: "question",
+						Answer:   "answer",
+					},
+				},
+			},
+			error: mockStoreSessionErr,
+		},
+		{
+			description: "oldest turn dropped",
+			session: &db.Session{
+				ID:    "mock_id",
+				Turns: fullTurns,
+			},
+			answer: &nlp.Answer{
+				Text: "answer",
+			},
+			mockStoreSessionError: nil,
+			turns: [][]nlp.Turn{
+				append(append([]nlp.Turn{}, fullTurns[1:]...), nlp.Turn{
+					Question: "question",
+					Answer:   "answer",
+				}),
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockStoreSessionError: test.mockStoreSessionError,
+			}
+
+			err := StoreTurn(context.Background(), dbClient, test.session, time.Hour, "question", test.answer)
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			turns := [][]nlp.Turn(nil)
+			for _, session := range dbClient.storedSessions {
+				if session.ID != test.session.ID || session.ExpiresAt.Before(time.Now().Add(time.Hour-time.Minute)) {
+					t.Errorf("incorrect session, received: %+v", session)
+				}
+
+				turns = append(turns, session.Turns)
+			}
+
+			if !reflect.DeepEqual(turns, test.turns) {
+				t.Errorf("incorrect turns, received: %+v, expected: %+v", turns, test.turns)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package tkn
+
+import "unicode"
+
+// The pre-tokenizer patterns of the encodings rely on a negative
+// lookahead which is not supported by the regexp package so the
+// text is split by hand following the order of the alternatives.
+//
+// cl100k_base:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// o200k_base:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+
+type splitter struct {
+	text    string
+	runes   []rune
+	offsets []int
+}
+
+func newSplitter(text string) *splitter {
+	s := &splitter{
+		text: text,
+	}
+
+	for offset, r := range text {
+		s.runes = append(s.runes, r)
+		s.offsets = append(s.offsets, offset)
+	}
+	s.offsets = append(s.offsets, len(text))
+
+	return s
+}
+
+func (s *splitter) pieces(match func(i int) int) []string {
+	pieces := []string{}
+	for i := 0; i < len(s.runes); {
+		end := match(i)
+		if end <= i {
+			end = i + 1
+		}
+
+		pieces = append(pieces, s.text[s.offsets[i]:s.offsets[end]])
+		i = end
+	}
+
+	return pieces
+}
+
+func splitCL100K(text string) []string {
+	s := newSplitter(text)
+	return s.pieces(func(i int) int {
+		if end := s.contraction(i); end >= 0 {
+			return end
+		}
+
+		if end := s.letters(i); end >= 0 {
+			return end
+		}
+
+		return s.others(i, isNewline)
+	})
+}
+
+func splitO200K(text string) []string {
+	s := newSplitter(text)
+	return s.pieces(func(i int) int {
+		if end := s.withPrefix(i, s.lowerWords); end >= 0 {
+			return end
+		}
+
+		if end := s.withPrefix(i, s.upperWords); end >= 0 {
+			return end
+		}
+
+		return s.others(i, isNewlineOrSlash)
+	})
+}
+
+// others matches the number, punctuation, and whitespace
+// alternatives shared by the encodings with the provided
+// punctuation suffix.
+func (s *splitter) others(i int, suffix func(r rune) bool) int {
+	if end := s.numbers(i); end >= 0 {
+		return end
+	}
+
+	if end := s.punctuation(i, suffix); end >= 0 {
+		return end
+	}
+
+	return s.whitespace(i)
+}
+
+// contraction matches (?i:'s|'t|'re|'ve|'m|'ll|'d).
+func (s *splitter) contraction(i int) int {
+	if i+1 >= len(s.runes) || s.runes[i] != '\'' {
+		return -1
+	}
+
+	next := s.runes[i+1]
+	for _, suffix := range []rune{'s', 't', 'm', 'd'} {
+		if foldEqual(next, suffix) {
+			return i + 2
+		}
+	}
+
+	if i+2 >= len(s.runes) {
+		return -1
+	}
+
+	for _, suffix := range [][2]rune{{'r', 'e'}, {'v', 'e'}, {'l', 'l'}} {
+		if foldEqual(next, suffix[0]) && foldEqual(s.runes[i+2], suffix[1]) {
+			return i + 3
+		}
+	}
+
+	return -1
+}
+
+// letters matches [^\r\n\p{L}\p{N}]?\p{L}+.
+func (s *splitter) letters(i int) int {
+	start := i
+	if isPrefix(s.runes[i]) && i+1 < len(s.runes) && unicode.IsLetter(s.runes[i+1]) {
+		start = i + 1
+	}
+
+	if !unicode.IsLetter(s.runes[start]) {
+		return -1
+	}
+
+	return s.run(start, unicode.IsLetter)
+}
+
+// withPrefix matches [^\r\n\p{L}\p{N}]? followed by the provided
+// word match, preferring to include the prefix.
+func (s *splitter) withPrefix(i int, words func(i int) int) int {
+	if isPrefix(s.runes[i]) && i+1 < len(s.runes) {
+		if end := words(i + 1); end >= 0 {
+			return end
+		}
+	}
+
+	return words(i)
+}
+
+// lowerWords matches
+// [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+
+// followed by an optional contraction.
+func (s *splitter) lowerWords(i int) int {
+	upper := s.run(i, isUpper)
+	for start := upper; start >= i; start-- {
+		if start < len(s.runes) && isLower(s.runes[start]) {
+			return s.optionalContraction(s.run(start, isLower))
+		}
+	}
+
+	return -1
+}
+
+// upperWords matches
+// [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*
+// followed by an optional contraction.
+func (s *splitter) upperWords(i int) int {
+	upper := s.run(i, isUpper)
+	if upper == i {
+		return -1
+	}
+
+	return s.optionalContraction(s.run(upper, isLower))
+}
+
+func (s *splitter) optionalContraction(i int) int {
+	if end := s.contraction(i); end >= 0 {
+		return end
+	}
+	return i
+}
+
+// numbers matches \p{N}{1,3}.
+func (s *splitter) numbers(i int) int {
+	end := i
+	for end < len(s.runes) && end-i < 3 && unicode.IsNumber(s.runes[end]) {
+		end++
+	}
+
+	if end == i {
+		return -1
+	}
+
+	return end
+}
+
+// punctuation matches ?[^\s\p{L}\p{N}]+ followed by a run of the
+// provided suffix, [\r\n]* or [\r\n/]*.
+func (s *splitter) punctuation(i int, suffix func(r rune) bool) int {
+	start := i
+	if s.runes[i] == ' ' && i+1 < len(s.runes) && isPunctuation(s.runes[i+1]) {
+		start = i + 1
+	}
+
+	if !isPunctuation(s.runes[start]) {
+		return -1
+	}
+
+	return s.run(s.run(start, isPunctuation), suffix)
+}
+
+// whitespace matches \s*[\r\n]+|\s+(?!\S)|\s+.
+func (s *splitter) whitespace(i int) int {
+	end := s.run(i, unicode.IsSpace)
+	if end == i {
+		return -1
+	}
+
+	for last := end - 1; last >= i; last-- {
+		if isNewline(s.runes[last]) {
+			return last + 1
+		}
+	}
+
+	if end < len(s.runes) && end-i > 1 {
+		return end - 1
+	}
+
+	return end
+}
+
+func (s *splitter) run(i int, match func(r rune) bool) int {
+	for i < len(s.runes) && match(s.runes[i]) {
+		i++
+	}
+	return i
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+func isNewlineOrSlash(r rune) bool {
+	return isNewline(r) || r == '/'
+}
+
+func isPrefix(r rune) bool {
+	return !isNewline(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func isPunctuation(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func isUpper(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+func isLower(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+func foldEqual(r, target rune) bool {
+	for folded := target; ; {
+		if folded == r {
+			return true
+		}
+
+		folded = unicode.SimpleFold(folded)
+		if folded == target {
+			return false
+		}
+	}
+}
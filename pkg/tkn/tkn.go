@@ -0,0 +1,264 @@
+// Package tkn counts tokens with the byte pair encodings used by
+// the OpenAI models.
+//
+// The cl100k_base and o200k_base vocabularies are embedded in
+// the binary so no files are downloaded at runtime. The
+// vocabularies are published by OpenAI in the MIT licensed
+// tiktoken project.
+package tkn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// CL100KBase is the encoding used by the GPT-3.5, GPT-4, and
+	// third generation embeddings models.
+	CL100KBase = "cl100k_base"
+
+	// O200KBase is the encoding used by the GPT-4o and later
+	// models.
+	O200KBase = "o200k_base"
+)
+
+// ErrUnknownModel is returned by ForModel when the encoding of
+// the provided model is not known.
+var ErrUnknownModel = errors.New("tkn: unknown model")
+
+//go:embed assets/*.tiktoken.gz
+var assets embed.FS
+
+var modelEncodings = map[string]string{
+	"gpt-4":                  CL100KBase,
+	"gpt-3.5-turbo":          CL100KBase,
+	"gpt-35-turbo":           CL100KBase,
+	"text-embedding-ada-002": CL100KBase,
+	"text-embedding-3-small": CL100KBase,
+	"text-embedding-3-large": CL100KBase,
+	"o1":                     O200KBase,
+	"o3":                     O200KBase,
+	"gpt-4o":                 O200KBase,
+}
+
+var modelPrefixEncodings = []struct {
+	prefix   string
+	encoding string
+}{
+	{"o1-", O200KBase},
+	{"o3-", O200KBase},
+	{"o4-", O200KBase},
+	{"gpt-4o-", O200KBase},
+	{"gpt-4.1", O200KBase},
+	{"gpt-4.5-", O200KBase},
+	{"gpt-5", O200KBase},
+	{"chatgpt-4o-", O200KBase},
+	{"gpt-4-", CL100KBase},
+	{"gpt-3.5-turbo-", CL100KBase},
+	{"gpt-35-turbo-", CL100KBase},
+}
+
+var encodings = map[string]*Encoding{
+	CL100KBase: {
+		name:  CL100KBase,
+		split: splitCL100K,
+	},
+	O200KBase: {
+		name:  O200KBase,
+		split: splitO200K,
+	},
+}
+
+// Encoding is a byte pair encoding which converts text into
+// model tokens.
+type Encoding struct {
+	name  string
+	split func(text string) []string
+
+	once   sync.Once
+	err    error
+	ranks  map[string]int
+	tokens map[int]string
+}
+
+// Get returns the Encoding with the provided name, loading its
+// vocabulary on first use.
+func Get(name string) (*Encoding, error) {
+	encoding, ok := encodings[name]
+	if !ok {
+		return nil, fmt.Errorf("tkn: unknown encoding %q", name)
+	}
+
+	encoding.once.Do(encoding.load)
+	if encoding.err != nil {
+		return nil, encoding.err
+	}
+
+	return encoding, nil
+}
+
+// ForModel returns the Encoding used by the provided model and
+// ErrUnknownModel if it is not known.
+func ForModel(model string) (*Encoding, error) {
+	if name, ok := modelEncodings[model]; ok {
+		return Get(name)
+	}
+
+	for _, prefixEncoding := range modelPrefixEncodings {
+		if strings.HasPrefix(model, prefixEncoding.prefix) {
+			return Get(prefixEncoding.encoding)
+		}
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownModel, model)
+}
+
+// Name returns the name of the encoding.
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode returns the tokens of the provided text. Special
+// tokens such as "<|endoftext|>" are encoded as plain text.
+func (e *Encoding) Encode(text string) []int {
+	tokens := []int{}
+	for _, piece := range e.split(validUTF8(text)) {
+		if rank, ok := e.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+
+		tokens = append(tokens, e.merge(piece)...)
+	}
+
+	return tokens
+}
+
+// Count returns the number of tokens in the provided text.
+func (e *Encoding) Count(text string) int {
+	count := 0
+	for _, piece := range e.split(validUTF8(text)) {
+		if _, ok := e.ranks[piece]; ok {
+			count++
+			continue
+		}
+
+		count += len(e.merge(piece))
+	}
+
+	return count
+}
+
+// Decode returns the text of the provided tokens. Unknown
+// tokens are skipped.
+func (e *Encoding) Decode(tokens []int) string {
+	text := strings.Builder{}
+	for _, token := range tokens {
+		text.WriteString(e.tokens[token])
+	}
+
+	return text.String()
+}
+
+// merge applies the byte pair merges to a piece of text which is
+// not a token itself by repeatedly joining the adjacent parts
+// with the lowest rank.
+func (e *Encoding) merge(piece string) []int {
+	parts := make([]string, 0, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts = append(parts, piece[i:i+1])
+	}
+
+	for len(parts) > 1 {
+		lowest, index := math.MaxInt32, -1
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := e.ranks[parts[i]+parts[i+1]]; ok && rank < lowest {
+				lowest, index = rank, i
+			}
+		}
+
+		if index < 0 {
+			break
+		}
+
+		parts[index] += parts[index+1]
+		parts = append(parts[:index+1], parts[index+2:]...)
+	}
+
+	tokens := make([]int, len(parts))
+	for i, part := range parts {
+		tokens[i] = e.ranks[part]
+	}
+
+	return tokens
+}
+
+// validUTF8 replaces each invalid byte with the replacement
+// character as is done when the text is encoded to JSON.
+func validUTF8(text string) string {
+	if utf8.ValidString(text) {
+		return text
+	}
+
+	return strings.Map(func(r rune) rune {
+		return r
+	}, text)
+}
+
+func (e *Encoding) load() {
+	data, err := assets.ReadFile("assets/" + e.name + ".tiktoken.gz")
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		e.err = err
+		return
+	}
+	defer reader.Close()
+
+	ranks := map[string]int{}
+	tokens := map[int]string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			e.err = err
+			return
+		}
+
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			e.err = err
+			return
+		}
+
+		ranks[string(token)] = rank
+		tokens[rank] = string(token)
+	}
+
+	if err := scanner.Err(); err != nil {
+		e.err = err
+		return
+	}
+
+	e.ranks = ranks
+	e.tokens = tokens
+}
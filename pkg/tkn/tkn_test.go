@@ -0,0 +1,140 @@
+package tkn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		description string
+		encoding    string
+		text        string
+		tokens      []int
+	}{
+		{
+			description: "empty text",
+			encoding:    CL100KBase,
+			text:        "",
+			tokens:      []int{},
+		},
+		{
+			description: "cl100k_base words",
+			encoding:    CL100KBase,
+			text:        "hello world",
+			tokens:      []int{15339, 1917},
+		},
+		{
+			description: "cl100k_base punctuation, whitespace, and contractions",
+			encoding:    CL100KBase,
+			text:        "How do I start a startup?\n\n  Don't worry.",
+			tokens:      []int{4438, 656, 358, 1212, 264, 21210, 1980, 220, 4418, 956, 11196, 13},
+		},
+		{
+			description: "cl100k_base numbers",
+			encoding:    CL100KBase,
+			text:        "It's 2024 /path",
+			tokens:      []int{2181, 596, 220, 2366, 19, 611, 2398},
+		},
+		{
+			description: "cl100k_base special token as text",
+			encoding:    CL100KBase,
+			text:        "<|endoftext|>",
+			tokens:      []int{27, 91, 8862, 728, 428, 91, 29},
+		},
+		{
+			description: "o200k_base words",
+			encoding:    O200KBase,
+			text:        "hello world",
+			tokens:      []int{24912, 2375},
+		},
+		{
+			description: "o200k_base punctuation, whitespace, and contractions",
+			encoding:    O200KBase,
+			text:        "How do I start a startup?\n\n  Don't worry.",
+			tokens:      []int{5299, 621, 357, 1604, 261, 34220, 1715, 220, 19666, 14479, 13},
+		},
+		{
+			description: "o200k_base numbers",
+			encoding:    O200KBase,
+			text:        "It's 2024 /path",
+			tokens:      []int{15834, 220, 1323, 19, 820, 4189},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			encoding, err := Get(test.encoding)
+			if err != nil {
+				t.Fatalf("error getting encoding: %v", err)
+			}
+
+			tokens := encoding.Encode(test.text)
+			if !reflect.DeepEqual(tokens, test.tokens) {
+				t.Errorf("incorrect tokens, received: %v, expected: %v", tokens, test.tokens)
+			}
+
+			if count := encoding.Count(test.text); count != len(test.tokens) {
+				t.Errorf("incorrect count, received: %d, expected: %d", count, len(test.tokens))
+			}
+
+			if text := encoding.Decode(tokens); text != test.text {
+				t.Errorf("incorrect text, received: %q, expected: %q", text, test.text)
+			}
+		})
+	}
+}
+
+func TestForModel(t *testing.T) {
+	tests := []struct {
+		description string
+		model       string
+		encoding    string
+		error       error
+	}{
+		{
+			description: "unknown model",
+			model:       "text-davinci-002",
+			encoding:    "",
+			error:       ErrUnknownModel,
+		},
+		{
+			description: "exact chat model",
+			model:       "gpt-3.5-turbo",
+			encoding:    CL100KBase,
+			error:       nil,
+		},
+		{
+			description: "prefixed chat model",
+			model:       "gpt-3.5-turbo-instruct",
+			encoding:    CL100KBase,
+			error:       nil,
+		},
+		{
+			description: "embeddings model",
+			model:       "text-embedding-3-small",
+			encoding:    CL100KBase,
+			error:       nil,
+		},
+		{
+			description: "o200k_base model",
+			model:       "gpt-4o-mini",
+			encoding:    O200KBase,
+			error:       nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			encoding, err := ForModel(test.model)
+			if !errors.Is(err, test.error) {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			if encoding != nil && encoding.Name() != test.encoding {
+				t.Errorf("incorrect encoding, received: %s, expected: %s", encoding.Name(), test.encoding)
+			}
+		})
+	}
+}
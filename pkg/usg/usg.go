@@ -0,0 +1,55 @@
+package usg
+
+import (
+	"context"
+	"time"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+// usageTimeout bounds the usage write which is not bound by the
+// deadline of the request it is recorded for.
+const usageTimeout = 2 * time.Second
+
+// StoreUsage stores the LLM provider usage collected by the
+// recorder with the row of the provided ID. Nothing is stored
+// when no usage was recorded.
+//
+// The usage is written with a context detached from the provided
+// context so that usage of requests which ran out of their
+// deadline budget or were canceled is still stored.
+func StoreUsage(ctx context.Context, dbClient db.Databaser, recorder *nlp.UsageRecorder, id, endpoint string) error {
+	tokens := recorder.Usage()
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, usageTimeout)
+	defer cancel()
+
+	return dbClient.StoreUsage(ctx, db.Usage{
+		ID:        id,
+		Endpoint:  endpoint,
+		Timestamp: time.Now().UTC(),
+		Tokens:    tokens,
+	})
+}
+
+// detachedContext keeps the values of the wrapped context
+// without its deadline or cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
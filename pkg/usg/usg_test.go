@@ -0,0 +1,188 @@
+package usg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+type mockDBClient struct {
+	mockStoreUsageError error
+	storedUsage         []db.Usage
+	storedUsageErrors   []error
+}
+
+func (m *mockDBClient) GetIDs(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummaries(ctx context.Context) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSummariesByIDs(ctx context.Context, ids []string) ([]db.Summary, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSummaries(ctx context.Context, summaries []db.Summary) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreRelated(ctx context.Context, id string, related []db.Related) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreTopics(ctx context.Context, id string, topics []string, promptVersion string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreText(ctx context.Context, id, text string) error {
+	return nil
+}
+
+func (m *mockDBClient) GetDocuments(ctx context.Context) ([]dct.Document, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreDocuments(ctx context.Context, documents []dct.Document) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreQuestion(ctx context.Context, id, question string) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreAnswer(ctx context.Context, id string, answer nlp.Answer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreModerations(ctx context.Context, id string, moderations []nlp.Moderation) error {
+	return nil
+}
+
+func (m *mockDBClient) GetCachedAnswer(ctx context.Context, question, mode string) (*db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetCachedAnswers(ctx context.Context) ([]db.CachedAnswer, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreCachedAnswer(ctx context.Context, cachedAnswer db.CachedAnswer) error {
+	return nil
+}
+
+func (m *mockDBClient) StoreUsage(ctx context.Context, usage db.Usage) error {
+	m.storedUsage = append(m.storedUsage, usage)
+	m.storedUsageErrors = append(m.storedUsageErrors, ctx.Err())
+	return m.mockStoreUsageError
+}
+
+func (m *mockDBClient) GetUsage(ctx context.Context) ([]db.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) GetSpend(ctx context.Context, month string) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) AddSpend(ctx context.Context, month string, amount float64) (float64, error) {
+	return 0, nil
+}
+
+func (m *mockDBClient) GetSession(ctx context.Context, id string) (*db.Session, error) {
+	return nil, nil
+}
+
+func (m *mockDBClient) StoreSession(ctx context.Context, session db.Session) error {
+	return nil
+}
+
+func TestStoreUsage(t *testing.T) {
+	mockStoreUsageErr := errors.New("mock store usage error")
+
+	tokens := []nlp.Usage{
+		{
+			Model:            "gpt-3.5-turbo",
+			PromptTokens:     100,
+			CompletionTokens: 20,
+		},
+	}
+
+	tests := []struct {
+		description         string
+		tokens              []nlp.Usage
+		mockStoreUsageError error
+		storedTokens        [][]nlp.Usage
+		error               error
+	}{
+		{
+			description:         "no usage recorded",
+			tokens:              []nlp.Usage{},
+			mockStoreUsageError: nil,
+			storedTokens:        nil,
+			error:               nil,
+		},
+		{
+			description:         "error storing usage",
+			tokens:              tokens,
+			mockStoreUsageError: mockStoreUsageErr,
+			storedTokens: [][]nlp.Usage{
+				tokens,
+			},
+			error: mockStoreUsageErr,
+		},
+		{
+			description:         "successful invocation",
+			tokens:              tokens,
+			mockStoreUsageError: nil,
+			storedTokens: [][]nlp.Usage{
+				tokens,
+			},
+			error: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dbClient := &mockDBClient{
+				mockStoreUsageError: test.mockStoreUsageError,
+			}
+
+			ctx, recorder := nlp.WithUsageRecorder(context.Background())
+			for _, usage := range test.tokens {
+				nlp.RecordUsage(ctx, usage)
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			err := StoreUsage(ctx, dbClient, recorder, "mock_id", "question")
+			if err != test.error {
+				t.Errorf("incorrect error, received: %v, expected: %v", err, test.error)
+			}
+
+			storedTokens := [][]nlp.Usage(nil)
+			for i, usage := range dbClient.storedUsage {
+				if usage.ID != "mock_id" || usage.Endpoint != "question" {
+					t.Errorf("incorrect usage, received: %+v", usage)
+				}
+
+				if dbClient.storedUsageErrors[i] != nil {
+					t.Errorf("incorrect context error, received: %v, expected: nil", dbClient.storedUsageErrors[i])
+				}
+
+				storedTokens = append(storedTokens, usage.Tokens)
+			}
+
+			if !reflect.DeepEqual(storedTokens, test.storedTokens) {
+				t.Errorf("incorrect stored tokens, received: %+v, expected: %+v", storedTokens, test.storedTokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Package vld validates user questions and answer modes before
+// they are stored or sent to the LLM provider.
+package vld
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+// MaxQuestionLength is the maximum number of characters in a
+// question.
+const MaxQuestionLength = 100
+
+// minLatinRatio is the fraction of the letters in a question
+// which must be Latin letters for it to be answered since the
+// essays are written in the Latin script. The language of the
+// question itself is not checked.
+const minLatinRatio = 0.5
+
+// Reason codes sent with validation errors so that clients can
+// tell the failures apart.
+const (
+	EmptyQuestionCode     = "QUESTION_EMPTY"
+	QuestionTooLongCode   = "QUESTION_TOO_LONG"
+	InvalidCharactersCode = "QUESTION_INVALID_CHARACTERS"
+	UnsupportedScriptCode = "QUESTION_UNSUPPORTED_SCRIPT"
+	UnsupportedModeCode   = "MODE_UNSUPPORTED"
+)
+
+// Error represents a question which failed validation.
+//
+// Code is one of the reason codes and Message describes the
+// failure to users.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ValidateQuestion returns an Error when the question is empty
+// or whitespace-only, is longer than MaxQuestionLength
+// characters, holds control characters or invalid UTF-8, or is
+// not mostly written in Latin letters.
+func ValidateQuestion(question string) error {
+	if !utf8.ValidString(question) {
+		return &Error{
+			Code:    InvalidCharactersCode,
+			Message: "question must be valid utf-8",
+		}
+	}
+
+	if strings.TrimSpace(question) == "" {
+		return &Error{
+			Code:    EmptyQuestionCode,
+			Message: "question is required",
+		}
+	}
+
+	if utf8.RuneCountInString(question) > MaxQuestionLength {
+		return &Error{
+			Code:    QuestionTooLongCode,
+			Message: fmt.Sprintf("question must be less than or equal to %d characters", MaxQuestionLength),
+		}
+	}
+
+	letters, latin := 0, 0
+	for _, r := range question {
+		if unicode.IsControl(r) {
+			return &Error{
+				Code:    InvalidCharactersCode,
+				Message: "question must not contain control characters",
+			}
+		}
+
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.Is(unicode.Latin, r) {
+				latin++
+			}
+		}
+	}
+
+	if letters > 0 && float64(latin) < minLatinRatio*float64(letters) {
+		return &Error{
+			Code:    UnsupportedScriptCode,
+			Message: "question must be written in the latin script",
+		}
+	}
+
+	return nil
+}
+
+// ValidateMode returns an Error when the answer mode is set and
+// is not one of nlp.Modes. An empty mode selects nlp.DefaultMode.
+func ValidateMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+
+	for _, supported := range nlp.Modes {
+		if mode == supported {
+			return nil
+		}
+	}
+
+	return &Error{
+		Code:    UnsupportedModeCode,
+		Message: fmt.Sprintf("mode must be one of %s", strings.Join(nlp.Modes, ", ")),
+	}
+}
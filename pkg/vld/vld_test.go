@@ -0,0 +1,136 @@
+package vld
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+)
+
+func TestValidateQuestion(t *testing.T) {
+	tests := []struct {
+		description string
+		question    string
+		code        string
+	}{
+		{
+			description: "empty question",
+			question:    "",
+			code:        EmptyQuestionCode,
+		},
+		{
+			description: "whitespace-only question",
+			question:    " \t ",
+			code:        EmptyQuestionCode,
+		},
+		{
+			description: "question too long",
+			question:    strings.Repeat("a", MaxQuestionLength+1),
+			code:        QuestionTooLongCode,
+		},
+		{
+			description: "multibyte question within limit",
+			question:    strings.Repeat("é", MaxQuestionLength),
+			code:        "",
+		},
+		{
+			description: "question with control characters",
+			question:    "what is\x00 a startup?",
+			code:        InvalidCharactersCode,
+		},
+		{
+			description: "question with newline",
+			question:    "what is\na startup?",
+			code:        InvalidCharactersCode,
+		},
+		{
+			description: "question with invalid utf-8",
+			question:    "what is \xff a startup?",
+			code:        InvalidCharactersCode,
+		},
+		{
+			description: "question in unsupported script",
+			question:    "что такое стартап?",
+			code:        UnsupportedScriptCode,
+		},
+		{
+			description: "question with numbers only",
+			question:    "42?",
+			code:        "",
+		},
+		{
+			description: "valid question",
+			question:    "What makes a startup succeed?",
+			code:        "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := ValidateQuestion(test.question)
+
+			if test.code == "" {
+				if err != nil {
+					t.Errorf("incorrect error, received: %v, expected: nil", err)
+				}
+				return
+			}
+
+			validationErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("incorrect error type, received: %v, expected: %v", reflect.TypeOf(err), reflect.TypeOf(&Error{}))
+			}
+
+			if validationErr.Code != test.code {
+				t.Errorf("incorrect code, received: %s, expected: %s", validationErr.Code, test.code)
+			}
+		})
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	tests := []struct {
+		description string
+		mode        string
+		code        string
+	}{
+		{
+			description: "empty mode",
+			mode:        "",
+			code:        "",
+		},
+		{
+			description: "unsupported mode",
+			mode:        "haiku",
+			code:        UnsupportedModeCode,
+		},
+		{
+			description: "supported mode",
+			mode:        nlp.BulletsMode,
+			code:        "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := ValidateMode(test.mode)
+
+			if test.code == "" {
+				if err != nil {
+					t.Errorf("incorrect error, received: %v, expected: nil", err)
+				}
+				return
+			}
+
+			validationErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("incorrect error type, received: %v, expected: %v", reflect.TypeOf(err), reflect.TypeOf(&Error{}))
+			}
+
+			if validationErr.Code != test.code {
+				t.Errorf("incorrect code, received: %s, expected: %s", validationErr.Code, test.code)
+			}
+		})
+	}
+}
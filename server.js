@@ -20,9 +20,43 @@ app.post('/question', async (req, res) => {
 	res.json(questionResponse.data);
 });
 
+app.post('/question/stream', express.json(), async (req, res) => {
+	let streamResponse = await axios.post(
+		process.env.APG_STREAM_URL,
+		req.body,
+		{
+			responseType: 'stream',
+			validateStatus: () => true,
+		},
+	);
+	res.status(streamResponse.status);
+	res.set('Content-Type', streamResponse.headers['content-type']);
+	streamResponse.data.pipe(res);
+});
+
 app.get('/summaries', async (req, res) => {
 	let summariesResponse = await axios.get(
 		process.env.APG_SUMMARIES_URL,
 	);
 	res.json(summariesResponse.data);
-});
\ No newline at end of file
+});
+
+app.get('/quotes', async (req, res) => {
+	let quotesResponse = await axios.get(
+		process.env.APG_QUOTES_URL,
+		{
+			params: req.query,
+		},
+	);
+	res.json(quotesResponse.data);
+});
+
+app.get('/timeline', async (req, res) => {
+	let timelineResponse = await axios.get(
+		process.env.APG_TIMELINE_URL,
+		{
+			params: req.query,
+		},
+	);
+	res.json(timelineResponse.data);
+});
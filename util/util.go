@@ -1,25 +1,38 @@
 package util
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/golang-jwt/jwt/v4"
 
+	"github.com/forstmeier/askpaulgraham/pkg/bgt"
 	"github.com/forstmeier/askpaulgraham/pkg/db"
+	"github.com/forstmeier/askpaulgraham/pkg/dct"
+	"github.com/forstmeier/askpaulgraham/pkg/ess"
+	"github.com/forstmeier/askpaulgraham/pkg/nlp"
+	"github.com/forstmeier/askpaulgraham/pkg/ssn"
+	"github.com/forstmeier/askpaulgraham/pkg/vld"
 )
 
 // Config represents the config.json file.
 type Config struct {
 	AWS    AWS    `json:"aws"`
 	OpenAI OpenAI `json:"open_ai"`
+	LLM    LLM    `json:"llm"`
 }
 
 // AWS represents aws config.json file field.
@@ -44,6 +57,294 @@ type OpenAI struct {
 	APIKey string `json:"api_key"`
 }
 
+// LLM represents llm config.json file field.
+//
+// Empty fields fall back to the OpenAI defaults and the
+// open_ai api_key field is used for authentication.
+type LLM struct {
+	BaseURL              string  `json:"base_url"`
+	AuthHeader           string  `json:"auth_header"`
+	AuthScheme           string  `json:"auth_scheme"`
+	API                  string  `json:"api"`
+	SummariesModel       string  `json:"summaries_model"`
+	AnswersModel         string  `json:"answers_model"`
+	EmbeddingsModel      string  `json:"embeddings_model"`
+	EmbeddingsDimensions int     `json:"embeddings_dimensions"`
+	ContextTokens        int     `json:"context_tokens"`
+	ModerationModel      string  `json:"moderation_model"`
+	VerifierModel        string  `json:"verifier_model"`
+	GroundingThreshold   float64 `json:"grounding_threshold"`
+}
+
+// GetProvider returns the LLM provider described by the
+// config.json file.
+func GetProvider(config Config) nlp.Provider {
+	return nlp.Provider{
+		BaseURL:              config.LLM.BaseURL,
+		APIKey:               config.OpenAI.APIKey,
+		AuthHeader:           config.LLM.AuthHeader,
+		AuthScheme:           config.LLM.AuthScheme,
+		API:                  config.LLM.API,
+		SummariesModel:       config.LLM.SummariesModel,
+		AnswersModel:         config.LLM.AnswersModel,
+		EmbeddingsModel:      config.LLM.EmbeddingsModel,
+		EmbeddingsDimensions: config.LLM.EmbeddingsDimensions,
+		ContextTokens:        config.LLM.ContextTokens,
+		ModerationModel:      config.LLM.ModerationModel,
+		VerifierModel:        config.LLM.VerifierModel,
+		GroundingThreshold:   config.LLM.GroundingThreshold,
+	}
+}
+
+// GetEnvProvider returns the LLM provider described by the
+// Lambda environment variables.
+func GetEnvProvider() (*nlp.Provider, error) {
+	embeddingsDimensions, err := getEnvInt("LLM_EMBEDDINGS_DIMENSIONS")
+	if err != nil {
+		return nil, err
+	}
+
+	contextTokens, err := getEnvInt("LLM_CONTEXT_TOKENS")
+	if err != nil {
+		return nil, err
+	}
+
+	groundingThreshold, err := getEnvFloat("GROUNDING_THRESHOLD")
+	if err != nil {
+		return nil, err
+	}
+
+	return &nlp.Provider{
+		BaseURL:              os.Getenv("LLM_BASE_URL"),
+		APIKey:               os.Getenv("OPENAI_API_KEY"),
+		AuthHeader:           os.Getenv("LLM_AUTH_HEADER"),
+		AuthScheme:           os.Getenv("LLM_AUTH_SCHEME"),
+		API:                  os.Getenv("LLM_API"),
+		SummariesModel:       os.Getenv("LLM_SUMMARIES_MODEL"),
+		AnswersModel:         os.Getenv("LLM_ANSWERS_MODEL"),
+		EmbeddingsModel:      os.Getenv("LLM_EMBEDDINGS_MODEL"),
+		EmbeddingsDimensions: embeddingsDimensions,
+		ContextTokens:        contextTokens,
+		ModerationModel:      os.Getenv("LLM_MODERATION_MODEL"),
+		VerifierModel:        os.Getenv("LLM_VERIFIER_MODEL"),
+		GroundingThreshold:   groundingThreshold,
+	}, nil
+}
+
+// GetEnvRetrieval returns the retrieval settings described by
+// the Lambda environment variables.
+func GetEnvRetrieval() (*nlp.Retrieval, error) {
+	embeddingsWeight, err := getEnvFloat("RETRIEVAL_EMBEDDINGS_WEIGHT")
+	if err != nil {
+		return nil, err
+	}
+
+	keywordWeight, err := getEnvFloat("RETRIEVAL_KEYWORD_WEIGHT")
+	if err != nil {
+		return nil, err
+	}
+
+	return &nlp.Retrieval{
+		Method:           os.Getenv("RETRIEVAL"),
+		EmbeddingsWeight: embeddingsWeight,
+		KeywordWeight:    keywordWeight,
+	}, nil
+}
+
+// GetEnvCacheThreshold returns the answer cache similarity
+// threshold described by the Lambda environment variables.
+func GetEnvCacheThreshold() (float64, error) {
+	return getEnvFloat("CACHE_SIMILARITY_THRESHOLD")
+}
+
+// GetEnvBudget returns the monthly LLM provider budget described
+// by the Lambda environment variables.
+func GetEnvBudget() (*bgt.Budget, error) {
+	limit, err := getEnvFloat("BUDGET_MONTHLY_LIMIT")
+	if err != nil {
+		return nil, err
+	}
+
+	softThreshold, err := getEnvFloat("BUDGET_SOFT_THRESHOLD")
+	if err != nil {
+		return nil, err
+	}
+
+	unknownModelPrice, err := getEnvFloat("BUDGET_UNKNOWN_MODEL_PRICE")
+	if err != nil {
+		return nil, err
+	}
+
+	return &bgt.Budget{
+		Limit:             limit,
+		SoftThreshold:     softThreshold,
+		FallbackModel:     os.Getenv("BUDGET_FALLBACK_MODEL"),
+		UnknownModelPrice: unknownModelPrice,
+	}, nil
+}
+
+// GetEnvSessionTTL returns how long conversation sessions are
+// kept after their latest question described by the Lambda
+// environment variables.
+func GetEnvSessionTTL() (time.Duration, error) {
+	minutes, err := getEnvInt("SESSION_TTL_MINUTES")
+	if err != nil {
+		return 0, err
+	}
+
+	if minutes <= 0 {
+		return ssn.DefaultTTL, nil
+	}
+
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+func getEnvInt(key string) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return number, nil
+}
+
+func getEnvFloat(key string) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return number, nil
+}
+
+// ErrTimeout is sent in place of errors caused by the request
+// running out of its deadline budget.
+var ErrTimeout = errors.New("request timed out")
+
+// WithDeadlineBudget returns a copy of the provided context with
+// a deadline the reserve duration ahead of its own deadline,
+// such as the Lambda invocation deadline, so that outbound calls
+// are cancelled while there is still time to send a response.
+// Contexts without a deadline are only made cancelable.
+func WithDeadlineBudget(ctx context.Context, reserve time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, deadline.Add(-reserve))
+}
+
+// GetErrorStatus returns the status code to send for the
+// provided error along with how long the client should wait
+// before retrying.
+//
+// Requests which ran out of their deadline return 504, rate
+// limited LLM provider requests return 429, unavailable or out
+// of quota providers and a spent monthly budget return 503, and
+// invalid questions and session IDs return 400 while all other
+// errors return 500.
+func GetErrorStatus(err error) (int, time.Duration) {
+	if isTimeout(err) {
+		return http.StatusGatewayTimeout, 0
+	}
+
+	validationErr := &vld.Error{}
+	if errors.Is(err, ssn.ErrInvalidSession) || errors.Is(err, ess.ErrInvalidCount) || errors.Is(err, ess.ErrInvalidSort) || errors.Is(err, ess.ErrInvalidPage) || errors.Is(err, ess.ErrInvalidYears) || errors.As(err, &validationErr) {
+		return http.StatusBadRequest, 0
+	}
+
+	if errors.Is(err, ess.ErrEssayNotFound) {
+		return http.StatusNotFound, 0
+	}
+
+	if errors.Is(err, bgt.ErrThrottled) {
+		return http.StatusServiceUnavailable, 0
+	}
+
+	apiErr := &nlp.APIError{}
+	if !errors.As(err, &apiErr) {
+		return http.StatusInternalServerError, 0
+	}
+
+	switch {
+	case apiErr.StatusCode == http.StatusTooManyRequests && apiErr.Type != "insufficient_quota":
+		return http.StatusTooManyRequests, apiErr.RetryAfter
+	case apiErr.Temporary():
+		return http.StatusServiceUnavailable, apiErr.RetryAfter
+	default:
+		return http.StatusInternalServerError, 0
+	}
+}
+
+// GetErrorCode returns the error code sent with the provided
+// error for clients to tell it apart from other errors and an
+// empty string for errors without one.
+func GetErrorCode(err error) string {
+	if errors.Is(err, bgt.ErrThrottled) {
+		return bgt.ThrottledCode
+	}
+
+	validationErr := &vld.Error{}
+	if errors.As(err, &validationErr) {
+		return validationErr.Code
+	}
+
+	return ""
+}
+
+// isTimeout reports whether the error was caused by a context
+// deadline including those wrapped by the AWS SDK which does not
+// support errors.Is.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == request.CanceledErrorCode {
+		return errors.Is(awsErr.OrigErr(), context.DeadlineExceeded)
+	}
+
+	return false
+}
+
+// SendErrorResponse sends the provided error with the status
+// code from GetErrorStatus and a Retry-After header when a wait
+// is known. Timeouts are sent as ErrTimeout.
+func SendErrorResponse(err error, message string) (events.APIGatewayProxyResponse, error) {
+	statusCode, retryAfter := GetErrorStatus(err)
+	if statusCode == http.StatusGatewayTimeout {
+		Log(message, err.Error())
+		err = ErrTimeout
+	}
+
+	response, sendErr := SendResponse(statusCode, err, message)
+	if retryAfter > 0 {
+		response.Headers = map[string]string{
+			"Retry-After": FormatRetryAfter(retryAfter),
+		}
+	}
+
+	return response, sendErr
+}
+
+// FormatRetryAfter formats the provided wait as a Retry-After
+// header value in whole seconds.
+func FormatRetryAfter(retryAfter time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+}
+
 // Log provides a basic wrapper to format log output.
 func Log(key string, value interface{}) {
 	logMessage(key, value)
@@ -58,8 +359,10 @@ func SendResponse(statusCode int, payload interface{}, message string) (events.A
 	case error:
 		body = struct {
 			Error string `json:"error"`
+			Code  string `json:"code,omitempty"`
 		}{
-			Error: payload.(error).Error(),
+			Error: payloadValue.Error(),
+			Code:  GetErrorCode(payloadValue),
 		}
 
 	case []db.Summary:
@@ -71,13 +374,62 @@ func SendResponse(statusCode int, payload interface{}, message string) (events.A
 			Summaries: payloadValue,
 		}
 
-	case string:
+	case []db.Topic:
+		body = struct {
+			Message string     `json:"message"`
+			Topics  []db.Topic `json:"topics"`
+		}{
+			Message: "success",
+			Topics:  payloadValue,
+		}
+
+	case db.Timeline:
 		body = struct {
 			Message string `json:"message"`
-			Answer  string `json:"answer"`
+			db.Timeline
+		}{
+			Message:  "success",
+			Timeline: payloadValue,
+		}
+
+	case []dct.Document:
+		body = struct {
+			Message string         `json:"message"`
+			Results []dct.Document `json:"results"`
+		}{
+			Message: "success",
+			Results: payloadValue,
+		}
+
+	case []nlp.Citation:
+		body = struct {
+			Message string         `json:"message"`
+			Quotes  []nlp.Citation `json:"quotes"`
 		}{
 			Message: "success",
-			Answer:  payloadValue,
+			Quotes:  payloadValue,
+		}
+
+	case nlp.Answer:
+		citations := payloadValue.Citations
+		if citations == nil {
+			citations = []nlp.Citation{}
+		}
+
+		body = struct {
+			Message   string         `json:"message"`
+			Answer    string         `json:"answer"`
+			Citations []nlp.Citation `json:"citations"`
+			Cached    bool           `json:"cached"`
+			Refusal   *nlp.Refusal   `json:"refusal,omitempty"`
+			Grounding *nlp.Grounding `json:"grounding,omitempty"`
+		}{
+			Message:   "success",
+			Answer:    payloadValue.Text,
+			Citations: citations,
+			Cached:    payloadValue.Cached,
+			Refusal:   payloadValue.Refusal,
+			Grounding: payloadValue.Grounding,
 		}
 
 	}